@@ -3,17 +3,27 @@ package pnet
 import "errors"
 
 var (
-	ErrNilMessage        = errors.New("nil message")
-	ErrEmptyEncodeBuffer = errors.New("encoded buffer length is 0")
-	ErrInvalidMagic      = errors.New("invalid magic")
-	ErrMessageTooLarge   = errors.New("message is too large")
-	ErrClosedConn        = errors.New("connection is closed")
-	ErrRegisteredSession = errors.New("session is registered")
-	ErrInvalidSessionId  = errors.New("invalid session id")
-	ErrOutOfReadCap      = errors.New("out of read capacity")
-	ErrOutOfWriteCap     = errors.New("out of write capacity")
-	ErrClosedClient      = errors.New("client is closed")
-	ErrWriteQueueFull    = errors.New("write queue is full")
-	ErrClientActiveClose = errors.New("client active close")
-	ErrInvalidLengthSize = errors.New("invalid length size")
+	ErrNilMessage         = errors.New("nil message")
+	ErrEmptyEncodeBuffer  = errors.New("encoded buffer length is 0")
+	ErrInvalidMagic       = errors.New("invalid magic")
+	ErrMessageTooLarge    = errors.New("message is too large")
+	ErrClosedConn         = errors.New("connection is closed")
+	ErrRegisteredSession  = errors.New("session is registered")
+	ErrInvalidSessionId   = errors.New("invalid session id")
+	ErrOutOfReadCap       = errors.New("out of read capacity")
+	ErrOutOfWriteCap      = errors.New("out of write capacity")
+	ErrClosedClient       = errors.New("client is closed")
+	ErrWriteQueueFull     = errors.New("write queue is full")
+	ErrClientActiveClose  = errors.New("client active close")
+	ErrInvalidLengthSize  = errors.New("invalid length size")
+	ErrIdleTimeout        = errors.New("idle timeout")
+	ErrServerDraining     = errors.New("server is draining")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	ErrServerStopped      = errors.New("server is stopped")
+	ErrSessionExpired     = errors.New("session is expired")
+	ErrSessionKicked      = errors.New("session is replaced by a new one")
+	ErrSlowConsumer       = errors.New("slow consumer")
+	ErrResumeSeqMissed    = errors.New("resume sequence is out of replay buffer")
+	ErrMismatchedMessage  = errors.New("mismatched message type")
 )
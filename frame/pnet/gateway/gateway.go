@@ -0,0 +1,251 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/message"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	tcpserver "github.com/meow-pad/persian/frame/pnet/tcp/server"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	udpserver "github.com/meow-pad/persian/frame/pnet/udp/server"
+	wsserver "github.com/meow-pad/persian/frame/pnet/ws/server"
+	"sync"
+)
+
+// server
+//
+//	@Description: 网关下的单个监听服务
+type server interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	ResumeSession(sess session.Session, token string, lastSeq uint64) error
+	Broadcast(groupId uint64, message any, exclude ...session.Session) error
+	BroadcastAll(message any, exclude ...session.Session) error
+}
+
+// NewGateway
+//
+//	@Description: 构造网关，多个地址与协议的监听共享会话管理器与监听器
+//	@param name 网关名称
+//	@param listener 会话监听器
+//	@param opts
+//	@return *Gateway
+//	@return error
+func NewGateway(name string, listener session.Listener, opts ...Option) (*Gateway, error) {
+	if listener == nil {
+		return nil, errors.New("less listener")
+	}
+	options := NewOptions(opts...)
+	manager, err := session.NewManager(name, options.UnregisterSessionLife)
+	if err != nil {
+		return nil, err
+	}
+	if options.Resume != nil {
+		manager.EnableResume(options.Resume)
+	}
+	return &Gateway{
+		Manager:  manager,
+		options:  options,
+		name:     name,
+		listener: listener,
+	}, nil
+}
+
+// Gateway
+//
+//	@Description: 网关，同一用户经任一监听注册时都会替换其他监听上的旧会话
+type Gateway struct {
+	*session.Manager
+	options *Options
+	// 网关名称
+	name string
+	// 共享的会话监听器
+	listener session.Listener
+	// 监听服务，按添加顺序启动
+	servers []server
+	// 已启动的服务数
+	started int
+}
+
+// AddTCP
+//
+//	@Description: 添加tcp监听，需在启动前调用
+//	@receiver gateway
+//	@param protoAddr 带协议地址
+//	@param codec 编解码器
+//	@param opts 服务配置，会话管理相关配置以网关为准
+//	@return *tcpserver.Server
+//	@return error
+func (gateway *Gateway) AddTCP(protoAddr string, codec codec.Codec, opts ...tcpserver.Option) (*tcpserver.Server, error) {
+	opts = append(opts, tcpserver.WithManager(gateway.Manager), func(options *tcpserver.Options) {
+		options.Resume = gateway.options.Resume
+	})
+	svr, err := tcpserver.NewServer(gateway.serverName(), protoAddr, codec, gateway.listener, opts...)
+	if err != nil {
+		return nil, err
+	}
+	gateway.servers = append(gateway.servers, svr)
+	return svr, nil
+}
+
+// AddWS
+//
+//	@Description: 添加websocket监听，需在启动前调用
+//	@receiver gateway
+//	@param protoAddr 地址
+//	@param codec 消息解析器
+//	@param opts 服务配置，会话管理相关配置以网关为准
+//	@return *wsserver.Server
+//	@return error
+func (gateway *Gateway) AddWS(protoAddr string, codec message.Codec, opts ...wsserver.Option) (*wsserver.Server, error) {
+	opts = append(opts, wsserver.WithManager(gateway.Manager), func(options *wsserver.Options) {
+		options.Resume = gateway.options.Resume
+	})
+	svr, err := wsserver.NewServer(gateway.serverName(), protoAddr, codec, gateway.listener, opts...)
+	if err != nil {
+		return nil, err
+	}
+	gateway.servers = append(gateway.servers, svr)
+	return svr, nil
+}
+
+// AddUDP
+//
+//	@Description: 添加可靠udp监听，需在启动前调用
+//	@receiver gateway
+//	@param protoAddr 带协议地址
+//	@param codec 编解码器
+//	@param opts 服务配置，会话管理相关配置以网关为准
+//	@return *udpserver.Server
+//	@return error
+func (gateway *Gateway) AddUDP(protoAddr string, codec codec.Codec, opts ...udpserver.Option) (*udpserver.Server, error) {
+	opts = append(opts, udpserver.WithManager(gateway.Manager), func(options *udpserver.Options) {
+		options.Resume = gateway.options.Resume
+	})
+	svr, err := udpserver.NewServer(gateway.serverName(), protoAddr, codec, gateway.listener, opts...)
+	if err != nil {
+		return nil, err
+	}
+	gateway.servers = append(gateway.servers, svr)
+	return svr, nil
+}
+
+func (gateway *Gateway) serverName() string {
+	return fmt.Sprintf("%s-%d", gateway.name, len(gateway.servers))
+}
+
+// Start
+//
+//	@Description: 按添加顺序启动所有监听，任一启动失败时停止已启动的监听
+//	@receiver gateway
+//	@param ctx
+//	@return error
+func (gateway *Gateway) Start(ctx context.Context) error {
+	if len(gateway.servers) <= 0 {
+		return errors.New("less server")
+	}
+	for _, svr := range gateway.servers {
+		if err := svr.Start(ctx); err != nil {
+			if stopErr := gateway.Stop(ctx); stopErr != nil {
+				plog.Error("stop gateway error:", pfield.String("gateway", gateway.name), pfield.Error(stopErr))
+			}
+			return fmt.Errorf("start %s error: %w", svr.Name(), err)
+		}
+		gateway.started++
+	}
+	return nil
+}
+
+// Stop
+//
+//	@Description: 并发停止所有已启动的监听，各监听分别排空
+//	@receiver gateway
+//	@param ctx
+//	@return error
+func (gateway *Gateway) Stop(ctx context.Context) error {
+	started := gateway.servers[:gateway.started]
+	gateway.started = 0
+	errs := make([]error, len(started))
+	wg := sync.WaitGroup{}
+	for i, svr := range started {
+		wg.Add(1)
+		go func(i int, svr server) {
+			defer wg.Done()
+			if err := svr.Stop(ctx); err != nil {
+				errs[i] = fmt.Errorf("stop %s error: %w", svr.Name(), err)
+			}
+		}(i, svr)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (gateway *Gateway) Name() string {
+	return gateway.name
+}
+
+// CName
+//
+//	@Description: 组件名，用于作为 pboot.LifeCycle 注册
+//	@receiver gateway
+//	@return string
+func (gateway *Gateway) CName() string {
+	return gateway.name
+}
+
+// ResumeSession
+//
+//	@Description: 在新连接的会话上恢复会话，由接受该会话的监听处理
+//	@receiver gateway
+//	@param sess 新连接的未注册会话
+//	@param token 注册时签发的恢复令牌
+//	@param lastSeq 客户端已收到的最后消息序号
+//	@return error 原会话在其他协议的监听上且有缺失消息时返回 pnet.ErrResumeSeqMissed
+func (gateway *Gateway) ResumeSession(sess session.Session, token string, lastSeq uint64) error {
+	for _, svr := range gateway.servers {
+		if err := svr.ResumeSession(sess, token, lastSeq); !errors.Is(err, errdef.ErrInvalidParams) {
+			return err
+		}
+	}
+	return errdef.ErrInvalidParams
+}
+
+// Broadcast
+//
+//	@Description: 向分组成员广播消息，消息在每个监听上编码一次
+//	@receiver gateway
+//	@param groupId 分组编号
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (gateway *Gateway) Broadcast(groupId uint64, message any, exclude ...session.Session) error {
+	errs := make([]error, 0)
+	for _, svr := range gateway.servers {
+		if err := svr.Broadcast(groupId, message, exclude...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BroadcastAll
+//
+//	@Description: 向所有已注册会话广播消息，消息在每个监听上编码一次
+//	@receiver gateway
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (gateway *Gateway) BroadcastAll(message any, exclude ...session.Session) error {
+	errs := make([]error, 0)
+	for _, svr := range gateway.servers {
+		if err := svr.BroadcastAll(message, exclude...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
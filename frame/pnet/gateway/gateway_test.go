@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/meow-pad/persian/frame/pnet/message"
+	"github.com/meow-pad/persian/frame/pnet/tcp/client"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	tcpserver "github.com/meow-pad/persian/frame/pnet/tcp/server"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	udpclient "github.com/meow-pad/persian/frame/pnet/udp/client"
+	wsserver "github.com/meow-pad/persian/frame/pnet/ws/server"
+	"github.com/panjf2000/gnet/v2"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type loginListener struct {
+	session.EmptyListener
+	closed chan *session.CloseReason
+}
+
+func (listener *loginListener) OnClosed(sess session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func (listener *loginListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	if msg == "login" {
+		ctx := &session.BaseContext{}
+		ctx.Init(1)
+		ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+		if err = sess.Register(ctx); err == nil {
+			sess.SendMessage("ok")
+		}
+	}
+	return
+}
+
+type cliListener struct {
+	session.EmptyListener
+	received chan any
+}
+
+func (listener *cliListener) OnReceive(sess session.Session, msg any, msgLen int) error {
+	listener.received <- msg
+	return nil
+}
+
+func TestGateway(t *testing.T) {
+	should := require.New(t)
+	tcpAddr, wsAddr, udpAddr := "127.0.0.1:12095", "127.0.0.1:9086", "127.0.0.1:12099"
+	listener := &loginListener{closed: make(chan *session.CloseReason, 2)}
+	gateway, err := NewGateway("test-gateway", listener)
+	should.Nil(err)
+	tcpCodec, err := codec.NewLengthFieldCodec()
+	should.Nil(err)
+	_, err = gateway.AddTCP("tcp://"+tcpAddr, tcpCodec,
+		tcpserver.WithGNetOption(gnet.WithReuseAddr(true)))
+	should.Nil(err)
+	_, err = gateway.AddWS(wsAddr, &message.TextCodec{},
+		wsserver.WithGNetOption(gnet.WithReuseAddr(true)), wsserver.WithTextFrame(true))
+	should.Nil(err)
+	_, err = gateway.AddUDP("udp://"+udpAddr, tcpCodec)
+	should.Nil(err)
+	should.Nil(gateway.Start(context.Background()))
+	expect := func(received chan any, expected any) {
+		select {
+		case msg := <-received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	expectKicked := func() {
+		select {
+		case reason := <-listener.closed:
+			should.Equal(session.CloseKicked, reason.Category)
+		case <-time.After(3 * time.Second):
+			should.FailNow("close timeout")
+		}
+	}
+
+	// tcp登录
+	receiver := &cliListener{received: make(chan any, 1)}
+	cli, err := client.NewClient(tcpCodec, receiver)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), tcpAddr))
+	cli.SendMessage("login")
+	expect(receiver.received, "ok")
+
+	// ws登录替换tcp会话
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+wsAddr+"/", nil)
+	should.Nil(err)
+	should.Nil(conn.WriteMessage(websocket.TextMessage, []byte("login")))
+	_, data, err := conn.ReadMessage()
+	should.Nil(err)
+	should.Equal("ok", string(data))
+	expectKicked()
+	should.Eventually(cli.IsClosed, 3*time.Second, 10*time.Millisecond)
+	should.NotNil(gateway.GetSession(1))
+
+	// 广播到各监听上的会话
+	should.Nil(gateway.BroadcastAll("hello"))
+	_, data, err = conn.ReadMessage()
+	should.Nil(err)
+	should.Equal("hello", string(data))
+
+	// tcp再次登录替换ws会话
+	cli, err = client.NewClient(tcpCodec, receiver)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), tcpAddr))
+	cli.SendMessage("login")
+	expect(receiver.received, "ok")
+	expectKicked()
+	_, _, err = conn.ReadMessage()
+	should.NotNil(err)
+	_ = conn.Close()
+
+	// udp登录替换tcp会话
+	udpReceiver := &cliListener{received: make(chan any, 1)}
+	udpCli, err := udpclient.NewClient(tcpCodec, udpReceiver)
+	should.Nil(err)
+	should.Nil(udpCli.Dial(context.Background(), udpAddr))
+	udpCli.SendMessage("login")
+	expect(udpReceiver.received, "ok")
+	expectKicked()
+	should.Eventually(cli.IsClosed, 3*time.Second, 10*time.Millisecond)
+	should.Nil(udpCli.Close())
+	should.Nil(gateway.Stop(context.Background()))
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"time"
+)
+
+// NewOptions
+//
+//	@Description: 创建 Options
+//	@param opts
+//	@return *Options
+func NewOptions(opts ...Option) *Options {
+	options := &Options{
+		UnregisterSessionLife: 20,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+type Options struct {
+	// 未注册session的存活时间，单位秒
+	UnregisterSessionLife int64
+	// 会话恢复配置，为nil时不支持恢复，所有监听共用
+	Resume *session.ResumeOptions
+}
+
+type Option func(options *Options)
+
+func WithUnregisterSessionLife(value int64) Option {
+	return func(opts *Options) {
+		opts.UnregisterSessionLife = value
+	}
+}
+
+// WithResume
+//
+//	@Description: 开启会话恢复，跨协议恢复时仅在没有缺失消息时成功
+//	@param gracePeriod 会话关闭后保留恢复状态的时长
+//	@param bufferSize 重放缓冲保留的消息数
+//	@return Option
+func WithResume(gracePeriod time.Duration, bufferSize int) Option {
+	return func(opts *Options) {
+		opts.Resume = &session.ResumeOptions{
+			GracePeriod: gracePeriod,
+			BufferSize:  bufferSize,
+		}
+	}
+}
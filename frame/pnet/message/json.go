@@ -0,0 +1,240 @@
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/utils/json"
+	"reflect"
+	"strconv"
+)
+
+type JsonOptions struct {
+	// 是否拒绝未知字段
+	Strict bool
+	// 最大消息长度，小于等于0时不限制
+	MaxLength int
+}
+
+type JsonOption func(*JsonOptions)
+
+func WithJsonStrict(value bool) JsonOption {
+	return func(options *JsonOptions) {
+		options.Strict = value
+	}
+}
+
+func WithJsonMaxLength(value int) JsonOption {
+	return func(options *JsonOptions) {
+		options.MaxLength = value
+	}
+}
+
+func NewJsonCodec(opts ...JsonOption) *JsonCodec {
+	options := &JsonOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return &JsonCodec{
+		JsonOptions:   options,
+		nameFactories: make(map[string]func() any),
+		idFactories:   make(map[uint32]func() any),
+		types:         make(map[reflect.Type]jsonType),
+	}
+}
+
+// jsonType
+//
+//	@Description: 信封中的消息类型
+type jsonType struct {
+	name string
+	id   uint32
+	// 是否以名称标识
+	named bool
+}
+
+// jsonEnvelope
+//
+//	@Description: 编码用消息信封
+type jsonEnvelope struct {
+	T any `json:"t"`
+	D any `json:"d"`
+}
+
+// rawJsonEnvelope
+//
+//	@Description: 解码用消息信封
+type rawJsonEnvelope struct {
+	T json.RawMessage `json:"t"`
+	D json.RawMessage `json:"d"`
+}
+
+// JsonCodec
+//
+//	@Description: json消息解析器
+//		消息注册不是协程安全的，需在服务启动前完成
+//		消息格式为 {"t":<类型名称或编号>,"d":<消息内容>}
+type JsonCodec struct {
+	*JsonOptions
+
+	// 类型名称对应的构造函数
+	nameFactories map[string]func() any
+	// 类型编号对应的构造函数
+	idFactories map[uint32]func() any
+	// 消息类型对应的标识
+	types map[reflect.Type]jsonType
+}
+
+// RegisterMessage
+//
+//	@Description: 以类型名称注册消息
+//	@receiver codec
+//	@param name 类型名称
+//	@param factory 消息构造函数，应返回指针
+//	@return error
+func (codec *JsonCodec) RegisterMessage(name string, factory func() any) error {
+	if len(name) <= 0 || factory == nil {
+		return errdef.ErrInvalidParams
+	}
+	if _, ok := codec.nameFactories[name]; ok {
+		return fmt.Errorf("type name(%s) is registered", name)
+	}
+	if err := codec.registerType(factory, jsonType{name: name, named: true}); err != nil {
+		return err
+	}
+	codec.nameFactories[name] = factory
+	return nil
+}
+
+// RegisterMessageId
+//
+//	@Description: 以类型编号注册消息
+//	@receiver codec
+//	@param id 类型编号
+//	@param factory 消息构造函数，应返回指针
+//	@return error
+func (codec *JsonCodec) RegisterMessageId(id uint32, factory func() any) error {
+	if factory == nil {
+		return errdef.ErrInvalidParams
+	}
+	if _, ok := codec.idFactories[id]; ok {
+		return fmt.Errorf("type id(%d) is registered", id)
+	}
+	if err := codec.registerType(factory, jsonType{id: id}); err != nil {
+		return err
+	}
+	codec.idFactories[id] = factory
+	return nil
+}
+
+func (codec *JsonCodec) registerType(factory func() any, jType jsonType) error {
+	msg := factory()
+	if msg == nil {
+		return errdef.ErrNilValue
+	}
+	msgType := reflect.TypeOf(msg)
+	if _, ok := codec.types[msgType]; ok {
+		return fmt.Errorf("message(%s) is registered", msgType.String())
+	}
+	codec.types[msgType] = jType
+	return nil
+}
+
+// MessageId
+//
+//	@Description: 获取以编号注册的消息编号，可作为路由的编号解析函数
+//	@receiver codec
+//	@param msg
+//	@return uint32
+//	@return bool
+func (codec *JsonCodec) MessageId(msg any) (uint32, bool) {
+	if msg == nil {
+		return 0, false
+	}
+	jType, ok := codec.types[reflect.TypeOf(msg)]
+	if !ok || jType.named {
+		return 0, false
+	}
+	return jType.id, true
+}
+
+func (codec *JsonCodec) Encode(msg any) ([]byte, error) {
+	if msg == nil {
+		return nil, pnet.ErrNilMessage
+	}
+	jType, ok := codec.types[reflect.TypeOf(msg)]
+	if !ok {
+		return nil, ErrUnregisteredMessage
+	}
+	envelope := jsonEnvelope{D: msg}
+	if jType.named {
+		envelope.T = jType.name
+	} else {
+		envelope.T = jType.id
+	}
+	out, err := json.Marshal(&envelope)
+	if err != nil {
+		return nil, err
+	}
+	if codec.MaxLength > 0 && len(out) > codec.MaxLength {
+		return nil, pnet.ErrMessageTooLarge
+	}
+	return out, nil
+}
+
+func (codec *JsonCodec) Decode(in []byte) (any, error) {
+	if codec.MaxLength > 0 && len(in) > codec.MaxLength {
+		return nil, pnet.ErrMessageTooLarge
+	}
+	var envelope rawJsonEnvelope
+	if err := json.Unmarshal(in, &envelope); err != nil {
+		return nil, err
+	}
+	factory, err := codec.factory(envelope.T)
+	if err != nil {
+		return nil, err
+	}
+	msg := factory()
+	if codec.Strict {
+		err = json.UnmarshalStrict(envelope.D, msg)
+	} else {
+		err = json.Unmarshal(envelope.D, msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// factory
+//
+//	@Description: 根据信封类型查找构造函数
+//	@receiver codec
+//	@param rawType
+//	@return func() any
+//	@return error
+func (codec *JsonCodec) factory(rawType json.RawMessage) (func() any, error) {
+	rawType = bytes.TrimSpace(rawType)
+	if len(rawType) <= 0 {
+		return nil, fmt.Errorf("%w: less type", ErrUnregisteredMessage)
+	}
+	var factory func() any
+	if rawType[0] == '"' {
+		var name string
+		if err := json.Unmarshal(rawType, &name); err != nil {
+			return nil, err
+		}
+		factory = codec.nameFactories[name]
+	} else {
+		id, err := strconv.ParseUint(string(rawType), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		factory = codec.idFactories[uint32(id)]
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("%w: type(%s)", ErrUnregisteredMessage, rawType)
+	}
+	return factory, nil
+}
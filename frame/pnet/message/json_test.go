@@ -0,0 +1,49 @@
+package message
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/utils/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type jsonLogin struct {
+	Name string `json:"name"`
+}
+
+type jsonChat struct {
+	Text string `json:"text"`
+}
+
+func TestJsonCodec(t *testing.T) {
+	should := require.New(t)
+	codec := NewJsonCodec(WithJsonStrict(true), WithJsonMaxLength(64))
+	should.Nil(codec.RegisterMessage("login", func() any { return &jsonLogin{} }))
+	should.Nil(codec.RegisterMessageId(2, func() any { return &jsonChat{} }))
+	should.NotNil(codec.RegisterMessageId(3, func() any { return &jsonChat{} }))
+
+	data, err := codec.Encode(&jsonLogin{Name: "meow"})
+	should.Nil(err)
+	should.Equal(`{"t":"login","d":{"name":"meow"}}`, string(data))
+	msg, err := codec.Decode(data)
+	should.Nil(err)
+	should.Equal("meow", msg.(*jsonLogin).Name)
+
+	msg, err = codec.Decode([]byte(`{"t":2,"d":{"text":"hi"}}`))
+	should.Nil(err)
+	should.Equal("hi", msg.(*jsonChat).Text)
+	id, ok := codec.MessageId(msg)
+	should.True(ok)
+	should.Equal(uint32(2), id)
+
+	_, err = codec.Decode([]byte(`{"t":2,"d":{"text":"hi","extra":1}}`))
+	should.NotNil(err)
+	// 严格模式下缺少消息体
+	_, err = codec.Decode([]byte(`{"t":2}`))
+	should.True(errors.Is(err, json.ErrEmptyData))
+	_, err = codec.Decode([]byte(`{"t":"chat","d":{}}`))
+	should.True(errors.Is(err, ErrUnregisteredMessage))
+	_, err = codec.Encode(&jsonChat{Text: string(make([]byte, 64))})
+	should.True(errors.Is(err, pnet.ErrMessageTooLarge))
+}
@@ -0,0 +1,172 @@
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/meow-pad/persian/errdef"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	DefaultProtoIdByteOrder = binary.LittleEndian
+	DefaultProtoIdSize      = 2
+)
+
+var (
+	ErrUnregisteredMessage = errors.New("message is not registered")
+	ErrInvalidIdSize       = errors.New("invalid message id size")
+	ErrShortMessage        = errors.New("message is too short")
+)
+
+type ProtoOptions struct {
+	// 消息编号字节序
+	ByteOrder binary.ByteOrder
+	// 消息编号所占字节数，仅支持2或4
+	IdSize int
+}
+
+type ProtoOption func(*ProtoOptions)
+
+func WithProtoByteOrder(value binary.ByteOrder) ProtoOption {
+	return func(options *ProtoOptions) {
+		options.ByteOrder = value
+	}
+}
+
+func WithProtoIdSize(value int) ProtoOption {
+	return func(options *ProtoOptions) {
+		options.IdSize = value
+	}
+}
+
+func NewProtoCodec(opts ...ProtoOption) (*ProtoCodec, error) {
+	options := &ProtoOptions{
+		ByteOrder: DefaultProtoIdByteOrder,
+		IdSize:    DefaultProtoIdSize,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.ByteOrder == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	if options.IdSize != 2 && options.IdSize != 4 {
+		return nil, ErrInvalidIdSize
+	}
+	return &ProtoCodec{
+		ProtoOptions: options,
+		factories:    make(map[uint32]func() proto.Message),
+		ids:          make(map[protoreflect.FullName]uint32),
+	}, nil
+}
+
+// ProtoCodec
+//
+//	@Description: protobuf消息解析器
+//		消息注册不是协程安全的，需在服务启动前完成
+//
+// * 0          idSize
+// * +------------+-----------------------+
+// * | message id |     protobuf body     |
+// * +------------+-----------------------+
+type ProtoCodec struct {
+	*ProtoOptions
+
+	// 消息编号对应的构造函数
+	factories map[uint32]func() proto.Message
+	// 消息类型对应的编号
+	ids map[protoreflect.FullName]uint32
+}
+
+// RegisterMessage
+//
+//	@Description: 注册消息类型
+//	@receiver codec
+//	@param id 消息编号
+//	@param factory 消息构造函数
+//	@return error
+func (codec *ProtoCodec) RegisterMessage(id uint32, factory func() proto.Message) error {
+	if factory == nil {
+		return errdef.ErrInvalidParams
+	}
+	if codec.IdSize == 2 && id > 0xFFFF {
+		return fmt.Errorf("id(%d) is out of range", id)
+	}
+	if _, ok := codec.factories[id]; ok {
+		return fmt.Errorf("id(%d) is registered", id)
+	}
+	msg := factory()
+	if msg == nil {
+		return errdef.ErrNilValue
+	}
+	name := msg.ProtoReflect().Descriptor().FullName()
+	if oldId, ok := codec.ids[name]; ok {
+		return fmt.Errorf("message(%s) is registered with id(%d)", name, oldId)
+	}
+	codec.factories[id] = factory
+	codec.ids[name] = id
+	return nil
+}
+
+// MessageId
+//
+//	@Description: 获取消息对应的编号，可作为路由的编号解析函数
+//	@receiver codec
+//	@param msg
+//	@return uint32
+//	@return bool
+func (codec *ProtoCodec) MessageId(msg any) (uint32, bool) {
+	pMsg, ok := msg.(proto.Message)
+	if !ok || pMsg == nil {
+		return 0, false
+	}
+	id, ok := codec.ids[pMsg.ProtoReflect().Descriptor().FullName()]
+	return id, ok
+}
+
+func (codec *ProtoCodec) Encode(msg any) ([]byte, error) {
+	pMsg, ok := msg.(proto.Message)
+	if !ok || pMsg == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	id, ok := codec.ids[pMsg.ProtoReflect().Descriptor().FullName()]
+	if !ok {
+		return nil, ErrUnregisteredMessage
+	}
+	out := make([]byte, codec.IdSize, codec.IdSize+proto.Size(pMsg))
+	codec.putId(out, id)
+	return proto.MarshalOptions{}.MarshalAppend(out, pMsg)
+}
+
+func (codec *ProtoCodec) Decode(in []byte) (any, error) {
+	if len(in) < codec.IdSize {
+		return nil, ErrShortMessage
+	}
+	id := codec.getId(in)
+	factory := codec.factories[id]
+	if factory == nil {
+		return nil, fmt.Errorf("%w: id(%d)", ErrUnregisteredMessage, id)
+	}
+	msg := factory()
+	if err := proto.Unmarshal(in[codec.IdSize:], msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (codec *ProtoCodec) putId(out []byte, id uint32) {
+	if codec.IdSize == 2 {
+		codec.ByteOrder.PutUint16(out, uint16(id))
+	} else {
+		codec.ByteOrder.PutUint32(out, id)
+	}
+}
+
+func (codec *ProtoCodec) getId(in []byte) uint32 {
+	if codec.IdSize == 2 {
+		return uint32(codec.ByteOrder.Uint16(in))
+	}
+	return codec.ByteOrder.Uint32(in)
+}
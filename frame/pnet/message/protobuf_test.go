@@ -0,0 +1,49 @@
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func TestProtoCodec(t *testing.T) {
+	should := require.New(t)
+	codec, err := NewProtoCodec(WithProtoIdSize(4), WithProtoByteOrder(binary.BigEndian))
+	should.Nil(err)
+	should.Nil(codec.RegisterMessage(1, func() proto.Message { return &wrapperspb.StringValue{} }))
+	should.Nil(codec.RegisterMessage(100000, func() proto.Message { return &wrapperspb.Int64Value{} }))
+	should.NotNil(codec.RegisterMessage(2, func() proto.Message { return &wrapperspb.StringValue{} }))
+
+	data, err := codec.Encode(wrapperspb.String("meow"))
+	should.Nil(err)
+	should.Equal(uint32(1), binary.BigEndian.Uint32(data))
+	msg, err := codec.Decode(data)
+	should.Nil(err)
+	should.Equal("meow", msg.(*wrapperspb.StringValue).GetValue())
+
+	data, err = codec.Encode(wrapperspb.Int64(10086))
+	should.Nil(err)
+	msg, err = codec.Decode(data)
+	should.Nil(err)
+	should.Equal(int64(10086), msg.(*wrapperspb.Int64Value).GetValue())
+	id, ok := codec.MessageId(msg)
+	should.True(ok)
+	should.Equal(uint32(100000), id)
+
+	_, err = codec.Encode(wrapperspb.Bool(true))
+	should.True(errors.Is(err, ErrUnregisteredMessage))
+	_, err = codec.Decode([]byte{0})
+	should.True(errors.Is(err, ErrShortMessage))
+}
+
+func TestProtoCodec_IdSize(t *testing.T) {
+	should := require.New(t)
+	_, err := NewProtoCodec(WithProtoIdSize(3))
+	should.True(errors.Is(err, ErrInvalidIdSize))
+	codec, err := NewProtoCodec()
+	should.Nil(err)
+	should.NotNil(codec.RegisterMessage(0x10000, func() proto.Message { return &wrapperspb.StringValue{} }))
+}
@@ -0,0 +1,85 @@
+package message
+
+import (
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/pnet"
+)
+
+// TypedCodec
+//
+//	@Description: 带类型的消息内容解析器
+//		In 为解码得到的接收消息类型，Out 为编码的发送消息类型
+type TypedCodec[In, Out any] interface {
+	// Encode
+	//	@Description: 编码指定消息
+	//	@param msg 消息对象
+	//	@return []byte 消息编码对象
+	//	@return error
+	//
+	Encode(msg Out) ([]byte, error)
+
+	// Decode
+	//	@Description: 解码消息
+	//	@param in 输入字节数据
+	//	@return In 消息对象
+	//	@return error
+	//
+	Decode(in []byte) (In, error)
+}
+
+// NewCodecAdapter
+//
+//	@Description: 将带类型的解析器适配为 Codec
+//	@param typed 带类型的解析器
+//	@return Codec 编码非 Out 类型的消息时返回 errdef.ErrInvalidParams
+func NewCodecAdapter[In, Out any](typed TypedCodec[In, Out]) Codec {
+	return &codecAdapter[In, Out]{typed: typed}
+}
+
+type codecAdapter[In, Out any] struct {
+	typed TypedCodec[In, Out]
+}
+
+func (adapter *codecAdapter[In, Out]) Encode(msg any) ([]byte, error) {
+	out, ok := msg.(Out)
+	if !ok {
+		return nil, errdef.ErrInvalidParams
+	}
+	return adapter.typed.Encode(out)
+}
+
+func (adapter *codecAdapter[In, Out]) Decode(in []byte) (any, error) {
+	return adapter.typed.Decode(in)
+}
+
+// AsTypedCodec
+//
+//	@Description: 将已有的 Codec 包装为带类型的解析器
+//	@param codec 原解析器
+//	@return TypedCodec[In, Out] 解码结果不是 In 类型时返回 pnet.ErrMismatchedMessage
+func AsTypedCodec[In, Out any](codec Codec) TypedCodec[In, Out] {
+	if adapter, ok := codec.(*codecAdapter[In, Out]); ok {
+		return adapter.typed
+	}
+	return &typedCodec[In, Out]{codec: codec}
+}
+
+type typedCodec[In, Out any] struct {
+	codec Codec
+}
+
+func (typed *typedCodec[In, Out]) Encode(msg Out) ([]byte, error) {
+	return typed.codec.Encode(msg)
+}
+
+func (typed *typedCodec[In, Out]) Decode(in []byte) (msg In, err error) {
+	var decoded any
+	if decoded, err = typed.codec.Decode(in); err != nil {
+		return
+	}
+	var ok bool
+	if msg, ok = decoded.(In); !ok {
+		err = pnet.ErrMismatchedMessage
+	}
+	return
+}
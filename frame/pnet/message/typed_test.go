@@ -0,0 +1,37 @@
+package message
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTypedCodec(t *testing.T) {
+	should := require.New(t)
+	jsonCodec := NewJsonCodec()
+	should.Nil(jsonCodec.RegisterMessage("login", func() any { return &jsonLogin{} }))
+	should.Nil(jsonCodec.RegisterMessage("chat", func() any { return &jsonChat{} }))
+
+	typed := AsTypedCodec[*jsonLogin, *jsonChat](jsonCodec)
+	data, err := typed.Encode(&jsonChat{Text: "hi"})
+	should.Nil(err)
+	should.Equal(`{"t":"chat","d":{"text":"hi"}}`, string(data))
+	login, err := typed.Decode([]byte(`{"t":"login","d":{"name":"meow"}}`))
+	should.Nil(err)
+	should.Equal("meow", login.Name)
+	_, err = typed.Decode(data)
+	should.True(errors.Is(err, pnet.ErrMismatchedMessage))
+
+	adapter := NewCodecAdapter(typed)
+	_, err = adapter.Encode(&jsonLogin{Name: "meow"})
+	should.True(errors.Is(err, errdef.ErrInvalidParams))
+	data, err = adapter.Encode(&jsonChat{Text: "hi"})
+	should.Nil(err)
+	msg, err := adapter.Decode([]byte(`{"t":"login","d":{"name":"meow"}}`))
+	should.Nil(err)
+	should.Equal("meow", msg.(*jsonLogin).Name)
+	// 适配器还原为原带类型的解析器
+	should.Equal(typed, AsTypedCodec[*jsonLogin, *jsonChat](adapter))
+}
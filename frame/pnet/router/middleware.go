@@ -0,0 +1,87 @@
+package router
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/utils/coding"
+	"time"
+)
+
+var (
+	ErrUnauthorized = errors.New("session is not registered")
+)
+
+// Recover
+//
+//	@Description: 捕捉处理函数中的panic并转为错误
+//	@return Middleware
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			return coding.SafeRunAnyParams(func(_ ...any) error {
+				return next(req)
+			})
+		}
+	}
+}
+
+// Logging
+//
+//	@Description: 以Debug级别记录消息处理
+//	@return Middleware
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			begin := time.Now()
+			err := next(req)
+			plog.Debug("handle message:",
+				pfield.Uint64("session", req.Session.Id()),
+				pfield.Uint32("id", req.Id),
+				pfield.Duration("cost", time.Since(begin)),
+				pfield.Error(err))
+			return err
+		}
+	}
+}
+
+// Auth
+//
+//	@Description: 仅允许已注册会话的消息通过
+//	@param exempts 无需注册即可处理的消息编号
+//	@return Middleware
+func Auth(exempts ...uint32) Middleware {
+	exemptSet := make(map[uint32]struct{}, len(exempts))
+	for _, id := range exempts {
+		exemptSet[id] = struct{}{}
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			if req.Session.Context() == nil {
+				if _, ok := exemptSet[req.Id]; !ok || !req.Identified {
+					return ErrUnauthorized
+				}
+			}
+			return next(req)
+		}
+	}
+}
+
+// Metrics
+//
+//	@Description: 统计消息处理结果
+//	@param observer 观察函数
+//	@return Middleware
+func Metrics(observer func(req *Request, cost time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		if observer == nil {
+			return next
+		}
+		return func(req *Request) error {
+			begin := time.Now()
+			err := next(req)
+			observer(req, time.Since(begin), err)
+			return err
+		}
+	}
+}
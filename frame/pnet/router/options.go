@@ -0,0 +1,40 @@
+package router
+
+import "github.com/meow-pad/persian/frame/pnet/tcp/session"
+
+func newOptions(opts ...Option) *Options {
+	options := &Options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+type Options struct {
+	// 消息编号解析函数，未设置或解析失败时按消息类型查找
+	IdResolver func(msg any) (id uint32, ok bool)
+	// 未知消息的处理函数
+	Fallback HandlerFunc
+	// 会话事件的后续监听器（开启、关闭、发送）
+	Listener session.Listener
+}
+
+type Option func(*Options)
+
+func WithIdResolver(resolver func(msg any) (id uint32, ok bool)) Option {
+	return func(options *Options) {
+		options.IdResolver = resolver
+	}
+}
+
+func WithFallback(handler HandlerFunc) Option {
+	return func(options *Options) {
+		options.Fallback = handler
+	}
+}
+
+func WithListener(listener session.Listener) Option {
+	return func(options *Options) {
+		options.Listener = listener
+	}
+}
@@ -0,0 +1,230 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"reflect"
+)
+
+var (
+	ErrUnknownMessage    = errors.New("unknown message")
+	ErrRegisteredHandler = errors.New("handler is registered")
+	ErrInvalidMessage    = errors.New("invalid message type")
+)
+
+// Identified
+//
+//	@Description: 自带编号的消息
+type Identified interface {
+	MessageId() uint32
+}
+
+// Request
+//
+//	@Description: 待分发的请求
+type Request struct {
+	// 来源会话
+	Session session.Session
+	// 消息编号
+	Id uint32
+	// 消息编号是否有效
+	Identified bool
+	// 消息体
+	Message any
+	// 消息长度，批量接收时为所有消息的总长度
+	Length int
+}
+
+// HandlerFunc
+//
+//	@Description: 消息处理函数
+type HandlerFunc func(req *Request) error
+
+// Middleware
+//
+//	@Description: 中间件，包装后续处理函数
+type Middleware func(next HandlerFunc) HandlerFunc
+
+func NewRouter(opts ...Option) *Router {
+	router := &Router{
+		Options:  newOptions(opts...),
+		handlers: make(map[uint32]HandlerFunc),
+		typeIds:  make(map[reflect.Type]uint32),
+	}
+	if router.Listener == nil {
+		router.Listener = &session.EmptyListener{}
+	}
+	router.entry = router.route
+	return router
+}
+
+// Router
+//
+//	@Description: 按消息编号分发的会话监听器
+//		处理函数与中间件的注册不是协程安全的，需在服务启动前完成
+type Router struct {
+	*Options
+
+	// 消息处理函数
+	handlers map[uint32]HandlerFunc
+	// 消息类型对应的编号
+	typeIds map[reflect.Type]uint32
+	// 中间件
+	middlewares []Middleware
+	// 经过中间件包装后的入口
+	entry HandlerFunc
+}
+
+// Use
+//
+//	@Description: 添加中间件，先添加的中间件先执行
+//	@receiver router
+//	@param middlewares
+func (router *Router) Use(middlewares ...Middleware) {
+	router.middlewares = append(router.middlewares, middlewares...)
+	entry := router.route
+	for i := len(router.middlewares) - 1; i >= 0; i-- {
+		entry = router.middlewares[i](entry)
+	}
+	router.entry = entry
+}
+
+// Register
+//
+//	@Description: 注册消息处理函数
+//	@receiver router
+//	@param id 消息编号
+//	@param handler 处理函数
+//	@return error
+func (router *Router) Register(id uint32, handler HandlerFunc) error {
+	if handler == nil {
+		return errdef.ErrInvalidParams
+	}
+	if _, ok := router.handlers[id]; ok {
+		return ErrRegisteredHandler
+	}
+	router.handlers[id] = handler
+	return nil
+}
+
+// Handle
+//
+//	@Description: 注册类型化的消息处理函数，消息类型为 *T
+//	@param router
+//	@param id 消息编号
+//	@param handler 处理函数
+//	@return error
+func Handle[T any](router *Router, id uint32, handler func(sess session.Session, msg *T) error) error {
+	if router == nil || handler == nil {
+		return errdef.ErrInvalidParams
+	}
+	msgType := reflect.TypeOf((*T)(nil))
+	if oldId, ok := router.typeIds[msgType]; ok && oldId != id {
+		return fmt.Errorf("type(%s) is registered with id(%d)", msgType.String(), oldId)
+	}
+	err := router.Register(id, func(req *Request) error {
+		msg, ok := req.Message.(*T)
+		if !ok {
+			return fmt.Errorf("%w: id(%d) need %s", ErrInvalidMessage, req.Id, msgType.String())
+		}
+		return handler(req.Session, msg)
+	})
+	if err != nil {
+		return err
+	}
+	router.typeIds[msgType] = id
+	return nil
+}
+
+// Dispatch
+//
+//	@Description: 分发一条消息
+//	@receiver router
+//	@param sess 来源会话
+//	@param msg 消息体
+//	@param msgLen 消息长度
+//	@return error
+func (router *Router) Dispatch(sess session.Session, msg any, msgLen int) error {
+	req := &Request{Session: sess, Message: msg, Length: msgLen}
+	req.Id, req.Identified = router.resolveId(msg)
+	return router.entry(req)
+}
+
+// resolveId
+//
+//	@Description: 解析消息编号
+//	@receiver router
+//	@param msg
+//	@return id
+//	@return ok
+func (router *Router) resolveId(msg any) (id uint32, ok bool) {
+	if router.IdResolver != nil {
+		if id, ok = router.IdResolver(msg); ok {
+			return
+		}
+	}
+	if identified, isIdentified := msg.(Identified); isIdentified {
+		return identified.MessageId(), true
+	}
+	if msg != nil {
+		id, ok = router.typeIds[reflect.TypeOf(msg)]
+	}
+	return
+}
+
+// route
+//
+//	@Description: 查找处理函数并执行
+//	@receiver router
+//	@param req
+//	@return error
+func (router *Router) route(req *Request) error {
+	if req.Identified {
+		if handler := router.handlers[req.Id]; handler != nil {
+			return handler(req)
+		}
+	}
+	if router.Fallback != nil {
+		return router.Fallback(req)
+	}
+	return ErrUnknownMessage
+}
+
+func (router *Router) OnOpened(sess session.Session) {
+	router.Listener.OnOpened(sess)
+}
+
+func (router *Router) OnClosed(sess session.Session, reason *session.CloseReason) {
+	router.Listener.OnClosed(sess, reason)
+}
+
+func (router *Router) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	if err = router.Dispatch(sess, msg, msgLen); err != nil {
+		plog.Error("dispatch message error:",
+			pfield.Uint64("session", sess.Id()), pfield.Error(err))
+	}
+	return
+}
+
+func (router *Router) OnReceiveMulti(sess session.Session, msgArr []any, totalLen int) (err error) {
+	for _, msg := range msgArr {
+		if dErr := router.Dispatch(sess, msg, totalLen); dErr != nil {
+			plog.Error("dispatch message error:",
+				pfield.Uint64("session", sess.Id()), pfield.Error(dErr))
+			err = dErr
+		}
+	}
+	return
+}
+
+func (router *Router) OnSend(sess session.Session, msg any, msgLen int) (err error) {
+	return router.Listener.OnSend(sess, msg, msgLen)
+}
+
+func (router *Router) OnSendMulti(sess session.Session, msgArr []any, totalLen int) (err error) {
+	return router.Listener.OnSendMulti(sess, msgArr, totalLen)
+}
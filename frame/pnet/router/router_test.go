@@ -0,0 +1,104 @@
+package router
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testSession struct {
+	session.BaseSession
+}
+
+func (sess *testSession) Connection() session.Conn {
+	return nil
+}
+
+func (sess *testSession) Close() error {
+	return nil
+}
+
+func (sess *testSession) IsClosed() bool {
+	return false
+}
+
+func (sess *testSession) SendMessage(_ any) {
+}
+
+func (sess *testSession) SendMessages(_ ...any) {
+}
+
+type loginReq struct {
+	Name string
+}
+
+type chatReq struct {
+	Text string
+}
+
+func (req *chatReq) MessageId() uint32 {
+	return 2
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	should := require.New(t)
+	router := NewRouter()
+	var order []string
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			order = append(order, "first")
+			return next(req)
+		}
+	}, func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			order = append(order, "second")
+			return next(req)
+		}
+	})
+	var login, chat string
+	should.Nil(Handle[loginReq](router, 1, func(sess session.Session, msg *loginReq) error {
+		login = msg.Name
+		return nil
+	}))
+	should.Nil(Handle[chatReq](router, 2, func(sess session.Session, msg *chatReq) error {
+		chat = msg.Text
+		return nil
+	}))
+	should.True(errors.Is(router.Register(1, func(req *Request) error { return nil }), ErrRegisteredHandler))
+
+	sess := &testSession{}
+	should.Nil(router.OnReceive(sess, &loginReq{Name: "meow"}, 0))
+	should.Equal("meow", login)
+	should.Equal([]string{"first", "second"}, order)
+	should.Nil(router.OnReceiveMulti(sess, []any{&chatReq{Text: "hi"}}, 0))
+	should.Equal("hi", chat)
+	should.True(errors.Is(router.OnReceive(sess, "unknown", 0), ErrUnknownMessage))
+}
+
+func TestRouter_Middleware(t *testing.T) {
+	should := require.New(t)
+	var fallback any
+	router := NewRouter(WithFallback(func(req *Request) error {
+		fallback = req.Message
+		return nil
+	}))
+	router.Use(Recover(), Auth(1))
+	should.Nil(Handle[loginReq](router, 1, func(sess session.Session, msg *loginReq) error {
+		panic("boom")
+	}))
+	should.Nil(Handle[chatReq](router, 2, func(sess session.Session, msg *chatReq) error {
+		return nil
+	}))
+
+	sess := &testSession{}
+	should.NotNil(router.Dispatch(sess, &loginReq{}, 0))
+	should.True(errors.Is(router.Dispatch(sess, &chatReq{}, 0), ErrUnauthorized))
+	should.True(errors.Is(router.Dispatch(sess, "unknown", 0), ErrUnauthorized))
+	ctx := &session.BaseContext{}
+	ctx.Init(10086)
+	should.Nil(sess.Register(ctx))
+	should.Nil(router.Dispatch(sess, &chatReq{}, 0))
+	should.Nil(router.Dispatch(sess, "unknown", 0))
+	should.Equal("unknown", fallback)
+}
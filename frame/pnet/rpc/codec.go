@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/pnet/message"
+)
+
+// Kind
+//
+//	@Description: 帧类型
+type Kind uint8
+
+const (
+	// KindNotify 单向通知
+	KindNotify Kind = iota
+	// KindRequest 请求
+	KindRequest
+	// KindResponse 响应
+	KindResponse
+	// KindError 错误响应，消息体为错误描述
+	KindError
+)
+
+const (
+	kindSize = 1
+	seqSize  = 4
+)
+
+var (
+	ErrInvalidFrame = errors.New("invalid rpc frame")
+)
+
+// Frame
+//
+//	@Description: rpc帧
+type Frame struct {
+	Kind Kind
+	// 序列号，通知帧为0
+	Seq uint32
+	// 消息体，错误帧为错误描述
+	Message any
+	// 预先编码的数据
+	encoded []byte
+}
+
+func NewCodec(msgCodec message.Codec, byteOrder binary.ByteOrder) (*Codec, error) {
+	if msgCodec == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	if byteOrder == nil {
+		byteOrder = binary.LittleEndian
+	}
+	return &Codec{msgCodec: msgCodec, byteOrder: byteOrder}, nil
+}
+
+// Codec
+//
+//	@Description: 在消息体前写入帧头的消息解析器，非 *Frame 消息按通知帧编码
+//
+// * 0      1                 5
+// * +------+-----------------+-----------------------+
+// * | kind | seq(非通知帧)    |      message body     |
+// * +------+-----------------+-----------------------+
+type Codec struct {
+	msgCodec  message.Codec
+	byteOrder binary.ByteOrder
+}
+
+func (codec *Codec) Encode(msg any) ([]byte, error) {
+	frame, ok := msg.(*Frame)
+	if !ok {
+		frame = &Frame{Kind: KindNotify, Message: msg}
+	} else if frame.encoded != nil {
+		return frame.encoded, nil
+	}
+	var (
+		body []byte
+		err  error
+	)
+	if frame.Kind == KindError {
+		text, _ := frame.Message.(string)
+		body = []byte(text)
+	} else {
+		body, err = codec.msgCodec.Encode(frame.Message)
+		if err != nil {
+			return nil, err
+		}
+	}
+	headSize := kindSize
+	if frame.Kind != KindNotify {
+		headSize += seqSize
+	}
+	out := make([]byte, headSize, headSize+len(body))
+	out[0] = byte(frame.Kind)
+	if frame.Kind != KindNotify {
+		codec.byteOrder.PutUint32(out[kindSize:], frame.Seq)
+	}
+	return append(out, body...), nil
+}
+
+func (codec *Codec) Decode(in []byte) (any, error) {
+	if len(in) < kindSize {
+		return nil, ErrInvalidFrame
+	}
+	frame := &Frame{Kind: Kind(in[0])}
+	if frame.Kind > KindError {
+		return nil, ErrInvalidFrame
+	}
+	body := in[kindSize:]
+	if frame.Kind != KindNotify {
+		if len(body) < seqSize {
+			return nil, ErrInvalidFrame
+		}
+		frame.Seq = codec.byteOrder.Uint32(body)
+		body = body[seqSize:]
+	}
+	if frame.Kind == KindError {
+		frame.Message = string(body)
+		return frame, nil
+	}
+	msg, err := codec.msgCodec.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	frame.Message = msg
+	return frame, nil
+}
@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"time"
+)
+
+const (
+	DefaultCallTimeout = 10 * time.Second
+)
+
+func newOptions(opts ...Option) *Options {
+	options := &Options{
+		CallTimeout: DefaultCallTimeout,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+type Options struct {
+	// 上下文未设置截止时间时的调用超时
+	CallTimeout time.Duration
+	// 请求处理函数，返回的错误将以错误帧响应，响应与错误均为nil时不响应
+	Handler func(sess session.Session, req any) (resp any, err error)
+}
+
+type Option func(*Options)
+
+func WithCallTimeout(value time.Duration) Option {
+	return func(options *Options) {
+		options.CallTimeout = value
+	}
+}
+
+func WithHandler(handler func(sess session.Session, req any) (resp any, err error)) Option {
+	return func(options *Options) {
+		options.Handler = handler
+	}
+}
@@ -0,0 +1,313 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/utils/timewheel"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrCallTimeout = errors.New("rpc call timeout")
+)
+
+// RemoteError
+//
+//	@Description: 对端处理请求返回的错误
+type RemoteError struct {
+	Text string
+}
+
+func (err *RemoteError) Error() string {
+	return err.Text
+}
+
+// call
+//
+//	@Description: 等待响应的调用
+type call struct {
+	done chan struct{}
+	resp any
+	err  error
+	task *timewheel.Task
+}
+
+// NewRpc
+//
+//	@Description: 创建rpc层
+//	@param timeWheel 调用超时使用的时间轮
+//	@param codec 会话所使用的帧编解码器，请求在调用时预先编码
+//	@param listener 业务监听器
+//	@param opts
+//	@return *Rpc
+//	@return error
+func NewRpc(timeWheel *timewheel.TimeWheel, codec *Codec, listener session.Listener, opts ...Option) (*Rpc, error) {
+	if timeWheel == nil || codec == nil || listener == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	return &Rpc{
+		Options:   newOptions(opts...),
+		timeWheel: timeWheel,
+		codec:     codec,
+		listener:  listener,
+		pending:   make(map[session.Session]map[uint32]*call),
+	}, nil
+}
+
+// Rpc
+//
+//	@Description: 基于会话的请求/响应层，作为监听器包装业务监听器
+//		会话需使用 Codec 编解码消息
+type Rpc struct {
+	*Options
+
+	timeWheel *timewheel.TimeWheel
+	codec     *Codec
+	listener  session.Listener
+	seq       atomic.Uint32
+
+	mutex   sync.Mutex
+	pending map[session.Session]map[uint32]*call
+}
+
+// Call
+//
+//	@Description: 发送请求并等待响应
+//		会阻塞至响应到达，不可在未使用分发器的监听器回调中调用
+//	@receiver rpc
+//	@param ctx 未设置截止时间时使用 CallTimeout
+//	@param sess 会话
+//	@param req 请求消息
+//	@return resp 响应消息
+//	@return err 请求编码失败或会话已关闭时立即返回
+func (rpc *Rpc) Call(ctx context.Context, sess session.Session, req any) (resp any, err error) {
+	if sess == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	if sess.IsClosed() {
+		return nil, pnet.ErrClosedConn
+	}
+	seq := rpc.nextSeq()
+	frame := &Frame{Kind: KindRequest, Seq: seq, Message: req}
+	if frame.encoded, err = rpc.codec.Encode(frame); err != nil {
+		return nil, err
+	}
+	c := &call{done: make(chan struct{})}
+	timeout := rpc.CallTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	rpc.mutex.Lock()
+	// 会话先转为关闭状态再通知关闭，持锁检查可避免关闭通知已结束所有调用后再登记
+	if sess.IsClosed() {
+		rpc.mutex.Unlock()
+		return nil, pnet.ErrClosedConn
+	}
+	calls := rpc.pending[sess]
+	if calls == nil {
+		calls = make(map[uint32]*call)
+		rpc.pending[sess] = calls
+	}
+	calls[seq] = c
+	c.task = rpc.timeWheel.Add(timeout, func() {
+		rpc.complete(sess, seq, nil, ErrCallTimeout)
+	})
+	rpc.mutex.Unlock()
+
+	sess.SendMessage(frame)
+	select {
+	case <-c.done:
+		return c.resp, c.err
+	case <-ctx.Done():
+		rpc.complete(sess, seq, nil, ctx.Err())
+		<-c.done
+		return c.resp, c.err
+	}
+}
+
+// Notify
+//
+//	@Description: 发送单向通知
+//	@receiver rpc
+//	@param sess
+//	@param msg
+func (rpc *Rpc) Notify(sess session.Session, msg any) {
+	sess.SendMessage(&Frame{Kind: KindNotify, Message: msg})
+}
+
+func (rpc *Rpc) nextSeq() uint32 {
+	for {
+		if seq := rpc.seq.Add(1); seq != 0 {
+			return seq
+		}
+	}
+}
+
+// complete
+//
+//	@Description: 完成调用，重复完成时忽略
+//	@receiver rpc
+//	@param sess
+//	@param seq
+//	@param resp
+//	@param err
+func (rpc *Rpc) complete(sess session.Session, seq uint32, resp any, err error) bool {
+	rpc.mutex.Lock()
+	calls := rpc.pending[sess]
+	c := calls[seq]
+	if c == nil {
+		rpc.mutex.Unlock()
+		return false
+	}
+	delete(calls, seq)
+	if len(calls) <= 0 {
+		delete(rpc.pending, sess)
+	}
+	rpc.mutex.Unlock()
+	rpc.finish(c, resp, err)
+	return true
+}
+
+func (rpc *Rpc) finish(c *call, resp any, err error) {
+	if c.task != nil {
+		if rErr := rpc.timeWheel.Remove(c.task); rErr != nil {
+			plog.Error("remove call timeout task error:", pfield.Error(rErr))
+		}
+	}
+	c.resp, c.err = resp, err
+	close(c.done)
+}
+
+// failAll
+//
+//	@Description: 以指定错误结束会话的所有调用
+//	@receiver rpc
+//	@param sess
+//	@param err
+func (rpc *Rpc) failAll(sess session.Session, err error) {
+	rpc.mutex.Lock()
+	calls := rpc.pending[sess]
+	delete(rpc.pending, sess)
+	rpc.mutex.Unlock()
+	for _, c := range calls {
+		rpc.finish(c, nil, err)
+	}
+}
+
+// handleFrame
+//
+//	@Description: 处理请求与响应帧
+//	@receiver rpc
+//	@param sess
+//	@param frame
+//	@return bool 是否已处理，通知帧返回false
+func (rpc *Rpc) handleFrame(sess session.Session, frame *Frame) bool {
+	switch frame.Kind {
+	case KindRequest:
+		rpc.handleRequest(sess, frame)
+	case KindResponse:
+		if !rpc.complete(sess, frame.Seq, frame.Message, nil) {
+			plog.Debug("discard response without call", pfield.Uint32("seq", frame.Seq))
+		}
+	case KindError:
+		text, _ := frame.Message.(string)
+		if !rpc.complete(sess, frame.Seq, nil, &RemoteError{Text: text}) {
+			plog.Debug("discard error response without call", pfield.Uint32("seq", frame.Seq))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (rpc *Rpc) handleRequest(sess session.Session, frame *Frame) {
+	if rpc.Handler == nil {
+		sess.SendMessage(&Frame{Kind: KindError, Seq: frame.Seq, Message: errdef.ErrNotImplemented.Error()})
+		return
+	}
+	resp, err := rpc.Handler(sess, frame.Message)
+	if err != nil {
+		sess.SendMessage(&Frame{Kind: KindError, Seq: frame.Seq, Message: err.Error()})
+		return
+	}
+	if resp == nil {
+		// 不响应，由调用方超时
+		return
+	}
+	sess.SendMessage(&Frame{Kind: KindResponse, Seq: frame.Seq, Message: resp})
+}
+
+func (rpc *Rpc) OnOpened(sess session.Session) {
+	rpc.listener.OnOpened(sess)
+}
+
+func (rpc *Rpc) OnClosed(sess session.Session, reason *session.CloseReason) {
+	rpc.failAll(sess, pnet.ErrClosedConn)
+	rpc.listener.OnClosed(sess, reason)
+}
+
+func (rpc *Rpc) OnReceive(sess session.Session, msg any, msgLen int) error {
+	frame, ok := msg.(*Frame)
+	if !ok {
+		return rpc.listener.OnReceive(sess, msg, msgLen)
+	}
+	if rpc.handleFrame(sess, frame) {
+		return nil
+	}
+	return rpc.listener.OnReceive(sess, frame.Message, msgLen)
+}
+
+func (rpc *Rpc) OnReceiveMulti(sess session.Session, msgArr []any, totalLen int) error {
+	notifies := make([]any, 0, len(msgArr))
+	for _, msg := range msgArr {
+		if frame, ok := msg.(*Frame); ok {
+			if !rpc.handleFrame(sess, frame) {
+				notifies = append(notifies, frame.Message)
+			}
+		} else {
+			notifies = append(notifies, msg)
+		}
+	}
+	switch len(notifies) {
+	case 0:
+		return nil
+	case 1:
+		return rpc.listener.OnReceive(sess, notifies[0], totalLen)
+	default:
+		return rpc.listener.OnReceiveMulti(sess, notifies, totalLen)
+	}
+}
+
+func (rpc *Rpc) OnSend(sess session.Session, msg any, msgLen int) error {
+	if frame, ok := msg.(*Frame); ok {
+		if frame.Kind != KindNotify {
+			return nil
+		}
+		msg = frame.Message
+	}
+	return rpc.listener.OnSend(sess, msg, msgLen)
+}
+
+func (rpc *Rpc) OnSendMulti(sess session.Session, msgArr []any, totalLen int) error {
+	notifies := make([]any, 0, len(msgArr))
+	for _, msg := range msgArr {
+		if frame, ok := msg.(*Frame); ok {
+			if frame.Kind == KindNotify {
+				notifies = append(notifies, frame.Message)
+			}
+		} else {
+			notifies = append(notifies, msg)
+		}
+	}
+	if len(notifies) <= 0 {
+		return nil
+	}
+	return rpc.listener.OnSendMulti(sess, notifies, totalLen)
+}
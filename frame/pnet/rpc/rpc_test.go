@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/message"
+	"github.com/meow-pad/persian/frame/pnet/tcp/client"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	"github.com/meow-pad/persian/frame/pnet/tcp/server"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/utils/timewheel"
+	"github.com/panjf2000/gnet/v2"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newCodec(should *require.Assertions) (*Codec, codec.Codec) {
+	rpcCodec, err := NewCodec(&message.TextCodec{}, nil)
+	should.Nil(err)
+	c, err := codec.NewLengthFieldCodec(codec.WithMessageCodec[*codec.LengthOptions](rpcCodec))
+	should.Nil(err)
+	return rpcCodec, c
+}
+
+func TestRpc_Call(t *testing.T) {
+	should := require.New(t)
+	tw, err := timewheel.NewTimeWheel(10*time.Millisecond, 100)
+	should.Nil(err)
+	tw.Start()
+	defer tw.Stop()
+
+	svrFrameCodec, svrCodec := newCodec(should)
+	svrRpc, err := NewRpc(tw, svrFrameCodec, &session.EmptyListener{},
+		WithHandler(func(sess session.Session, req any) (any, error) {
+			switch req {
+			case "fail":
+				return nil, errors.New("bad request")
+			case "close":
+				return nil, sess.Close()
+			case "ignore":
+				return nil, nil
+			}
+			return "re:" + req.(string), nil
+		}))
+	should.Nil(err)
+	addr := "127.0.0.1:12090"
+	svr, err := server.NewServer("rpc-server", "tcp://"+addr, svrCodec, svrRpc,
+		server.WithGNetOption(gnet.WithReuseAddr(true)))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+
+	cliFrameCodec, cliCodec := newCodec(should)
+	cliRpc, err := NewRpc(tw, cliFrameCodec, &session.EmptyListener{}, WithCallTimeout(300*time.Millisecond))
+	should.Nil(err)
+	cli, err := client.NewClient(cliCodec, cliRpc)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+
+	resp, err := cliRpc.Call(context.Background(), cli, "hello")
+	should.Nil(err)
+	should.Equal("re:hello", resp)
+
+	_, err = cliRpc.Call(context.Background(), cli, "fail")
+	var remoteErr *RemoteError
+	should.True(errors.As(err, &remoteErr))
+	should.Equal("bad request", remoteErr.Text)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = cliRpc.Call(ctx, cli, "ignore")
+	should.True(errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCallTimeout))
+
+	// 编码失败时立即返回，无需等待超时
+	start := time.Now()
+	_, err = cliRpc.Call(context.Background(), cli, 1)
+	should.ErrorIs(err, errdef.ErrInvalidParams)
+	should.Less(time.Since(start), 100*time.Millisecond)
+
+	_, err = cliRpc.Call(context.Background(), cli, "close")
+	should.True(errors.Is(err, pnet.ErrClosedConn))
+	_, err = cliRpc.Call(context.Background(), cli, "hello")
+	should.ErrorIs(err, pnet.ErrClosedConn)
+}
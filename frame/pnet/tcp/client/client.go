@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/meow-pad/persian/errdef"
 	"github.com/meow-pad/persian/frame/plog"
@@ -10,7 +11,6 @@ import (
 	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/frame/pnet/utils"
-	"github.com/meow-pad/persian/utils/coding"
 	"net"
 	"sync/atomic"
 )
@@ -49,6 +49,12 @@ type Client struct {
 	conn   *Conn
 	loop   *eventLoop
 	connPT atomic.Pointer[Conn]
+	// 连接协议，为tcp或unix
+	network string
+	// 连接地址，unix socket为文件路径
+	address string
+	// 是否已主动关闭，主动关闭后不再重连
+	closing atomic.Bool
 }
 
 func (client *Client) init(codec codec.Codec, listener session.Listener, options *Options) error {
@@ -70,27 +76,47 @@ func (client *Client) init(codec codec.Codec, listener session.Listener, options
 //	@Description: 连接
 //	@receiver client
 //	@param ctx
-//	@param address 如：127.0.0.1:9999
+//	@param address 如：127.0.0.1:9999、tcp://127.0.0.1:9999 或 unix:///tmp/server.sock
 //	@return error
 func (client *Client) Dial(ctx context.Context, address string) error {
-	if !client.status.CompareAndSwap(StatusInitial, StatusConnecting) {
+	status := client.status.Load()
+	if status != StatusInitial && status != StatusClosed {
 		return ErrInvalidStatus
 	}
-	client.status.Store(StatusConnecting)
+	network, addr, err := utils.SplitAddress(address, utils.ProtoTCP, utils.ProtoUnix)
+	if err != nil {
+		return err
+	}
+	if !client.status.CompareAndSwap(status, StatusConnecting) {
+		return ErrInvalidStatus
+	}
+	client.network, client.address = network, addr
+	client.closing.Store(false)
+	return client.dial(ctx, status)
+}
+
+// dial
+//
+//	@Description: 连接已记录的地址，失败时恢复到原状态
+//	@receiver client
+//	@param ctx
+//	@param fromStatus 连接前的状态
+//	@return error
+func (client *Client) dial(ctx context.Context, fromStatus uint32) error {
 	var dialer net.Dialer
-	conn, err := dialer.DialContext(ctx, utils.ProtoTCP, address)
+	conn, err := dialer.DialContext(ctx, client.network, client.address)
 	if err != nil {
+		client.status.Store(fromStatus)
 		return err
 	}
-	tcpConn := coding.Cast[*net.TCPConn](conn)
-	if tcpConn == nil {
-		return errors.New("unknown network connection")
+	client.conn, err = NewConn(client, conn)
+	if err == nil && client.TLSConfig != nil {
+		err = client.conn.handshake(ctx, client.tlsConfig())
 	}
-	client.conn, err = NewConn(client, tcpConn)
 	if err != nil {
 		client.conn = nil
-		client.status.Store(StatusInitial)
-		if tErr := tcpConn.Close(); tErr != nil {
+		client.status.Store(fromStatus)
+		if tErr := conn.Close(); tErr != nil {
 			plog.Error("", pfield.Error(tErr))
 		}
 		return err
@@ -100,8 +126,8 @@ func (client *Client) Dial(ctx context.Context, address string) error {
 	if err = client.loop.start(client.conn); err != nil {
 		client.conn = nil
 		client.connPT.Store(nil)
-		client.status.Store(StatusInitial)
-		if tErr := tcpConn.Close(); tErr != nil {
+		client.status.Store(fromStatus)
+		if tErr := conn.Close(); tErr != nil {
 			plog.Error("", pfield.Error(tErr))
 		}
 		return err
@@ -109,6 +135,41 @@ func (client *Client) Dial(ctx context.Context, address string) error {
 	return nil
 }
 
+// tlsConfig
+//
+//	@Description: 获取TLS配置，未指定服务名时使用连接地址中的主机名
+//	@receiver client
+//	@return *tls.Config
+func (client *Client) tlsConfig() *tls.Config {
+	config := client.TLSConfig
+	if len(config.ServerName) > 0 || config.InsecureSkipVerify || client.network == utils.ProtoUnix {
+		// unix socket没有主机名，需在配置中指定服务名
+		return config
+	}
+	host, _, err := net.SplitHostPort(client.address)
+	if err != nil {
+		host = client.address
+	}
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
+
+// reconnect
+//
+//	@Description: 断线后按重连配置重新连接
+//	@receiver client
+//	@param reason 断线原因
+func (client *Client) reconnect(reason error) {
+	client.Reconnect.Reconnect(client.Name, reason, client.Attributes(), client.closing.Load,
+		func(ctx context.Context) (bool, error) {
+			if !client.status.CompareAndSwap(StatusClosed, StatusConnecting) {
+				return false, nil
+			}
+			return true, client.dial(ctx, StatusClosed)
+		}, client.Close)
+}
+
 func (client *Client) Status() uint32 {
 	return client.status.Load()
 }
@@ -118,6 +179,7 @@ func (client *Client) Connection() session.Conn {
 }
 
 func (client *Client) Close() error {
+	client.closing.Store(true)
 	if client.status.Load() == StatusClosed {
 		return pnet.ErrClosedClient
 	}
@@ -125,6 +187,7 @@ func (client *Client) Close() error {
 }
 
 func (client *Client) CloseWithContext(ctx context.Context) error {
+	client.closing.Store(true)
 	if client.status.Load() == StatusClosed {
 		return pnet.ErrClosedClient
 	}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/pnet"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
@@ -10,7 +12,7 @@ import (
 	"net"
 )
 
-func NewConn(client *Client, pConn *net.TCPConn) (*Conn, error) {
+func NewConn(client *Client, pConn net.Conn) (*Conn, error) {
 	conn := &Conn{}
 	err := conn.Init(client, pConn)
 	if err != nil {
@@ -21,34 +23,51 @@ func NewConn(client *Client, pConn *net.TCPConn) (*Conn, error) {
 }
 
 type Conn struct {
-	*net.TCPConn
+	// 原始连接，为*net.TCPConn或*net.UnixConn
+	net.Conn
 	session.BaseConn
 
+	// 实际读写的连接，开启TLS时为*tls.Conn
+	netConn net.Conn
+
 	client   *Client
 	inbound  elastic.RingBuffer
 	outbound bytes.Buffer
 	context  any
 }
 
-func (conn *Conn) Init(client *Client, pConn *net.TCPConn) error {
-	conn.TCPConn = pConn
+// socketBuffer
+//
+//	@Description: 可设置读写缓冲的连接
+type socketBuffer interface {
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+}
+
+func (conn *Conn) Init(client *Client, pConn net.Conn) error {
+	conn.Conn = pConn
+	conn.netConn = pConn
 	conn.client = client
-	if client.TCPKeepAlive > 0 {
-		if err := conn.TCPConn.SetKeepAlive(true); err != nil {
-			return err
+	if tcpConn, ok := pConn.(*net.TCPConn); ok {
+		if client.TCPKeepAlive > 0 {
+			if err := tcpConn.SetKeepAlive(true); err != nil {
+				return err
+			}
+			if err := tcpConn.SetKeepAlivePeriod(client.TCPKeepAlive); err != nil {
+				return err
+			}
 		}
-		if err := conn.TCPConn.SetKeepAlivePeriod(client.TCPKeepAlive); err != nil {
+		if err := tcpConn.SetNoDelay(client.TCPNoDelay); err != nil {
 			return err
 		}
 	}
-	if err := conn.TCPConn.SetNoDelay(client.TCPNoDelay); err != nil {
-		return err
-	}
-	if err := conn.TCPConn.SetReadBuffer(client.SocketRecvBuffer); err != nil {
-		return err
-	}
-	if err := conn.TCPConn.SetWriteBuffer(client.SocketSendBuffer); err != nil {
-		return err
+	if bufConn, ok := pConn.(socketBuffer); ok {
+		if err := bufConn.SetReadBuffer(client.SocketRecvBuffer); err != nil {
+			return err
+		}
+		if err := bufConn.SetWriteBuffer(client.SocketSendBuffer); err != nil {
+			return err
+		}
 	}
 	return conn.BaseConn.Init(pConn, true)
 }
@@ -168,7 +187,23 @@ func (conn *Conn) AsyncWritev(bs [][]byte, callback func(c session.Conn, err err
 }
 
 func (conn *Conn) Close() error {
-	return conn.TCPConn.Close()
+	return conn.netConn.Close()
+}
+
+// handshake
+//
+//	@Description: 在原始连接上完成TLS握手，之后的读写均经过TLS
+//	@receiver conn
+//	@param ctx
+//	@param config TLS配置
+//	@return error
+func (conn *Conn) handshake(ctx context.Context, config *tls.Config) error {
+	tlsConn := tls.Client(conn.Conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	conn.netConn = tlsConn
+	return nil
 }
 
 func (conn *Conn) ToClosed(reason error) bool {
@@ -3,13 +3,13 @@ package client
 import (
 	"bytes"
 	"context"
-	"errors"
 	"github.com/meow-pad/persian/errdef"
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
 	"github.com/meow-pad/persian/frame/pnet"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/utils/coding"
+	"time"
 )
 
 const (
@@ -46,6 +46,8 @@ type eventLoop struct {
 	readDone   chan struct{}
 	writeChan  chan writeEvent
 	closeChan  chan error
+	ticker     *time.Ticker
+	tickChan   <-chan time.Time
 	cancelCtx  context.Context
 	cancelFunc context.CancelFunc
 }
@@ -78,6 +80,7 @@ func (loop *eventLoop) run(first bool) {
 				loop._stop(pnet.ErrOutOfReadCap)
 				return
 			}
+			loop.conn.UpdateReadTime()
 			_, _ = loop.conn.inbound.Write(loop.buffer[:n]) // 目前的实现上不会返回err
 			act := loop.handler.OnTraffic(loop.conn)
 			if act == actionClose {
@@ -96,7 +99,10 @@ func (loop *eventLoop) run(first bool) {
 			}
 			// 有数据可写则发送
 			if loop.conn.outbound.Len() > 0 {
-				_, err := loop.conn.TCPConn.ReadFrom(&loop.conn.outbound)
+				_, err := loop.conn.outbound.WriteTo(loop.conn.netConn)
+				if err == nil {
+					loop.conn.UpdateWriteTime()
+				}
 				if event.callback != nil {
 					if cErr := event.callback(loop.conn, err); cErr != nil {
 						plog.Error("write callback error:", pfield.Error(cErr))
@@ -106,6 +112,10 @@ func (loop *eventLoop) run(first bool) {
 					return
 				}
 			}
+		case now := <-loop.tickChan:
+			if loop.checkIdle(now) {
+				return
+			}
 		case closeReason := <-loop.closeChan:
 			loop._stop(closeReason)
 			return
@@ -117,27 +127,43 @@ func (loop *eventLoop) run(first bool) {
 	}
 }
 
+// readState
+//
+//	@Description: 读协程使用的状态，在启动时获取，避免与事件循环的关闭竞争
+type readState struct {
+	conn      *Conn
+	buffer    []byte
+	readChan  chan int
+	readDone  chan struct{}
+	closeChan chan error
+	cancelCtx context.Context
+}
+
 // readConn
 //
-//	@Description: 持续读网络数据
+//	@Description: 持续读网络数据，仅处理启动时关联的连接
 //	@receiver loop
-func (loop *eventLoop) readConn() {
+//	@param state 启动时的读状态
+func (loop *eventLoop) readConn(state *readState) {
+	conn, buffer := state.conn, state.buffer
+	readChan, readDone, closeChan, cancelCtx := state.readChan, state.readDone, state.closeChan, state.cancelCtx
 	defer coding.CatchPanicError("read conn error:", func() {
-		if loop.client.IsClosed() {
+		if closed, _ := conn.IsClosed(); closed || cancelCtx.Err() != nil {
 			return
 		}
-		go loop.readConn()
+		go loop.readConn(state)
 	})
 	for {
 		// 判定是否已关闭
-		if loop.client.IsClosed() {
+		if closed, _ := conn.IsClosed(); closed {
 			return
 		}
 		// 读网络
-		n, err := loop.conn.TCPConn.Read(loop.buffer)
+		n, err := conn.netConn.Read(buffer)
 		if err != nil {
-			if sErr := loop.stop(context.Background(), err); sErr != nil && !errors.Is(sErr, pnet.ErrClosedClient) {
-				plog.Error("", pfield.Error(sErr))
+			select {
+			case closeChan <- err:
+			case <-cancelCtx.Done():
 			}
 			return
 		}
@@ -145,14 +171,14 @@ func (loop *eventLoop) readConn() {
 		if n > 0 {
 			// 等待读处理
 			select {
-			case loop.readChan <- n:
-			case <-loop.cancelCtx.Done():
+			case readChan <- n:
+			case <-cancelCtx.Done():
 				return
 			}
 			// 等待读处理结束
 			select {
-			case <-loop.readDone:
-			case <-loop.cancelCtx.Done():
+			case <-readDone:
+			case <-cancelCtx.Done():
 				return
 			}
 		}
@@ -194,6 +220,26 @@ func (loop *eventLoop) flush() error {
 	return nil
 }
 
+// checkIdle
+//
+//	@Description: 检查连接是否空闲，发送心跳或关闭超时连接
+//	@receiver loop
+//	@param now
+//	@return bool 是否已关闭
+func (loop *eventLoop) checkIdle(now time.Time) bool {
+	heartbeat := loop.client.Heartbeat
+	switch loop.conn.CheckIdle(heartbeat, now) {
+	case session.IdleHeartbeat:
+		if heartbeat.Message != nil {
+			loop.client.SendMessage(heartbeat.Message())
+		}
+	case session.IdleClose:
+		loop._stop(pnet.ErrIdleTimeout)
+		return true
+	}
+	return false
+}
+
 // start
 //
 //	@Description: 开启事件处理循环
@@ -210,9 +256,21 @@ func (loop *eventLoop) start(conn *Conn) error {
 	loop.readDone = make(chan struct{})
 	loop.writeChan = make(chan writeEvent, loop.client.WriteQueueCap)
 	loop.closeChan = make(chan error)
+	if heartbeat := loop.client.Heartbeat; heartbeat != nil && heartbeat.Interval > 0 {
+		loop.ticker = time.NewTicker(heartbeat.TickInterval())
+		loop.tickChan = loop.ticker.C
+	}
 	loop.cancelCtx, loop.cancelFunc = context.WithCancel(context.Background())
+	state := &readState{
+		conn:      conn,
+		buffer:    loop.buffer,
+		readChan:  loop.readChan,
+		readDone:  loop.readDone,
+		closeChan: loop.closeChan,
+		cancelCtx: loop.cancelCtx,
+	}
 	go loop.run(true)
-	go loop.readConn()
+	go loop.readConn(state)
 	return nil
 }
 
@@ -243,12 +301,23 @@ func (loop *eventLoop) _stop(reason error) {
 	if !loop.client.toClosed(reason) {
 		return
 	}
-	if err := loop.conn.TCPConn.Close(); err != nil {
+	if err := loop.conn.netConn.Close(); err != nil {
 		plog.Error("close connection error:", pfield.Error(err))
 	}
 	// 即便连接关闭出错，也继续走关闭逻辑
 	loop.handler.OnClose(loop.conn, reason)
 	loop.cancelFunc()
+	if loop.ticker != nil {
+		loop.ticker.Stop()
+		loop.ticker = nil
+		loop.tickChan = nil
+	}
 	loop.cache.Reset()
 	loop.buffer = nil
+	// 非主动关闭时重连，重连期间保留会话属性
+	if loop.client.Reconnect != nil && !loop.client.closing.Load() {
+		go loop.client.reconnect(reason)
+	} else {
+		loop.client.Attributes().Clear()
+	}
 }
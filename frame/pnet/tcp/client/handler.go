@@ -3,6 +3,7 @@ package client
 import (
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 )
 
 type action uint32
@@ -29,7 +30,7 @@ func (handler *eventHandler) OnOpen(conn *Conn) (action action) {
 
 func (handler *eventHandler) OnClose(conn *Conn, err error) (action action) {
 	conn.ToClosed(err)
-	conn.client.listener.OnClosed(conn.client)
+	conn.client.listener.OnClosed(conn.client, closeReason(conn))
 	plog.Debug("close tcp-client connecting:",
 		pfield.String("client", conn.client.Name),
 		pfield.Uint64("conn", conn.Hash()))
@@ -40,6 +41,7 @@ func (handler *eventHandler) OnTraffic(conn *Conn) (action action) {
 	msgArr, totalLen, err := conn.client.codec.Decode(conn)
 	if err != nil {
 		plog.Error("decode error", pfield.Error(err))
+		conn.ToClosed(session.NewCloseReason(session.CloseProtocol, session.CloseByLocal, err))
 		action = actionClose
 		return
 	}
@@ -51,3 +53,13 @@ func (handler *eventHandler) OnTraffic(conn *Conn) (action action) {
 	}
 	return
 }
+
+// closeReason
+//
+//	@Description: 获取连接的结构化关闭原因
+//	@param conn 已关闭的连接
+//	@return *session.CloseReason
+func closeReason(conn *Conn) *session.CloseReason {
+	_, err := conn.IsClosed()
+	return session.ResolveCloseReason(err)
+}
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"crypto/tls"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"time"
 )
 
@@ -37,6 +39,12 @@ type Options struct {
 	SocketRecvBuffer int
 	// socket写缓冲区
 	SocketSendBuffer int
+	// 心跳与空闲检测配置，为nil时不检测
+	Heartbeat *session.HeartbeatOptions
+	// 断线重连配置，为nil时不重连
+	Reconnect *session.ReconnectOptions
+	// TLS配置，设置后连接建立时先完成握手
+	TLSConfig *tls.Config
 }
 
 type Option func(*Options)
@@ -88,3 +96,42 @@ func WithSocketSendBuffer(cap int) Option {
 		options.SocketSendBuffer = cap
 	}
 }
+
+// WithHeartbeat
+//
+//	@Description: 开启心跳与空闲检测
+//	@param interval 未收到数据多久后发送心跳
+//	@param maxMissed 连续未响应的心跳次数上限，小于1时按1处理
+//	@param message 心跳消息构造函数，为nil时仅做空闲检测
+//	@return Option
+func WithHeartbeat(interval time.Duration, maxMissed int, message func() any) Option {
+	return func(options *Options) {
+		options.Heartbeat = &session.HeartbeatOptions{
+			Interval:  interval,
+			MaxMissed: maxMissed,
+			Message:   message,
+		}
+	}
+}
+
+// WithReconnect
+//
+//	@Description: 开启断线重连，主动关闭时不重连
+//	@param value 重连配置
+//	@return Option
+func WithReconnect(value *session.ReconnectOptions) Option {
+	return func(options *Options) {
+		options.Reconnect = value
+	}
+}
+
+// WithTLSConfig
+//
+//	@Description: 开启TLS，未指定ServerName时使用连接地址中的主机名校验证书
+//	@param config TLS配置
+//	@return Option
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.TLSConfig = config
+	}
+}
@@ -217,10 +217,13 @@ func (codec *lengthFieldCodec) decodeOne(reader gnet.Reader) (msg any, msgLen in
 	if err != nil || msgBuf == nil {
 		return
 	}
+	// 消息可能交由工作协程处理，读缓冲随后会被复用，消息体需拷贝出来再解码
+	body := make([]byte, bodyLen)
+	copy(body, msgBuf[bodyOffset:msgLen])
 	_, err = reader.Discard(msgLen)
 	if err != nil {
 		return
 	}
-	msg, err = codec.messageCodec.Decode(msgBuf[bodyOffset:msgLen])
+	msg, err = codec.messageCodec.Decode(body)
 	return
 }
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/panjf2000/gnet/v2"
+	"io"
+	"net"
 )
 
 func newConn(gConn gnet.Conn) (*Conn, error) {
@@ -17,6 +21,15 @@ func newConn(gConn gnet.Conn) (*Conn, error) {
 type Conn struct {
 	gnet.Conn
 	session.BaseConn
+
+	// TLS状态，未开启TLS时为nil
+	tls *tlsState
+	// 是否等待读取PROXY协议头，仅在事件循环中访问
+	proxyPending bool
+	// 是否已占用连接数，仅在事件循环中访问
+	counted bool
+	// 是否已通知会话开启，仅在事件循环中访问
+	opened bool
 }
 
 func (conn *Conn) Init(gConn gnet.Conn) error {
@@ -24,16 +37,144 @@ func (conn *Conn) Init(gConn gnet.Conn) error {
 	return conn.BaseConn.Init(gConn, false)
 }
 
+func (conn *Conn) RemoteAddr() net.Addr {
+	if addr := conn.ProxiedAddr(true); addr != nil {
+		return addr
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *Conn) LocalAddr() net.Addr {
+	if addr := conn.ProxiedAddr(false); addr != nil {
+		return addr
+	}
+	return conn.Conn.LocalAddr()
+}
+
+func (conn *Conn) Read(b []byte) (n int, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Read(b)
+	}
+	return conn.tls.plain.Read(b)
+}
+
+func (conn *Conn) WriteTo(w io.Writer) (n int64, err error) {
+	if conn.tls == nil {
+		return conn.Conn.WriteTo(w)
+	}
+	return conn.tls.plain.WriteTo(w)
+}
+
+func (conn *Conn) Next(n int) (buf []byte, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Next(n)
+	}
+	if totalLen := conn.tls.plain.Len(); n > totalLen {
+		err = io.ErrShortBuffer
+		return
+	} else if n <= 0 {
+		n = totalLen
+	}
+	buf = conn.tls.plain.Next(n)
+	return
+}
+
+func (conn *Conn) Peek(n int) (buf []byte, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Peek(n)
+	}
+	if totalLen := conn.tls.plain.Len(); n > totalLen {
+		err = io.ErrShortBuffer
+		return
+	} else if n <= 0 {
+		n = totalLen
+	}
+	buf = conn.tls.plain.Bytes()[:n]
+	return
+}
+
+func (conn *Conn) Discard(n int) (discarded int, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Discard(n)
+	}
+	if totalLen := conn.tls.plain.Len(); n > totalLen {
+		err = io.ErrShortBuffer
+		return
+	} else if n <= 0 {
+		n = totalLen
+	}
+	discarded = len(conn.tls.plain.Next(n))
+	return
+}
+
+func (conn *Conn) InboundBuffered() (n int) {
+	if conn.tls == nil {
+		return conn.Conn.InboundBuffered()
+	}
+	return conn.tls.plain.Len()
+}
+
+func (conn *Conn) Write(b []byte) (n int, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Write(b)
+	}
+	if err = conn.tlsWrite([][]byte{b}, nil); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (conn *Conn) Writev(bs [][]byte) (n int, err error) {
+	if conn.tls == nil {
+		return conn.Conn.Writev(bs)
+	}
+	if err = conn.tlsWrite(bs, nil); err != nil {
+		return
+	}
+	for _, b := range bs {
+		n += len(b)
+	}
+	return
+}
+
 func (conn *Conn) AsyncWrite(buf []byte, callback func(c session.Conn, err error) error) (err error) {
-	err = conn.Conn.AsyncWrite(buf, func(c gnet.Conn, err error) error {
+	wrapped := func(c gnet.Conn, err error) error {
 		return callback(conn, err)
-	})
+	}
+	if conn.tls != nil {
+		return conn.tlsWrite([][]byte{buf}, wrapped)
+	}
+	err = conn.Conn.AsyncWrite(buf, wrapped)
 	return
 }
 
 func (conn *Conn) AsyncWritev(bs [][]byte, callback func(c session.Conn, err error) error) (err error) {
-	err = conn.Conn.AsyncWritev(bs, func(c gnet.Conn, err error) error {
+	wrapped := func(c gnet.Conn, err error) error {
 		return callback(conn, err)
-	})
+	}
+	if conn.tls != nil {
+		return conn.tlsWrite(bs, wrapped)
+	}
+	err = conn.Conn.AsyncWritev(bs, wrapped)
 	return
 }
+
+func (conn *Conn) Close() error {
+	if conn.tls != nil {
+		// 尽量发送close_notify，出错时仍关闭底层连接
+		if err := conn.tls.conn.Close(); err != nil {
+			plog.Debug("close tls connection error:", pfield.Error(err))
+		}
+	}
+	return conn.Conn.Close()
+}
+
+func (conn *Conn) ToClosed(reason error) bool {
+	if conn.BaseConn.ToClosed(reason) {
+		if conn.tls != nil {
+			conn.tls.transport.shutdown()
+		}
+		return true
+	}
+	return false
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"crypto/tls"
 	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/utils/runtime"
 	"github.com/panjf2000/gnet/v2"
 	"time"
@@ -55,6 +57,7 @@ func NewOptions(opts ...Option) (*Options, error) {
 		},
 		UnregisterSessionLife: 20,
 		CheckSessionInterval:  30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
 	}
 
 	for _, opt := range opts {
@@ -70,6 +73,26 @@ type Options struct {
 	UnregisterSessionLife int64
 	// 检查session间隔
 	CheckSessionInterval time.Duration
+	// 消息分发器，设置后监听器的接收回调将在工作池中执行
+	Dispatcher *session.Dispatcher
+	// 心跳与空闲检测配置，为nil时不检测
+	Heartbeat *session.HeartbeatOptions
+	// TLS配置，设置后在编解码前完成握手与解密
+	TLSConfig *tls.Config
+	// TLS握手超时
+	TLSHandshakeTimeout time.Duration
+	// 停服排空配置，为nil时停服直接关闭所有连接
+	Drain *session.DrainOptions
+	// 速率与连接数限制配置，为nil时不限制
+	Limit *session.LimitOptions
+	// PROXY协议模式，开启后在连接首包中解析负载均衡传递的真实地址
+	ProxyProtocol session.ProxyMode
+	// 会话恢复配置，为nil时不支持恢复
+	Resume *session.ResumeOptions
+	// 出站背压配置，为nil时不限制出站缓冲
+	Backpressure *session.BackpressureOptions
+	// 共享的会话管理器，为nil时服务单独创建
+	Manager *session.Manager
 }
 
 type Option func(options *Options)
@@ -93,3 +116,124 @@ func WithCheckSessionInterval(value time.Duration) Option {
 		opts.CheckSessionInterval = value
 	}
 }
+
+func WithDispatcher(value *session.Dispatcher) Option {
+	return func(opts *Options) {
+		opts.Dispatcher = value
+	}
+}
+
+// WithHeartbeat
+//
+//	@Description: 开启心跳与空闲检测
+//	@param interval 未收到数据多久后发送心跳
+//	@param maxMissed 连续未响应的心跳次数上限，小于1时按1处理
+//	@param message 心跳消息构造函数，为nil时仅做空闲检测
+//	@return Option
+func WithHeartbeat(interval time.Duration, maxMissed int, message func() any) Option {
+	return func(opts *Options) {
+		opts.Heartbeat = &session.HeartbeatOptions{
+			Interval:  interval,
+			MaxMissed: maxMissed,
+			Message:   message,
+		}
+	}
+}
+
+// WithTLSConfig
+//
+//	@Description: 开启TLS，握手完成后才通知会话开启
+//	@param config TLS配置，需包含证书
+//	@return Option
+func WithTLSConfig(config *tls.Config) Option {
+	return func(opts *Options) {
+		opts.TLSConfig = config
+	}
+}
+
+func WithTLSHandshakeTimeout(value time.Duration) Option {
+	return func(opts *Options) {
+		opts.TLSHandshakeTimeout = value
+	}
+}
+
+// WithDrain
+//
+//	@Description: 开启停服排空
+//	@param timeout 停止上下文未设置截止时间时的最长等待时间
+//	@param message 停服通知消息构造函数，为nil时不通知
+//	@return Option
+func WithDrain(timeout time.Duration, message func() any) Option {
+	return func(opts *Options) {
+		opts.Drain = &session.DrainOptions{
+			Message: message,
+			Timeout: timeout,
+		}
+	}
+}
+
+// WithLimit
+//
+//	@Description: 开启速率与连接数限制，监听器可实现 session.LimitListener 获知触发的限制
+//	@param value 限制配置
+//	@return Option
+func WithLimit(value *session.LimitOptions) Option {
+	return func(opts *Options) {
+		opts.Limit = value
+	}
+}
+
+// WithProxyProtocol
+//
+//	@Description: 开启PROXY协议(v1/v2)解析，会话的 RemoteAddr 与 LocalAddr 返回真实地址
+//	@param mode 协议模式
+//	@return Option
+func WithProxyProtocol(mode session.ProxyMode) Option {
+	return func(opts *Options) {
+		opts.ProxyProtocol = mode
+	}
+}
+
+// WithResume
+//
+//	@Description: 开启会话恢复，注册后发出的消息保留在重放缓冲中，客户端重连后可通过 Server.ResumeSession 恢复
+//	@param gracePeriod 会话关闭后保留恢复状态的时长
+//	@param bufferSize 重放缓冲保留的消息数
+//	@return Option
+func WithResume(gracePeriod time.Duration, bufferSize int) Option {
+	return func(opts *Options) {
+		opts.Resume = &session.ResumeOptions{
+			GracePeriod: gracePeriod,
+			BufferSize:  bufferSize,
+		}
+	}
+}
+
+// WithBackpressure
+//
+//	@Description: 开启出站背压，监听器可实现 session.BackpressureListener 获知会话慢速状态的变化
+//	@param highWatermark 出站字节数高水位，达到后会话标记为慢速
+//	@param lowWatermark 出站字节数低水位，降至以下后恢复，为0时使用高水位的一半
+//	@param policy 慢速时的处理策略
+//	@return Option
+func WithBackpressure(highWatermark, lowWatermark int, policy session.BackpressurePolicy) Option {
+	return func(opts *Options) {
+		opts.Backpressure = &session.BackpressureOptions{
+			HighWatermark: highWatermark,
+			LowWatermark:  lowWatermark,
+			Policy:        policy,
+		}
+	}
+}
+
+// WithManager
+//
+//	@Description: 使用共享的会话管理器，多个服务共享时会话注册与顶号跨服务生效
+//		共享时 UnregisterSessionLife 以管理器的配置为准，恢复配置应保持一致
+//	@param manager 会话管理器
+//	@return Option
+func WithManager(manager *session.Manager) Option {
+	return func(opts *Options) {
+		opts.Manager = manager
+	}
+}
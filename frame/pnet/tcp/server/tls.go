@@ -0,0 +1,396 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/utils/coding"
+	"github.com/panjf2000/gnet/v2"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tlsReadBufferSize 单次解密读取的缓冲大小，与TLS最大记录长度一致
+const tlsReadBufferSize = 16 * 1024
+
+// tlsTransport
+//
+//	@Description: 将gnet连接适配为阻塞式的net.Conn，供crypto/tls读写密文
+//		读：事件循环投递的密文，无数据时阻塞
+//		写：直接异步写出，或在加密期间暂存后由调用方统一写出以保证顺序
+type tlsTransport struct {
+	gConn gnet.Conn
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	cipher bytes.Buffer
+	closed bool
+	// 是否处于加密暂存阶段
+	sealing bool
+	sealed  []byte
+}
+
+func newTLSTransport(gConn gnet.Conn) *tlsTransport {
+	transport := &tlsTransport{gConn: gConn}
+	transport.cond = sync.NewCond(&transport.mu)
+	return transport
+}
+
+// feed
+//
+//	@Description: 投递收到的密文，在事件循环中执行
+//	@receiver transport
+//	@param data
+func (transport *tlsTransport) feed(data []byte) {
+	transport.mu.Lock()
+	if !transport.closed {
+		transport.cipher.Write(data)
+		transport.cond.Signal()
+	}
+	transport.mu.Unlock()
+}
+
+func (transport *tlsTransport) Read(b []byte) (n int, err error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	for transport.cipher.Len() <= 0 && !transport.closed {
+		transport.cond.Wait()
+	}
+	if transport.cipher.Len() <= 0 {
+		return 0, io.EOF
+	}
+	return transport.cipher.Read(b)
+}
+
+func (transport *tlsTransport) Write(b []byte) (n int, err error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if transport.closed {
+		return 0, net.ErrClosed
+	}
+	if transport.sealing {
+		transport.sealed = append(transport.sealed, b...)
+		return len(b), nil
+	}
+	if err = transport.gConn.AsyncWrite(bytes.Clone(b), nil); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// seal
+//
+//	@Description: 执行加密写入并将产生的密文一次性异步写出
+//	@receiver transport
+//	@param write 加密写入函数
+//	@param callback 写出完成回调
+//	@return error
+func (transport *tlsTransport) seal(write func() error, callback gnet.AsyncCallback) error {
+	transport.mu.Lock()
+	transport.sealing = true
+	transport.mu.Unlock()
+	err := write()
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	sealed := transport.sealed
+	transport.sealing = false
+	transport.sealed = nil
+	if err != nil {
+		return err
+	}
+	if transport.closed {
+		return net.ErrClosed
+	}
+	return transport.gConn.AsyncWrite(sealed, callback)
+}
+
+// shutdown
+//
+//	@Description: 标记关闭并唤醒阻塞的读
+//	@receiver transport
+func (transport *tlsTransport) shutdown() {
+	transport.mu.Lock()
+	transport.closed = true
+	transport.cipher.Reset()
+	transport.cond.Broadcast()
+	transport.mu.Unlock()
+}
+
+func (transport *tlsTransport) Close() error {
+	transport.shutdown()
+	return transport.gConn.Close()
+}
+
+func (transport *tlsTransport) LocalAddr() net.Addr {
+	return transport.gConn.LocalAddr()
+}
+
+func (transport *tlsTransport) RemoteAddr() net.Addr {
+	return transport.gConn.RemoteAddr()
+}
+
+func (transport *tlsTransport) SetDeadline(_ time.Time) error {
+	return nil
+}
+
+func (transport *tlsTransport) SetReadDeadline(_ time.Time) error {
+	return nil
+}
+
+func (transport *tlsTransport) SetWriteDeadline(_ time.Time) error {
+	return nil
+}
+
+// queuedWrite
+//
+//	@Description: 握手完成前暂存的写入
+type queuedWrite struct {
+	bs       [][]byte
+	callback gnet.AsyncCallback
+}
+
+// tlsState
+//
+//	@Description: 连接的TLS状态
+type tlsState struct {
+	transport *tlsTransport
+	conn      *tls.Conn
+	// 握手是否已完成
+	handshaked atomic.Bool
+	// 是否已通知会话开启，仅在事件循环中访问
+	opened bool
+	// 加密写锁，保证密文按加密顺序写出
+	writeMu sync.Mutex
+	// 握手完成前提交的写入，握手完成后按序写出，受 writeMu 保护
+	queued []queuedWrite
+
+	// 解密协程投递的明文
+	pendingMu sync.Mutex
+	pending   [][]byte
+	// 待解码明文，仅在事件循环中访问
+	plain bytes.Buffer
+}
+
+// push
+//
+//	@Description: 投递解密后的明文，在解密协程中执行
+//	@receiver state
+//	@param data
+func (state *tlsState) push(data []byte) {
+	state.pendingMu.Lock()
+	state.pending = append(state.pending, data)
+	state.pendingMu.Unlock()
+}
+
+// collect
+//
+//	@Description: 将已解密明文移入待解码缓冲，在事件循环中执行
+//	@receiver state
+func (state *tlsState) collect() {
+	state.pendingMu.Lock()
+	pending := state.pending
+	state.pending = nil
+	state.pendingMu.Unlock()
+	for _, data := range pending {
+		state.plain.Write(data)
+	}
+}
+
+// initTLS
+//
+//	@Description: 初始化TLS状态，需在会话可被其他协程访问前执行，此后的写入暂存至握手完成
+//	@receiver conn
+//	@param config TLS配置
+func (conn *Conn) initTLS(config *tls.Config) {
+	transport := newTLSTransport(conn.Conn)
+	conn.tls = &tlsState{
+		transport: transport,
+		conn:      tls.Server(transport, config),
+	}
+}
+
+// startTLS
+//
+//	@Description: 启动握手及解密协程
+//	@receiver conn
+//	@param handshakeTimeout 握手超时
+func (conn *Conn) startTLS(handshakeTimeout time.Duration) {
+	go conn.serveTLS(handshakeTimeout)
+}
+
+// handshakeContext
+//
+//	@Description: 构造TLS握手的上下文
+//	@param handshakeTimeout 握手超时，为0时不限制
+//	@return context.Context
+//	@return context.CancelFunc
+func handshakeContext(handshakeTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if handshakeTimeout > 0 {
+		return context.WithTimeout(context.Background(), handshakeTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// serveTLS
+//
+//	@Description: 完成握手后持续解密数据，并唤醒事件循环处理
+//	@receiver conn
+//	@param handshakeTimeout 握手超时
+func (conn *Conn) serveTLS(handshakeTimeout time.Duration) {
+	defer coding.CatchPanicError("serve tls error:", func() {
+		_ = conn.Conn.Close()
+	})
+	state := conn.tls
+	ctx, cancel := handshakeContext(handshakeTimeout)
+	err := state.conn.HandshakeContext(ctx)
+	cancel()
+	if err != nil {
+		plog.Debug("tls handshake error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
+		conn.ToClosed(err)
+		conn.dropQueued(err)
+		_ = conn.Conn.Close()
+		return
+	}
+	if err = conn.flushQueued(); err != nil {
+		plog.Debug("write queued data error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
+		conn.ToClosed(err)
+		_ = conn.Conn.Close()
+		return
+	}
+	if err = conn.Conn.Wake(nil); err != nil {
+		plog.Error("wake connection error:", pfield.Error(err))
+	}
+	buf := make([]byte, tlsReadBufferSize)
+	for {
+		n, rErr := state.conn.Read(buf)
+		if n > 0 {
+			state.push(bytes.Clone(buf[:n]))
+			if err = conn.Conn.Wake(nil); err != nil {
+				plog.Error("wake connection error:", pfield.Error(err))
+			}
+		}
+		if rErr != nil {
+			if closed, _ := conn.IsClosed(); !closed {
+				conn.ToClosed(rErr)
+				_ = conn.Conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// tlsTraffic
+//
+//	@Description: 处理TLS连接的可读事件，投递密文并收集明文
+//	@receiver conn
+//	@return opened 本次是否完成握手需通知会话开启
+//	@return readable 是否有待解码的明文
+func (conn *Conn) tlsTraffic() (opened bool, readable bool) {
+	state := conn.tls
+	if n := conn.Conn.InboundBuffered(); n > 0 {
+		cipher, _ := conn.Conn.Next(n)
+		state.transport.feed(cipher)
+	}
+	if !state.opened && state.handshaked.Load() {
+		state.opened = true
+		opened = true
+	}
+	state.collect()
+	readable = state.opened && state.plain.Len() > 0
+	return
+}
+
+// tlsWrite
+//
+//	@Description: 加密并异步写出数据，握手完成前暂存至握手完成后写出
+//	@receiver conn
+//	@param bs 明文数据
+//	@param callback 写出完成回调
+//	@return error
+func (conn *Conn) tlsWrite(bs [][]byte, callback gnet.AsyncCallback) error {
+	state := conn.tls
+	state.writeMu.Lock()
+	defer state.writeMu.Unlock()
+	if !state.handshaked.Load() {
+		if closed, _ := conn.IsClosed(); closed {
+			return net.ErrClosed
+		}
+		cloned := make([][]byte, len(bs))
+		for i, b := range bs {
+			cloned[i] = bytes.Clone(b)
+		}
+		state.queued = append(state.queued, queuedWrite{bs: cloned, callback: callback})
+		return nil
+	}
+	return conn.sealWrite(bs, callback)
+}
+
+// sealWrite
+//
+//	@Description: 加密并异步写出数据，需持有 writeMu
+//	@receiver conn
+//	@param bs 明文数据
+//	@param callback 写出完成回调
+//	@return error
+func (conn *Conn) sealWrite(bs [][]byte, callback gnet.AsyncCallback) error {
+	state := conn.tls
+	return state.transport.seal(func() error {
+		for _, b := range bs {
+			if _, err := state.conn.Write(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, callback)
+}
+
+// flushQueued
+//
+//	@Description: 标记握手完成并按序写出握手期间暂存的数据，在解密协程中执行
+//	@receiver conn
+//	@return error
+func (conn *Conn) flushQueued() error {
+	state := conn.tls
+	state.writeMu.Lock()
+	defer state.writeMu.Unlock()
+	queued := state.queued
+	state.queued = nil
+	state.handshaked.Store(true)
+	for i, write := range queued {
+		if err := conn.sealWrite(write.bs, write.callback); err != nil {
+			failQueued(queued[i:], err)
+			return err
+		}
+	}
+	return nil
+}
+
+// dropQueued
+//
+//	@Description: 握手失败时丢弃暂存的数据并以错误通知回调
+//	@receiver conn
+//	@param err 握手错误
+func (conn *Conn) dropQueued(err error) {
+	state := conn.tls
+	state.writeMu.Lock()
+	queued := state.queued
+	state.queued = nil
+	state.writeMu.Unlock()
+	failQueued(queued, err)
+}
+
+func failQueued(queued []queuedWrite, err error) {
+	for _, write := range queued {
+		if write.callback == nil {
+			continue
+		}
+		if cErr := write.callback(nil, err); cErr != nil {
+			plog.Error("write callback error:", pfield.Error(cErr))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/frame/pnet/message"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+)
+
+// NewTypedServer
+//
+//	@Description: 构造带类型的服务，编解码、监听与发送的消息类型在编译期检查
+//	@param name 服务名称
+//	@param protoAddr 带协议地址
+//	@param frameCodec 帧编解码器，需实现 codec.Options，其消息解析器会被替换为 msgCodec
+//	@param msgCodec 带类型的消息解析器
+//	@param listener 带类型的会话监听器
+//	@param opts
+//	@return *TypedServer[In, Out]
+//	@return error
+func NewTypedServer[In, Out any](name string, protoAddr string, frameCodec codec.Codec,
+	msgCodec message.TypedCodec[In, Out], listener session.TypedListener[In, Out], opts ...Option) (*TypedServer[In, Out], error) {
+	if frameCodec == nil {
+		return nil, errors.New("less codec")
+	}
+	if msgCodec == nil {
+		return nil, errors.New("less message codec")
+	}
+	if listener == nil {
+		return nil, errors.New("less listener")
+	}
+	codecOpts, ok := frameCodec.(codec.Options)
+	if !ok {
+		return nil, errors.New("frame codec does not support message codec")
+	}
+	codecOpts.SetMessageCodec(message.NewCodecAdapter(msgCodec))
+	server, err := NewServer(name, protoAddr, frameCodec, session.NewListenerAdapter(listener), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedServer[In, Out]{Server: server}, nil
+}
+
+// TypedServer
+//
+//	@Description: 带类型的服务，广播消息的类型在编译期检查
+type TypedServer[In, Out any] struct {
+	*Server
+}
+
+// Broadcast
+//
+//	@Description: 向分组成员广播消息，消息仅编码一次
+//	@receiver server
+//	@param groupId 分组编号
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (server *TypedServer[In, Out]) Broadcast(groupId uint64, message Out, exclude ...session.Session) error {
+	return server.Server.Broadcast(groupId, message, exclude...)
+}
+
+// BroadcastAll
+//
+//	@Description: 向所有已注册会话广播消息，消息仅编码一次
+//	@receiver server
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (server *TypedServer[In, Out]) BroadcastAll(message Out, exclude ...session.Session) error {
+	return server.Server.BroadcastAll(message, exclude...)
+}
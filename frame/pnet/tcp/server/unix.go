@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/utils"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixDialTimeout 探测socket文件是否仍在监听的超时
+const unixDialTimeout = time.Second
+
+// checkUnixAddress
+//
+//	@Description: 检查unix socket地址，gnet会将地址转为小写，路径需为小写
+//	@param protoAddr 带协议地址
+//	@return error
+func checkUnixAddress(protoAddr string) error {
+	proto, path, err := utils.GetAddress(protoAddr)
+	if err != nil || proto != utils.ProtoUnix {
+		return err
+	}
+	if len(path) <= 0 {
+		return errors.New("less unix socket path")
+	}
+	if strings.ToLower(path) != path {
+		return fmt.Errorf("unix socket path(%s) must be lower case", path)
+	}
+	return nil
+}
+
+// prepareUnixSocket
+//
+//	@Description: 启动前清理残留的socket文件，仍有服务监听或不是socket文件时返回错误
+//		停止时由gnet关闭监听并删除socket文件
+//	@param protoAddr 带协议地址
+//	@return error
+func prepareUnixSocket(protoAddr string) error {
+	proto, path, err := utils.GetAddress(protoAddr)
+	if err != nil || proto != utils.ProtoUnix {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s is not a unix socket file", path)
+	}
+	conn, err := net.DialTimeout(utils.ProtoUnix, path, unixDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket(%s) is in use", path)
+	}
+	plog.Info("remove stale unix socket", pfield.String("path", path))
+	return os.Remove(path)
+}
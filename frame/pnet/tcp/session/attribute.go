@@ -0,0 +1,109 @@
+package session
+
+import "sync"
+
+// Attributes
+//
+//	@Description: 会话属性，协程安全，会话注册前后均可使用，会话关闭后清空
+type Attributes struct {
+	values sync.Map
+}
+
+// Load
+//
+//	@Description: 获取属性
+//	@receiver attrs
+//	@param key
+//	@return value
+//	@return ok 是否存在
+func (attrs *Attributes) Load(key string) (value any, ok bool) {
+	return attrs.values.Load(key)
+}
+
+// Set
+//
+//	@Description: 设置属性
+//	@receiver attrs
+//	@param key
+//	@param value
+func (attrs *Attributes) Set(key string, value any) {
+	attrs.values.Store(key, value)
+}
+
+// Delete
+//
+//	@Description: 删除属性
+//	@receiver attrs
+//	@param key
+func (attrs *Attributes) Delete(key string) {
+	attrs.values.Delete(key)
+}
+
+// Range
+//
+//	@Description: 遍历属性
+//	@receiver attrs
+//	@param op 返回false时停止遍历
+func (attrs *Attributes) Range(op func(key string, value any) bool) {
+	attrs.values.Range(func(key, value any) bool {
+		return op(key.(string), value)
+	})
+}
+
+// Clear
+//
+//	@Description: 清空属性
+//	@receiver attrs
+func (attrs *Attributes) Clear() {
+	attrs.values.Range(func(key, _ any) bool {
+		attrs.values.Delete(key)
+		return true
+	})
+}
+
+// copyTo
+//
+//	@Description: 复制属性到目标属性
+//	@receiver attrs
+//	@param target
+func (attrs *Attributes) copyTo(target *Attributes) {
+	attrs.values.Range(func(key, value any) bool {
+		target.values.Store(key, value)
+		return true
+	})
+}
+
+// GetAttribute
+//
+//	@Description: 获取指定类型的会话属性
+//	@param sess 会话
+//	@param key 属性键
+//	@return value
+//	@return ok 不存在或类型不符时为false
+func GetAttribute[T any](sess Session, key string) (value T, ok bool) {
+	raw, ok := sess.Attributes().Load(key)
+	if !ok {
+		return
+	}
+	value, ok = raw.(T)
+	return
+}
+
+// SetAttribute
+//
+//	@Description: 设置会话属性
+//	@param sess 会话
+//	@param key 属性键
+//	@param value
+func SetAttribute[T any](sess Session, key string, value T) {
+	sess.Attributes().Set(key, value)
+}
+
+// DeleteAttribute
+//
+//	@Description: 删除会话属性
+//	@param sess 会话
+//	@param key 属性键
+func DeleteAttribute(sess Session, key string) {
+	sess.Attributes().Delete(key)
+}
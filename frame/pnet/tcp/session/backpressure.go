@@ -0,0 +1,426 @@
+package session
+
+import (
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/panjf2000/gnet/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// BackpressureSampleInterval 慢速会话出站缓冲的采样间隔
+	BackpressureSampleInterval = 100 * time.Millisecond
+)
+
+// BackpressurePolicy
+//
+//	@Description: 会话慢速时的处理策略
+type BackpressurePolicy uint8
+
+const (
+	// BackpressureDrop 丢弃可丢弃（实现 Droppable）的消息
+	BackpressureDrop BackpressurePolicy = iota
+	// BackpressureCoalesce 暂存可合并（实现 Coalescable）的消息，同键只保留最新的一条，恢复后发送
+	BackpressureCoalesce
+	// BackpressureClose 关闭会话
+	BackpressureClose
+)
+
+// OutboundAction
+//
+//	@Description: 发送消息前的检查结果
+type OutboundAction uint8
+
+const (
+	// OutboundSend 正常发送
+	OutboundSend OutboundAction = iota
+	// OutboundDrop 丢弃消息
+	OutboundDrop
+	// OutboundHold 已暂存消息，恢复后发送
+	OutboundHold
+	// OutboundClose 需关闭会话
+	OutboundClose
+)
+
+// Droppable
+//
+//	@Description: 消息可实现该接口标记为低优先级，会话慢速时可被丢弃
+type Droppable interface {
+	Droppable() bool
+}
+
+// Coalescable
+//
+//	@Description: 消息可实现该接口，会话慢速时同键的消息只保留最新的一条
+type Coalescable interface {
+	CoalesceKey() string
+}
+
+// BackpressureListener
+//
+//	@Description: 会话监听器可实现该接口以获知会话慢速状态的变化
+type BackpressureListener interface {
+
+	// OnBackpressure
+	//	@Description: 会话慢速状态变化，可能在任意协程中调用
+	//	@param session
+	//	@param slow 是否慢速
+	//
+	OnBackpressure(session Session, slow bool)
+}
+
+// BackpressureOptions
+//
+//	@Description: 出站背压配置
+//		会话出站字节数（已提交未写出的数据）达到高水位时标记为慢速，降至低水位以下后恢复
+type BackpressureOptions struct {
+	// 高水位字节数
+	HighWatermark int
+	// 低水位字节数，为0时使用高水位的一半
+	LowWatermark int
+	// 慢速时的处理策略
+	Policy BackpressurePolicy
+}
+
+// NewOutbound
+//
+//	@Description: 构造会话的出站统计
+//	@param opts
+//	@return *Outbound 未配置背压时返回nil
+func NewOutbound(opts *BackpressureOptions) *Outbound {
+	if opts == nil || opts.HighWatermark <= 0 {
+		return nil
+	}
+	outbound := &Outbound{opts: opts, low: int64(opts.LowWatermark)}
+	if outbound.low <= 0 || outbound.low >= int64(opts.HighWatermark) {
+		outbound.low = int64(opts.HighWatermark / 2)
+	}
+	return outbound
+}
+
+// Outbound
+//
+//	@Description: 会话的出站统计，协程安全
+type Outbound struct {
+	opts *BackpressureOptions
+	low  int64
+	// 已提交但事件循环尚未处理的字节数
+	queued atomic.Int64
+	// 事件循环中最近一次获取的出站缓冲字节数
+	buffered atomic.Int64
+
+	mu sync.Mutex
+	// 暂存的可合并消息，按键首次暂存的顺序发送
+	heldKeys []string
+	held     map[string]any
+}
+
+// Size
+//
+//	@Description: 出站字节数
+//	@receiver outbound
+//	@return int64
+func (outbound *Outbound) Size() int64 {
+	return outbound.queued.Load() + outbound.buffered.Load()
+}
+
+// Admit
+//
+//	@Description: 发送消息前检查慢速状态
+//	@receiver outbound
+//	@param conn 会话的连接
+//	@param message 消息
+//	@return OutboundAction
+func (outbound *Outbound) Admit(conn *BaseConn, message any) OutboundAction {
+	if !conn.IsSlow() {
+		return OutboundSend
+	}
+	switch outbound.opts.Policy {
+	case BackpressureDrop:
+		if droppable, ok := message.(Droppable); ok && droppable.Droppable() {
+			return OutboundDrop
+		}
+	case BackpressureCoalesce:
+		if coalescable, ok := message.(Coalescable); ok {
+			outbound.hold(coalescable.CoalesceKey(), message)
+			return OutboundHold
+		}
+	case BackpressureClose:
+		return OutboundClose
+	}
+	return OutboundSend
+}
+
+func (outbound *Outbound) hold(key string, message any) {
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+	if outbound.held == nil {
+		outbound.held = make(map[string]any)
+	}
+	if _, ok := outbound.held[key]; !ok {
+		outbound.heldKeys = append(outbound.heldKeys, key)
+	}
+	outbound.held[key] = message
+}
+
+// Submit
+//
+//	@Description: 提交异步写出的数据
+//	@receiver outbound
+//	@param size 字节数
+func (outbound *Outbound) Submit(size int) {
+	outbound.queued.Add(int64(size))
+}
+
+// Cancel
+//
+//	@Description: 撤销未能提交的数据
+//	@receiver outbound
+//	@param size 字节数
+func (outbound *Outbound) Cancel(size int) {
+	outbound.queued.Add(-int64(size))
+}
+
+// Written
+//
+//	@Description: 事件循环已处理提交的数据，在写出回调中调用
+//	@receiver outbound
+//	@param size 字节数
+//	@param buffered 连接当前的出站缓冲字节数
+func (outbound *Outbound) Written(size int, buffered int) {
+	outbound.queued.Add(-int64(size))
+	outbound.buffered.Store(int64(buffered))
+}
+
+// Sample
+//
+//	@Description: 更新出站缓冲字节数，在事件循环中调用
+//	@receiver outbound
+//	@param buffered 连接当前的出站缓冲字节数
+func (outbound *Outbound) Sample(buffered int) {
+	outbound.buffered.Store(int64(buffered))
+}
+
+// Update
+//
+//	@Description: 按水位更新连接的慢速状态
+//	@receiver outbound
+//	@param conn 会话的连接
+//	@return changed 状态是否变化
+//	@return held 恢复时取出的暂存消息
+func (outbound *Outbound) Update(conn *BaseConn) (changed bool, held []any) {
+	size := outbound.Size()
+	if size >= int64(outbound.opts.HighWatermark) {
+		return conn.slow.CompareAndSwap(false, true), nil
+	}
+	if size > outbound.low || !conn.slow.CompareAndSwap(true, false) {
+		return false, nil
+	}
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+	for _, key := range outbound.heldKeys {
+		held = append(held, outbound.held[key])
+	}
+	outbound.heldKeys, outbound.held = nil, nil
+	return true, held
+}
+
+// BackpressureSession
+//
+//	@Description: 服务端会话可实现该接口以参与慢速会话的采样
+type BackpressureSession interface {
+	OwnedSession
+
+	// Backpressure
+	//	@Description: 会话的出站背压，未开启背压时为nil
+	//	@return *Backpressure
+	//
+	Backpressure() *Backpressure
+}
+
+// NewBackpressure
+//
+//	@Description: 构造会话的出站背压
+//	@param opts 背压配置
+//	@param name 服务名
+//	@param listener 会话监听器，实现 BackpressureListener 时通知慢速状态变化
+//	@param sess 会话
+//	@param conn 会话的连接
+//	@param gConn 会话的gnet连接
+//	@param closeSlow 关闭慢速会话
+//	@return *Backpressure 未配置背压时返回nil
+func NewBackpressure(opts *BackpressureOptions, name string, listener Listener,
+	sess Session, conn *BaseConn, gConn gnet.Conn, closeSlow func() error) *Backpressure {
+	outbound := NewOutbound(opts)
+	if outbound == nil {
+		return nil
+	}
+	return &Backpressure{
+		outbound:  outbound,
+		name:      name,
+		listener:  listener,
+		sess:      sess,
+		conn:      conn,
+		gConn:     gConn,
+		closeSlow: closeSlow,
+	}
+}
+
+// Backpressure
+//
+//	@Description: 会话的出站背压，按出站统计限制慢速会话的发送，协程安全
+type Backpressure struct {
+	outbound *Outbound
+	name     string
+	listener Listener
+	sess     Session
+	conn     *BaseConn
+	gConn    gnet.Conn
+	// 关闭慢速会话
+	closeSlow func() error
+}
+
+// Admit
+//
+//	@Description: 按会话慢速状态检查是否发送消息
+//	@receiver bp
+//	@param message
+//	@return bool 是否发送
+func (bp *Backpressure) Admit(message any) bool {
+	switch bp.outbound.Admit(bp.conn, message) {
+	case OutboundDrop:
+		plog.Debug("drop message of slow session:", pfield.Uint64("conn", bp.conn.Hash()))
+		return false
+	case OutboundHold:
+		return false
+	case OutboundClose:
+		bp.close()
+		return false
+	}
+	return true
+}
+
+// AdmitAll
+//
+//	@Description: 按会话慢速状态过滤待发送的消息
+//	@receiver bp
+//	@param messages
+//	@return []any 需发送的消息，会话已关闭时返回nil
+func (bp *Backpressure) AdmitAll(messages []any) []any {
+	if !bp.conn.IsSlow() {
+		return messages
+	}
+	admitted := make([]any, 0, len(messages))
+	for _, message := range messages {
+		if bp.Admit(message) {
+			admitted = append(admitted, message)
+		} else if closed, _ := bp.conn.IsClosed(); closed {
+			return nil
+		}
+	}
+	return admitted
+}
+
+// Track
+//
+//	@Description: 统计提交的出站数据，写出回调中更新出站缓冲字节数和慢速状态
+//	@receiver bp
+//	@param dataArr 提交的数据
+//	@param callback 写出完成回调
+//	@return int 提交的字节数，提交失败时需 Cancel
+//	@return func(c Conn, err error) error 包装后的回调
+func (bp *Backpressure) Track(dataArr [][]byte,
+	callback func(c Conn, err error) error) (int, func(c Conn, err error) error) {
+	size := 0
+	for _, data := range dataArr {
+		size += len(data)
+	}
+	bp.outbound.Submit(size)
+	return size, func(c Conn, err error) error {
+		bp.outbound.Written(size, bp.gConn.OutboundBuffered())
+		bp.Update()
+		return callback(c, err)
+	}
+}
+
+// Cancel
+//
+//	@Description: 撤销未能提交的数据
+//	@receiver bp
+//	@param size Track 返回的字节数
+func (bp *Backpressure) Cancel(size int) {
+	bp.outbound.Cancel(size)
+}
+
+// Update
+//
+//	@Description: 出站字节数变化后更新会话的慢速状态，恢复时发送暂存的消息
+//	@receiver bp
+func (bp *Backpressure) Update() {
+	changed, held := bp.outbound.Update(bp.conn)
+	if !changed {
+		return
+	}
+	slow := bp.conn.IsSlow()
+	plog.Debug("session backpressure changed:",
+		pfield.String("server", bp.name),
+		pfield.Uint64("conn", bp.conn.Hash()),
+		pfield.Bool("slow", slow),
+		pfield.Int64("outbound", bp.outbound.Size()))
+	if listener, ok := bp.listener.(BackpressureListener); ok {
+		listener.OnBackpressure(bp.sess, slow)
+	}
+	if slow && bp.outbound.opts.Policy == BackpressureClose {
+		bp.close()
+		return
+	}
+	if len(held) > 0 {
+		bp.sess.SendMessages(held...)
+	}
+}
+
+// sample
+//
+//	@Description: 在事件循环中采样出站缓冲，出站缓冲在写出时不会触发回调
+//	@receiver bp
+func (bp *Backpressure) sample() {
+	err := bp.gConn.Wake(func(c gnet.Conn, err error) error {
+		if err == nil {
+			bp.outbound.Sample(c.OutboundBuffered())
+			bp.Update()
+		}
+		return nil
+	})
+	if err != nil {
+		plog.Debug("wake connection error:", pfield.Error(err))
+	}
+}
+
+func (bp *Backpressure) close() {
+	plog.Debug("close slow session:",
+		pfield.String("server", bp.name),
+		pfield.Uint64("conn", bp.conn.Hash()))
+	if err := bp.closeSlow(); err != nil {
+		plog.Error("close slow session error:", pfield.Error(err))
+	}
+}
+
+// CheckSlowSessions
+//
+//	@Description: 采样服务接受的慢速会话的出站缓冲
+//	@param manager 会话管理器
+//	@param owner 服务，仅采样其接受的会话
+func CheckSlowSessions(manager *Manager, owner any) {
+	manager.RangeSessions(func(sess Session) bool {
+		bpSess, ok := sess.(BackpressureSession)
+		if !ok || bpSess.Owner() != owner || bpSess.IsClosed() {
+			return true
+		}
+		if bp := bpSess.Backpressure(); bp != nil && bp.conn.IsSlow() {
+			bp.sample()
+		}
+		return true
+	})
+}
@@ -0,0 +1,114 @@
+package session
+
+// OwnedSession
+//
+//	@Description: 由服务接受的会话，共享管理器时用于区分会话所属的服务
+type OwnedSession interface {
+	Session
+
+	// Owner
+	//	@Description: 接受该会话的服务
+	//	@return any
+	//
+	Owner() any
+}
+
+// OwnSession
+//
+//	@Description: 获取由服务接受的会话，共享管理器时忽略其他服务的会话
+//	@param sess 会话
+//	@param owner 服务
+//	@return S
+//	@return bool
+func OwnSession[S OwnedSession](sess Session, owner any) (S, bool) {
+	svrSess, ok := sess.(S)
+	if !ok || svrSess.Owner() != owner {
+		var empty S
+		return empty, false
+	}
+	return svrSess, true
+}
+
+// NewBroadcaster
+//
+//	@Description: 构造服务的广播器
+//	@param manager 会话管理器
+//	@param owner 服务，仅向其接受的会话发送
+//	@param encode 消息编码函数
+//	@param send 发送编码后的消息
+//	@return *Broadcaster[S, E]
+func NewBroadcaster[S OwnedSession, E any](manager *Manager, owner any,
+	encode func(message any) (E, error), send func(sess S, encoded E, message any)) *Broadcaster[S, E] {
+	return &Broadcaster[S, E]{
+		manager: manager,
+		owner:   owner,
+		encode:  encode,
+		send:    send,
+	}
+}
+
+// Broadcaster
+//
+//	@Description: 服务的广播器，消息仅编码一次后发送给服务接受的会话
+type Broadcaster[S OwnedSession, E any] struct {
+	manager *Manager
+	owner   any
+	encode  func(message any) (E, error)
+	send    func(sess S, encoded E, message any)
+}
+
+// Broadcast
+//
+//	@Description: 向分组成员广播消息，消息仅编码一次
+//	@receiver broadcaster
+//	@param groupId 分组编号
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (broadcaster *Broadcaster[S, E]) Broadcast(groupId uint64, message any, exclude ...Session) error {
+	members, err := broadcaster.manager.GroupMembers(groupId)
+	if err != nil {
+		return err
+	}
+	if len(members) <= 0 {
+		return nil
+	}
+	encoded, err := broadcaster.encode(message)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		broadcaster.sendShared(member, encoded, message, exclude)
+	}
+	return nil
+}
+
+// BroadcastAll
+//
+//	@Description: 向所有已注册会话广播消息，消息仅编码一次
+//	@receiver broadcaster
+//	@param message 消息
+//	@param exclude 排除的会话
+//	@return error
+func (broadcaster *Broadcaster[S, E]) BroadcastAll(message any, exclude ...Session) error {
+	encoded, err := broadcaster.encode(message)
+	if err != nil {
+		return err
+	}
+	broadcaster.manager.RangeRegisteredSessions(func(sess Session) bool {
+		broadcaster.sendShared(sess, encoded, message, exclude)
+		return true
+	})
+	return nil
+}
+
+func (broadcaster *Broadcaster[S, E]) sendShared(sess Session, encoded E, message any, exclude []Session) {
+	for _, excluded := range exclude {
+		if excluded == sess {
+			return
+		}
+	}
+	if svrSess, ok := OwnSession[S](sess, broadcaster.owner); ok {
+		broadcaster.send(svrSess, encoded, message)
+	}
+}
@@ -0,0 +1,158 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"github.com/meow-pad/persian/frame/pnet"
+)
+
+// CloseCategory
+//
+//	@Description: 会话关闭原因的分类
+type CloseCategory uint8
+
+const (
+	// CloseNormal 正常关闭，如本端调用 Close 或对端正常关闭websocket
+	CloseNormal CloseCategory = iota
+	// CloseNetwork 网络错误或对端断开连接
+	CloseNetwork
+	// CloseProtocol 编解码、握手等协议错误
+	CloseProtocol
+	// CloseIdle 心跳超时
+	CloseIdle
+	// CloseExpired 未注册会话超时或已注册会话失活
+	CloseExpired
+	// CloseKicked 被同编号的新会话替换，如重复登录或会话恢复
+	CloseKicked
+	// CloseLimited 超出速率、连接数、读写缓冲等限制或慢速
+	CloseLimited
+	// CloseShutdown 停服
+	CloseShutdown
+)
+
+var closeCategoryNames = [...]string{
+	CloseNormal:   "normal",
+	CloseNetwork:  "network",
+	CloseProtocol: "protocol",
+	CloseIdle:     "idle",
+	CloseExpired:  "expired",
+	CloseKicked:   "kicked",
+	CloseLimited:  "limited",
+	CloseShutdown: "shutdown",
+}
+
+func (category CloseCategory) String() string {
+	if int(category) < len(closeCategoryNames) {
+		return closeCategoryNames[category]
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(category))
+}
+
+// CloseInitiator
+//
+//	@Description: 关闭的发起方
+type CloseInitiator uint8
+
+const (
+	// CloseByLocal 本端发起
+	CloseByLocal CloseInitiator = iota
+	// CloseByPeer 对端发起或网络中断
+	CloseByPeer
+)
+
+// CloseReason
+//
+//	@Description: 结构化的会话关闭原因，在 Listener.OnClosed 中传递
+type CloseReason struct {
+	Category  CloseCategory
+	Initiator CloseInitiator
+	// 底层错误，本端正常关闭时为nil
+	Err error
+}
+
+// NewCloseReason
+//
+//	@Description: 构造关闭原因
+//	@param category 分类
+//	@param initiator 发起方
+//	@param err 底层错误
+//	@return *CloseReason
+func NewCloseReason(category CloseCategory, initiator CloseInitiator, err error) *CloseReason {
+	return &CloseReason{Category: category, Initiator: initiator, Err: err}
+}
+
+func (reason *CloseReason) Error() string {
+	initiator := "local"
+	if reason.Initiator == CloseByPeer {
+		initiator = "peer"
+	}
+	if reason.Err == nil {
+		return fmt.Sprintf("closed by %s: %s", initiator, reason.Category)
+	}
+	return fmt.Sprintf("closed by %s: %s: %v", initiator, reason.Category, reason.Err)
+}
+
+func (reason *CloseReason) Unwrap() error {
+	return reason.Err
+}
+
+// ResolveCloseReason
+//
+//	@Description: 将连接记录的关闭原因转换为结构化的关闭原因
+//	@param err 连接记录的关闭原因，即 Conn.IsClosed 返回的错误
+//	@return *CloseReason
+func ResolveCloseReason(err error) *CloseReason {
+	var reason *CloseReason
+	if errors.As(err, &reason) {
+		return reason
+	}
+	switch {
+	case err == nil, errors.Is(err, pnet.ErrClientActiveClose):
+		return NewCloseReason(CloseNormal, CloseByLocal, err)
+	case errors.Is(err, pnet.ErrIdleTimeout):
+		return NewCloseReason(CloseIdle, CloseByLocal, err)
+	case errors.Is(err, pnet.ErrSessionExpired):
+		return NewCloseReason(CloseExpired, CloseByLocal, err)
+	case errors.Is(err, pnet.ErrSessionKicked):
+		return NewCloseReason(CloseKicked, CloseByLocal, err)
+	case errors.Is(err, pnet.ErrServerDraining), errors.Is(err, pnet.ErrServerStopped):
+		return NewCloseReason(CloseShutdown, CloseByLocal, err)
+	case errors.Is(err, pnet.ErrRateLimited), errors.Is(err, pnet.ErrSlowConsumer),
+		errors.Is(err, pnet.ErrOutOfReadCap), errors.Is(err, pnet.ErrOutOfWriteCap),
+		errors.Is(err, pnet.ErrWriteQueueFull):
+		return NewCloseReason(CloseLimited, CloseByLocal, err)
+	case errors.Is(err, ErrInvalidProxyHeader), errors.Is(err, ErrLessProxyHeader),
+		errors.Is(err, pnet.ErrInvalidMagic), errors.Is(err, pnet.ErrMessageTooLarge):
+		return NewCloseReason(CloseProtocol, CloseByLocal, err)
+	default:
+		return NewCloseReason(CloseNetwork, CloseByPeer, err)
+	}
+}
+
+// WebSocketCloseCategory
+//
+//	@Description: websocket关闭码对应的分类
+//	@param code 关闭码
+//	@param remote 是否由对端发起
+//	@return CloseCategory
+func WebSocketCloseCategory(code int, remote bool) CloseCategory {
+	switch code {
+	case 1000, 1005:
+		// 正常关闭或未携带关闭码
+		return CloseNormal
+	case 1001:
+		// 本端发出的going away仅用于停服
+		if remote {
+			return CloseNormal
+		}
+		return CloseShutdown
+	case 1006:
+		// 异常断开
+		return CloseNetwork
+	case 1008, 1013:
+		// 违反策略或稍后重试
+		return CloseLimited
+	default:
+		return CloseProtocol
+	}
+}
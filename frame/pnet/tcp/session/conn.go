@@ -5,7 +5,9 @@ import (
 	"github.com/panjf2000/gnet/v2"
 	"io"
 	"net"
+	"strconv"
 	"sync/atomic"
+	"time"
 )
 
 type Writer interface {
@@ -69,41 +71,156 @@ type Conn interface {
 	//  @return error closed reason
 	//
 	IsClosed() (bool, error)
+
+	// ToClosed
+	//	@Description: 转换到关闭状态并记录关闭原因，仅首次记录的原因有效
+	//	@param reason 关闭原因
+	//	@return bool 是否由本次转换
+	//
+	ToClosed(reason error) bool
+
+	// LastReadTime
+	//	@Description: 最后一次收到数据的时间
+	//	@return time.Time
+	//
+	LastReadTime() time.Time
+
+	// LastWriteTime
+	//	@Description: 最后一次成功发送数据的时间
+	//	@return time.Time
+	//
+	LastWriteTime() time.Time
+
+	// ProxyHeader
+	//	@Description: PROXY协议头，未使用PROXY协议时为nil；有真实地址时 RemoteAddr 与 LocalAddr 返回真实地址
+	//	@return *ProxyHeader
+	//
+	ProxyHeader() *ProxyHeader
+
+	// IsSlow
+	//	@Description: 是否为慢速连接，出站字节数达到背压高水位后为true，降至低水位以下后恢复
+	//	@return bool
+	//
+	IsSlow() bool
 }
 
 type BaseConn struct {
-	hash     uint64
+	hash     atomic.Uint64
+	proxy    atomic.Pointer[ProxyHeader]
 	closed   atomic.Bool
-	closeErr error
+	slow     atomic.Bool
+	closeErr atomic.Pointer[error]
+	// 最后读、写及心跳时间，单位毫秒
+	lastRead      atomic.Int64
+	lastWrite     atomic.Int64
+	lastHeartbeat atomic.Int64
 }
 
 func (conn *BaseConn) Init(pConn net.Conn, fromClient bool) error {
 	if pConn == nil {
 		return errdef.ErrNilValue
 	}
-	addr := pConn.RemoteAddr().String()
+	netAddr := pConn.RemoteAddr()
 	if fromClient {
-		addr = pConn.LocalAddr().String()
+		netAddr = pConn.LocalAddr()
 	}
-	for _, ch := range addr {
-		conn.hash = 31*conn.hash + uint64(uint32(ch))
+	var addr string
+	if netAddr != nil {
+		addr = netAddr.String()
+	}
+	if netAddr == nil || netAddr.Network() == "unix" {
+		// unix socket的客户端地址通常为空，以序号区分连接
+		addr = addr + "#" + strconv.FormatUint(unixConnSeq.Add(1), 10)
 	}
+	conn.hash.Store(hashAddr(addr))
 	conn.closed.Store(false)
+	now := time.Now().UnixMilli()
+	conn.lastRead.Store(now)
+	conn.lastWrite.Store(now)
 	return nil
 }
 
+// unixConnSeq unix socket连接序号
+var unixConnSeq atomic.Uint64
+
+func hashAddr(addr string) (hash uint64) {
+	for _, ch := range addr {
+		hash = 31*hash + uint64(uint32(ch))
+	}
+	return
+}
+
 func (conn *BaseConn) Hash() uint64 {
-	return conn.hash
+	return conn.hash.Load()
+}
+
+func (conn *BaseConn) ProxyHeader() *ProxyHeader {
+	return conn.proxy.Load()
+}
+
+// SetProxyHeader
+//
+//	@Description: 设置PROXY协议头，有真实地址时按客户端地址重新计算hash
+//	@receiver conn
+//	@param header
+func (conn *BaseConn) SetProxyHeader(header *ProxyHeader) {
+	conn.proxy.Store(header)
+	if header != nil && header.Source != nil {
+		conn.hash.Store(hashAddr(header.Source.String()))
+	}
+}
+
+// ProxiedAddr
+//
+//	@Description: 获取PROXY协议头中的真实地址
+//	@receiver conn
+//	@param remote 是否为对端地址
+//	@return net.Addr 没有真实地址时为nil
+func (conn *BaseConn) ProxiedAddr(remote bool) net.Addr {
+	header := conn.proxy.Load()
+	if header == nil || header.Local {
+		return nil
+	}
+	if remote {
+		return header.Source
+	}
+	return header.Destination
+}
+
+func (conn *BaseConn) IsSlow() bool {
+	return conn.slow.Load()
 }
 
 func (conn *BaseConn) IsClosed() (bool, error) {
-	return conn.closed.Load(), conn.closeErr
+	if !conn.closed.Load() {
+		return false, nil
+	}
+	if reason := conn.closeErr.Load(); reason != nil {
+		return true, *reason
+	}
+	return true, nil
 }
 
 func (conn *BaseConn) ToClosed(reason error) bool {
 	if conn.closed.CompareAndSwap(false, true) {
-		conn.closeErr = reason
+		conn.closeErr.Store(&reason)
 		return true
 	}
 	return false
 }
+
+func (conn *BaseConn) LastReadTime() time.Time {
+	return time.UnixMilli(conn.lastRead.Load())
+}
+
+func (conn *BaseConn) LastWriteTime() time.Time {
+	return time.UnixMilli(conn.lastWrite.Load())
+}
+
+func (conn *BaseConn) UpdateReadTime() {
+	conn.lastRead.Store(time.Now().UnixMilli())
+}
+
+func (conn *BaseConn) UpdateWriteTime() {
+	conn.lastWrite.Store(time.Now().UnixMilli())
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/utils/coding"
+	"github.com/meow-pad/persian/utils/worker"
+	"sync/atomic"
+)
+
+// DispatchPolicy
+//
+//	@Description: 工作队列已满时的处理策略
+type DispatchPolicy uint8
+
+const (
+	// DispatchBlock 阻塞等待队列空闲（会阻塞网络事件循环）
+	DispatchBlock DispatchPolicy = iota
+	// DispatchDrop 丢弃消息
+	DispatchDrop
+	// DispatchClose 丢弃消息并关闭会话
+	DispatchClose
+)
+
+// DispatchStats
+//
+//	@Description: 分发统计
+type DispatchStats struct {
+	// 提交成功次数
+	Submitted uint64
+	// 因队列满丢弃的次数
+	Dropped uint64
+	// 因队列满关闭会话的次数
+	Closed uint64
+}
+
+type DispatcherOption func(dispatcher *Dispatcher)
+
+// WithDispatchKey
+//
+//	@Description: 设置会话分组函数，同组消息按序执行
+//	@param keyFunc
+//	@return DispatcherOption
+func WithDispatchKey(keyFunc func(sess Session) int) DispatcherOption {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.keyFunc = keyFunc
+	}
+}
+
+// WithDispatchFullHandler
+//
+//	@Description: 设置队列满时的回调
+//	@param handler
+//	@return DispatcherOption
+func WithDispatchFullHandler(handler func(sess Session, err error)) DispatcherOption {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.onFull = handler
+	}
+}
+
+func NewDispatcher(pool *worker.FixedWorkerPool, policy DispatchPolicy, opts ...DispatcherOption) (*Dispatcher, error) {
+	if pool == nil || policy > DispatchClose {
+		return nil, errdef.ErrInvalidParams
+	}
+	dispatcher := &Dispatcher{
+		pool:    pool,
+		policy:  policy,
+		keyFunc: connKey,
+	}
+	for _, opt := range opts {
+		opt(dispatcher)
+	}
+	if dispatcher.keyFunc == nil {
+		dispatcher.keyFunc = connKey
+	}
+	return dispatcher, nil
+}
+
+// connKey
+//
+//	@Description: 默认按连接分组，注册前后分组保持不变
+//	@param sess
+//	@return int
+func connKey(sess Session) int {
+	return int(sess.Connection().Hash() >> 1)
+}
+
+// Dispatcher
+//
+//	@Description: 将已解码消息按会话分组提交到工作池执行，保证同一会话内消息有序
+type Dispatcher struct {
+	pool    *worker.FixedWorkerPool
+	policy  DispatchPolicy
+	keyFunc func(sess Session) int
+	onFull  func(sess Session, err error)
+
+	submitted atomic.Uint64
+	dropped   atomic.Uint64
+	closed    atomic.Uint64
+}
+
+// Dispatch
+//
+//	@Description: 提交消息到工作池并在工作协程中通知监听器
+//	@receiver dispatcher
+//	@param sess 会话
+//	@param listener 监听器
+//	@param msgArr 消息集合
+//	@param totalLen 消息总长度
+//	@return error 提交失败时返回工作池错误
+func (dispatcher *Dispatcher) Dispatch(sess Session, listener Listener, msgArr []any, totalLen int) error {
+	msgNum := len(msgArr)
+	if msgNum <= 0 {
+		return nil
+	}
+	err := dispatcher.pool.SubmitWithBlocking(dispatcher.keyFunc(sess), func(_ *worker.GoroutineLocal) {
+		defer coding.CatchPanicError("dispatch message error:", nil)
+		if msgNum > 1 {
+			_ = listener.OnReceiveMulti(sess, msgArr, totalLen)
+		} else {
+			_ = listener.OnReceive(sess, msgArr[0], totalLen)
+		}
+	}, dispatcher.policy == DispatchBlock)
+	if err == nil {
+		dispatcher.submitted.Add(1)
+		return nil
+	}
+	switch dispatcher.policy {
+	case DispatchClose:
+		dispatcher.closed.Add(1)
+		plog.Warn("dispatch queue is full, close session",
+			pfield.Uint64("conn", sess.Connection().Hash()), pfield.Error(err))
+		if cErr := sess.Close(); cErr != nil {
+			plog.Error("close session error:", pfield.Error(cErr))
+		}
+	default:
+		dispatcher.dropped.Add(1)
+		plog.Warn("dispatch queue is full, drop messages",
+			pfield.Uint64("conn", sess.Connection().Hash()),
+			pfield.Int("msgNum", msgNum), pfield.Error(err))
+	}
+	if dispatcher.onFull != nil {
+		dispatcher.onFull(sess, err)
+	}
+	return err
+}
+
+// DispatchClosed
+//
+//	@Description: 通过会话所在的工作队列通知关闭，保证在已提交的消息之后执行，通知后清理会话属性；
+//	该通知总是阻塞提交，提交失败时直接在当前协程通知
+//	@receiver dispatcher
+//	@param sess 会话
+//	@param listener 监听器
+//	@param reason 关闭原因
+func (dispatcher *Dispatcher) DispatchClosed(sess Session, listener Listener, reason *CloseReason) {
+	notify := func() {
+		defer coding.CatchPanicError("dispatch closed error:", nil)
+		defer sess.Attributes().Clear()
+		listener.OnClosed(sess, reason)
+	}
+	err := dispatcher.pool.SubmitWithBlocking(dispatcher.keyFunc(sess), func(_ *worker.GoroutineLocal) {
+		notify()
+	}, true)
+	if err != nil {
+		plog.Warn("dispatch closed error, notify directly",
+			pfield.Uint64("conn", sess.Connection().Hash()), pfield.Error(err))
+		notify()
+	}
+}
+
+// Stats
+//
+//	@Description: 获取分发统计
+//	@receiver dispatcher
+//	@return DispatchStats
+func (dispatcher *Dispatcher) Stats() DispatchStats {
+	return DispatchStats{
+		Submitted: dispatcher.submitted.Load(),
+		Dropped:   dispatcher.dropped.Load(),
+		Closed:    dispatcher.closed.Load(),
+	}
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"context"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"time"
+)
+
+const (
+	// drainPollInterval 排空时检查连接数的间隔
+	drainPollInterval = 50 * time.Millisecond
+)
+
+// DrainOptions
+//
+//	@Description: 停服排空配置
+//		停服时不再接受新连接，通知已注册会话后等待客户端断开，超时后强制关闭剩余会话
+type DrainOptions struct {
+	// 停服通知消息构造函数，经编解码器发送给所有已注册会话；为nil时不通知
+	Message func() any
+	// 停止上下文未设置截止时间时的最长等待时间，为0时不等待
+	Timeout time.Duration
+}
+
+// Wait
+//
+//	@Description: 等待连接全部断开
+//	@receiver opts
+//	@param ctx 停止上下文，截止时间即等待的截止时间
+//	@param count 获取当前连接数
+//	@return int 等待结束时剩余的连接数
+func (opts *DrainOptions) Wait(ctx context.Context, count func() int) int {
+	if _, ok := ctx.Deadline(); !ok {
+		if opts.Timeout <= 0 {
+			return count()
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	return waitDrained(ctx, count)
+}
+
+// Drain
+//
+//	@Description: 通知已注册会话停服，等待客户端断开后强制关闭剩余会话并等待关闭完成，
+//	所有等待均以停止上下文为界
+//	@receiver opts
+//	@param ctx 停止上下文
+//	@param name 服务名
+//	@param count 获取当前连接数
+//	@param broadcast 向所有已注册会话广播消息
+//	@param closeRemain 强制关闭剩余会话，返回关闭的会话数
+func (opts *DrainOptions) Drain(ctx context.Context, name string, count func() int,
+	broadcast func(message any) error, closeRemain func() int) {
+	total := count()
+	if opts.Message != nil {
+		if err := broadcast(opts.Message()); err != nil {
+			plog.Error("broadcast drain message error:", pfield.String("server", name), pfield.Error(err))
+		}
+	}
+	remain := opts.Wait(ctx, count)
+	forced := 0
+	if remain > 0 {
+		forced = closeRemain()
+		// 等待强制关闭完成，关闭前会写出已提交的数据
+		waitDrained(ctx, count)
+	}
+	plog.Info("server drained",
+		pfield.String("server", name),
+		pfield.Int("connections", total),
+		pfield.Int("disconnected", total-remain),
+		pfield.Int("forced", forced))
+}
+
+// waitDrained
+//
+//	@Description: 等待连接全部断开或上下文结束
+//	@param ctx
+//	@param count 获取当前连接数
+//	@return int 等待结束时剩余的连接数
+func waitDrained(ctx context.Context, count func() int) int {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		n := count()
+		if n <= 0 || ctx.Err() != nil {
+			return n
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
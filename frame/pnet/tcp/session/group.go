@@ -0,0 +1,177 @@
+package session
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrGroupExists    = errors.New("group already exists")
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrInvalidSession = errors.New("invalid session")
+)
+
+// groupSet
+//
+//	@Description: 会话分组集合
+type groupSet struct {
+	mutex sync.RWMutex
+	// 分组成员
+	groups map[uint64]map[Session]struct{}
+	// 会话所在分组
+	memberGroups map[Session]map[uint64]struct{}
+}
+
+func (set *groupSet) init() {
+	set.groups = make(map[uint64]map[Session]struct{})
+	set.memberGroups = make(map[Session]map[uint64]struct{})
+}
+
+// CreateGroup
+//
+//	@Description: 创建分组
+//	@receiver manager
+//	@param groupId 分组编号
+//	@return error
+func (manager *Manager) CreateGroup(groupId uint64) error {
+	set := &manager.groupSet
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	if _, ok := set.groups[groupId]; ok {
+		return ErrGroupExists
+	}
+	set.groups[groupId] = make(map[Session]struct{})
+	return nil
+}
+
+// DestroyGroup
+//
+//	@Description: 销毁分组
+//	@receiver manager
+//	@param groupId 分组编号
+//	@return error
+func (manager *Manager) DestroyGroup(groupId uint64) error {
+	set := &manager.groupSet
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	members, ok := set.groups[groupId]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	for member := range members {
+		set.leave(groupId, member)
+	}
+	delete(set.groups, groupId)
+	return nil
+}
+
+// JoinGroup
+//
+//	@Description: 加入分组，已关闭的会话无法加入
+//	@receiver manager
+//	@param groupId 分组编号
+//	@param sess 会话
+//	@return error
+func (manager *Manager) JoinGroup(groupId uint64, sess Session) error {
+	if sess == nil {
+		return ErrInvalidSession
+	}
+	set := &manager.groupSet
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	// 会话先转为关闭状态再离开所有分组，持锁检查可避免关闭后残留在分组中
+	if sess.IsClosed() {
+		return ErrInvalidSession
+	}
+	members, ok := set.groups[groupId]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	members[sess] = struct{}{}
+	groupIds := set.memberGroups[sess]
+	if groupIds == nil {
+		groupIds = make(map[uint64]struct{})
+		set.memberGroups[sess] = groupIds
+	}
+	groupIds[groupId] = struct{}{}
+	return nil
+}
+
+// LeaveGroup
+//
+//	@Description: 离开分组
+//	@receiver manager
+//	@param groupId 分组编号
+//	@param sess 会话
+func (manager *Manager) LeaveGroup(groupId uint64, sess Session) {
+	set := &manager.groupSet
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	set.leave(groupId, sess)
+}
+
+// GroupMembers
+//
+//	@Description: 获取分组成员快照
+//	@receiver manager
+//	@param groupId 分组编号
+//	@return []Session
+//	@return error
+func (manager *Manager) GroupMembers(groupId uint64) ([]Session, error) {
+	set := &manager.groupSet
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	members, ok := set.groups[groupId]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	result := make([]Session, 0, len(members))
+	for member := range members {
+		result = append(result, member)
+	}
+	return result, nil
+}
+
+// SessionGroups
+//
+//	@Description: 获取会话所在的分组
+//	@receiver manager
+//	@param sess 会话
+//	@return []uint64
+func (manager *Manager) SessionGroups(sess Session) []uint64 {
+	set := &manager.groupSet
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	groupIds := set.memberGroups[sess]
+	result := make([]uint64, 0, len(groupIds))
+	for groupId := range groupIds {
+		result = append(result, groupId)
+	}
+	return result
+}
+
+// leaveAllGroups
+//
+//	@Description: 离开所有分组
+//	@receiver manager
+//	@param sess 会话
+func (manager *Manager) leaveAllGroups(sess Session) {
+	set := &manager.groupSet
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	for groupId := range set.memberGroups[sess] {
+		set.leave(groupId, sess)
+	}
+}
+
+func (set *groupSet) leave(groupId uint64, sess Session) {
+	if members, ok := set.groups[groupId]; ok {
+		delete(members, sess)
+	}
+	if groupIds, ok := set.memberGroups[sess]; ok {
+		delete(groupIds, groupId)
+		if len(groupIds) <= 0 {
+			delete(set.memberGroups, sess)
+		}
+	}
+}
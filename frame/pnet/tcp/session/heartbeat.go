@@ -0,0 +1,79 @@
+package session
+
+import (
+	"time"
+)
+
+// IdleAction
+//
+//	@Description: 空闲检查结果
+type IdleAction uint8
+
+const (
+	// IdleNone 无需处理
+	IdleNone IdleAction = iota
+	// IdleHeartbeat 需要发送心跳
+	IdleHeartbeat
+	// IdleClose 心跳超时需要关闭
+	IdleClose
+)
+
+// HeartbeatOptions
+//
+//	@Description: 心跳与空闲检测配置
+type HeartbeatOptions struct {
+	// 未收到数据多久后发送心跳
+	Interval time.Duration
+	// 连续未响应的心跳次数上限，超过后关闭会话；小于1时按1处理，关闭前至少发送一次心跳
+	MaxMissed int
+	// 心跳消息构造函数，经编解码器发送；为nil时仅做空闲检测（websocket使用ping帧）
+	Message func() any
+}
+
+// TickInterval
+//
+//	@Description: 空闲检查的间隔
+//	@receiver opts
+//	@return time.Duration
+func (opts *HeartbeatOptions) TickInterval() time.Duration {
+	tick := opts.Interval / 2
+	if tick <= 0 {
+		tick = opts.Interval
+	}
+	return tick
+}
+
+// CheckIdle
+//
+//	@Description: 根据最后读取时间检查连接是否空闲
+//	@receiver conn
+//	@param opts 心跳配置
+//	@param now 当前时间
+//	@return IdleAction
+func (conn *BaseConn) CheckIdle(opts *HeartbeatOptions, now time.Time) IdleAction {
+	if opts == nil || opts.Interval <= 0 {
+		return IdleNone
+	}
+	nowMilli := now.UnixMilli()
+	interval := opts.Interval.Milliseconds()
+	lastRead := conn.lastRead.Load()
+	idle := nowMilli - lastRead
+	if idle < interval {
+		return IdleNone
+	}
+	// 静默期间每个间隔视为一次未响应的心跳
+	maxMissed := int64(opts.MaxMissed)
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+	if idle/interval > maxMissed {
+		return IdleClose
+	}
+	lastHeartbeat := conn.lastHeartbeat.Load()
+	if lastHeartbeat < lastRead || nowMilli-lastHeartbeat >= interval {
+		if conn.lastHeartbeat.CompareAndSwap(lastHeartbeat, nowMilli) {
+			return IdleHeartbeat
+		}
+	}
+	return IdleNone
+}
@@ -0,0 +1,370 @@
+package session
+
+import (
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LimitKind
+//
+//	@Description: 触发的限制类型
+type LimitKind uint8
+
+const (
+	// LimitMessageRate 会话消息速率
+	LimitMessageRate LimitKind = iota + 1
+	// LimitByteRate 会话字节速率
+	LimitByteRate
+	// LimitIPConnections 单IP并发连接数
+	LimitIPConnections
+	// LimitConnections 全局并发连接数
+	LimitConnections
+)
+
+// LimitPolicy
+//
+//	@Description: 超出速率限制时的处理策略
+type LimitPolicy uint8
+
+const (
+	// LimitDrop 丢弃消息
+	LimitDrop LimitPolicy = iota
+	// LimitDelay 暂停读取，待令牌恢复后再投递消息
+	LimitDelay
+	// LimitClose 关闭会话
+	LimitClose
+)
+
+// LimitEvent
+//
+//	@Description: 限制触发事件
+type LimitEvent struct {
+	Kind LimitKind
+	// 处理策略，连接数限制总是拒绝连接
+	Policy LimitPolicy
+	// 对端地址
+	RemoteAddr net.Addr
+	// 延迟投递的等待时间，仅 LimitDelay 策略有值
+	Delay time.Duration
+}
+
+// LimitListener
+//
+//	@Description: 会话监听器可实现该接口以获知触发的限制
+type LimitListener interface {
+
+	// OnLimited
+	//	@Description: 触发限制，在事件循环中调用
+	//	@param session 会话，连接数限制拒绝的连接为nil
+	//	@param event
+	//
+	OnLimited(session Session, event LimitEvent)
+}
+
+// LimitOptions
+//
+//	@Description: 速率与连接数限制配置，值为0时不限制
+type LimitOptions struct {
+	// 每个会话每秒消息数
+	MessagesPerSecond float64
+	// 消息突发上限，为0时使用每秒消息数
+	MessageBurst int
+	// 每个会话每秒字节数
+	BytesPerSecond float64
+	// 字节突发上限，为0时使用每秒字节数
+	ByteBurst int
+	// 超出速率限制时的处理策略
+	Policy LimitPolicy
+	// 单IP并发连接数上限
+	MaxConnectionsPerIP int
+	// 全局并发连接数上限
+	MaxConnections int
+}
+
+// rateLimited
+//
+//	@Description: 是否限制会话速率
+//	@receiver opts
+//	@return bool
+func (opts *LimitOptions) rateLimited() bool {
+	return opts.MessagesPerSecond > 0 || opts.BytesPerSecond > 0
+}
+
+// tokenBucket
+//
+//	@Description: 令牌桶，剩余至少一个令牌时即放行并允许透支，透支部分需等待恢复
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (bucket *tokenBucket) init(rate float64, burst int, now time.Time) {
+	bucket.rate = rate
+	bucket.burst = float64(burst)
+	if bucket.burst <= 0 {
+		bucket.burst = rate
+	}
+	bucket.tokens = bucket.burst
+	bucket.last = now
+}
+
+func (bucket *tokenBucket) enabled() bool {
+	return bucket.rate > 0
+}
+
+func (bucket *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(bucket.last).Seconds(); elapsed > 0 {
+		bucket.tokens += elapsed * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+	}
+	bucket.last = now
+}
+
+// wait
+//
+//	@Description: 距离令牌恢复到可放行的等待时间
+//	@receiver bucket
+//	@return time.Duration
+func (bucket *tokenBucket) wait() time.Duration {
+	if bucket.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1-bucket.tokens)/bucket.rate*float64(time.Second)) + time.Millisecond
+}
+
+// NewLimiter
+//
+//	@Description: 构造会话的速率限制器
+//	@param opts
+//	@return *Limiter 未配置速率限制时返回nil
+func NewLimiter(opts *LimitOptions) *Limiter {
+	if opts == nil || !opts.rateLimited() {
+		return nil
+	}
+	now := time.Now()
+	limiter := &Limiter{opts: opts}
+	if opts.MessagesPerSecond > 0 {
+		limiter.messages.init(opts.MessagesPerSecond, opts.MessageBurst, now)
+	}
+	if opts.BytesPerSecond > 0 {
+		limiter.bytes.init(opts.BytesPerSecond, opts.ByteBurst, now)
+	}
+	return limiter
+}
+
+// Limiter
+//
+//	@Description: 会话的速率限制器，除 Wake 外仅在事件循环中访问
+type Limiter struct {
+	opts     *LimitOptions
+	messages tokenBucket
+	bytes    tokenBucket
+	// 延迟投递的消息
+	pending    []any
+	pendingLen int
+	// 是否已安排唤醒
+	waking atomic.Bool
+}
+
+// Pending
+//
+//	@Description: 是否有延迟投递的消息，有则暂停解码新数据
+//	@receiver limiter
+//	@return bool
+func (limiter *Limiter) Pending() bool {
+	return len(limiter.pending) > 0
+}
+
+// Admit
+//
+//	@Description: 检查消息速率，先投递延迟的消息
+//	@receiver limiter
+//	@param now
+//	@param msgArr 本次解码的消息
+//	@param totalLen 消息总长度
+//	@return []any 放行的消息
+//	@return int 放行的消息长度
+//	@return *LimitEvent 触发的限制，未触发时为nil
+func (limiter *Limiter) Admit(now time.Time, msgArr []any, totalLen int) ([]any, int, *LimitEvent) {
+	if limiter.Pending() {
+		msgArr = append(limiter.pending, msgArr...)
+		totalLen += limiter.pendingLen
+		limiter.pending, limiter.pendingLen = nil, 0
+	}
+	if len(msgArr) <= 0 {
+		return msgArr, totalLen, nil
+	}
+	var kind LimitKind
+	var wait time.Duration
+	if limiter.messages.enabled() {
+		limiter.messages.refill(now)
+		if wait = limiter.messages.wait(); wait > 0 {
+			kind = LimitMessageRate
+		}
+	}
+	if kind == 0 && limiter.bytes.enabled() {
+		limiter.bytes.refill(now)
+		if wait = limiter.bytes.wait(); wait > 0 {
+			kind = LimitByteRate
+		}
+	}
+	if kind == 0 {
+		limiter.messages.tokens -= float64(len(msgArr))
+		limiter.bytes.tokens -= float64(totalLen)
+		return msgArr, totalLen, nil
+	}
+	event := &LimitEvent{Kind: kind, Policy: limiter.opts.Policy}
+	if event.Policy == LimitDelay {
+		event.Delay = wait
+		limiter.pending, limiter.pendingLen = msgArr, totalLen
+	}
+	return nil, 0, event
+}
+
+// Wake
+//
+//	@Description: 安排延迟后唤醒，已安排时忽略
+//	@receiver limiter
+//	@param delay
+//	@param wake 唤醒函数，在定时器协程中执行
+func (limiter *Limiter) Wake(delay time.Duration, wake func()) {
+	if !limiter.waking.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(delay, func() {
+		limiter.waking.Store(false)
+		wake()
+	})
+}
+
+// Apply
+//
+//	@Description: 按速率限制放行消息，通知监听器触发的限制并安排延迟唤醒，在事件循环中执行
+//	@receiver limiter
+//	@param sess 会话
+//	@param listener 会话监听器，实现 LimitListener 时通知
+//	@param msgArr 本次解码的消息
+//	@param totalLen 消息总长度
+//	@param buffered 连接是否仍有未解码的数据
+//	@param wake 唤醒连接以继续处理，可在定时器协程中执行
+//	@return []any 放行的消息
+//	@return int 放行的消息长度
+//	@return bool 是否需按 LimitClose 策略关闭会话
+func (limiter *Limiter) Apply(sess Session, listener Listener, msgArr []any, totalLen int,
+	buffered bool, wake func()) ([]any, int, bool) {
+	pending := limiter.Pending()
+	msgArr, totalLen, event := limiter.Admit(time.Now(), msgArr, totalLen)
+	if event == nil {
+		if pending && buffered {
+			// 延迟期间暂停了解码，唤醒以继续处理已收到的数据
+			wake()
+		}
+		return msgArr, totalLen, false
+	}
+	event.RemoteAddr = sess.Connection().RemoteAddr()
+	if limitListener, ok := listener.(LimitListener); ok {
+		limitListener.OnLimited(sess, *event)
+	}
+	switch event.Policy {
+	case LimitDelay:
+		limiter.Wake(event.Delay, wake)
+	case LimitClose:
+		plog.Debug("close rate limited session:", pfield.Uint64("conn", sess.Connection().Hash()))
+		return nil, 0, true
+	}
+	return nil, 0, false
+}
+
+// RejectConn
+//
+//	@Description: 超出连接数限制时记录并通知监听器
+//	@param name 服务名
+//	@param listener 会话监听器，实现 LimitListener 时通知
+//	@param event
+func RejectConn(name string, listener Listener, event *LimitEvent) {
+	plog.Debug("reject connection:",
+		pfield.String("server", name),
+		pfield.String("addr", event.RemoteAddr.String()),
+		pfield.Uint8("kind", uint8(event.Kind)))
+	if limitListener, ok := listener.(LimitListener); ok {
+		limitListener.OnLimited(nil, *event)
+	}
+}
+
+// NewConnCounter
+//
+//	@Description: 构造连接计数器
+//	@param opts
+//	@return *ConnCounter 未配置连接数限制时返回nil
+func NewConnCounter(opts *LimitOptions) *ConnCounter {
+	if opts == nil || (opts.MaxConnections <= 0 && opts.MaxConnectionsPerIP <= 0) {
+		return nil
+	}
+	return &ConnCounter{opts: opts, perIP: make(map[string]int)}
+}
+
+// ConnCounter
+//
+//	@Description: 全局及单IP的并发连接计数，协程安全
+type ConnCounter struct {
+	opts  *LimitOptions
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+// Acquire
+//
+//	@Description: 占用连接数，成功后需在连接关闭时 Release
+//	@receiver counter
+//	@param addr 对端地址
+//	@return *LimitEvent 超出上限时返回，此时未占用
+func (counter *ConnCounter) Acquire(addr net.Addr) *LimitEvent {
+	ip := addrIP(addr)
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if maxConns := counter.opts.MaxConnections; maxConns > 0 && counter.total >= maxConns {
+		return &LimitEvent{Kind: LimitConnections, RemoteAddr: addr}
+	}
+	if maxConns := counter.opts.MaxConnectionsPerIP; maxConns > 0 && counter.perIP[ip] >= maxConns {
+		return &LimitEvent{Kind: LimitIPConnections, RemoteAddr: addr}
+	}
+	counter.total++
+	counter.perIP[ip]++
+	return nil
+}
+
+// Release
+//
+//	@Description: 释放连接数
+//	@receiver counter
+//	@param addr 对端地址
+func (counter *ConnCounter) Release(addr net.Addr) {
+	ip := addrIP(addr)
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.total--
+	if n := counter.perIP[ip] - 1; n > 0 {
+		counter.perIP[ip] = n
+	} else {
+		delete(counter.perIP, ip)
+	}
+}
+
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
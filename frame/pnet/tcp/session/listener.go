@@ -14,8 +14,9 @@ type Listener interface {
 	// OnClosed
 	//	@Description: 当会话关闭
 	//	@param session
+	//	@param reason 关闭原因，非nil
 	//
-	OnClosed(session Session)
+	OnClosed(session Session, reason *CloseReason)
 
 	// OnReceive
 	//	@Description: 成功接收消息
@@ -62,7 +63,7 @@ type EmptyListener struct {
 func (listener *EmptyListener) OnOpened(session Session) {
 }
 
-func (listener *EmptyListener) OnClosed(session Session) {
+func (listener *EmptyListener) OnClosed(session Session, reason *CloseReason) {
 }
 
 func (listener *EmptyListener) OnReceive(session Session, msg any, msgLen int) (err error) {
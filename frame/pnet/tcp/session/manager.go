@@ -14,7 +14,9 @@ func NewManager(name string, unregisterSessionLife int64) (*Manager, error) {
 	if unregisterSessionLife <= 0 {
 		return nil, errdef.ErrInvalidParams
 	}
-	return &Manager{name: name, unregisterSessionLife: unregisterSessionLife}, nil
+	manager := &Manager{name: name, unregisterSessionLife: unregisterSessionLife}
+	manager.groupSet.init()
+	return manager, nil
 }
 
 type Manager struct {
@@ -25,6 +27,23 @@ type Manager struct {
 	unregisterSessions sync.Map
 	// 已注册会话集合
 	registerSessions sync.Map
+	// 会话分组
+	groupSet groupSet
+	// 会话恢复配置，为nil时不支持恢复
+	resumeOptions *ResumeOptions
+	// 恢复状态，以令牌为键
+	resumeStates sync.Map
+	// 恢复状态，以会话编号为键
+	resumeIds sync.Map
+}
+
+// EnableResume
+//
+//	@Description: 开启会话恢复，需在服务启动前调用
+//	@receiver manager
+//	@param opts 恢复配置
+func (manager *Manager) EnableResume(opts *ResumeOptions) {
+	manager.resumeOptions = opts
 }
 
 // AddSession
@@ -63,12 +82,18 @@ func (manager *Manager) AddSession(svrSess Session) error {
 //	@receiver manager
 //	@param svrSess
 func (manager *Manager) RemoveSession(svrSess Session) {
+	manager.leaveAllGroups(svrSess)
+	if state := svrSess.ResumeState(); state != nil {
+		// 保留恢复状态直至过期
+		state.detach(svrSess)
+	}
 	if svrSess.Context() == nil {
 		manager.unregisterSessions.Delete(svrSess)
 	} else {
 		sessionId := svrSess.Id()
 		if sessionId != InvalidSessionId {
-			manager.registerSessions.Delete(sessionId)
+			// 同编号的会话可能已被新会话替换
+			manager.registerSessions.CompareAndDelete(sessionId, svrSess)
 		}
 	}
 }
@@ -101,15 +126,123 @@ func (manager *Manager) RegisterSession(svrSess Session, context Context) error
 				pfield.String("valueType", reflect.TypeOf(value).String()))
 		} else {
 			// 关闭旧会话
-			if err := oldSession.Close(); err != nil {
+			if err := closeSession(oldSession, pnet.ErrSessionKicked); err != nil {
 				plog.Error("close session error:", pfield.Error(err))
 			}
 		} // end of else
 	}
 	manager.registerSessions.Store(sessionId, svrSess)
+	if manager.resumeOptions != nil {
+		return manager.issueResume(svrSess, context)
+	}
+	return nil
+}
+
+// issueResume
+//
+//	@Description: 为注册的会话签发恢复令牌，废弃同编号会话原有的恢复状态
+//	@receiver manager
+//	@param svrSess
+//	@param context
+//	@return error
+func (manager *Manager) issueResume(svrSess Session, context Context) error {
+	state, err := newResumeState(manager.resumeOptions, context, svrSess)
+	if err != nil {
+		return err
+	}
+	if old, loaded := manager.resumeIds.Swap(context.Id(), state); loaded {
+		manager.discardResume(old.(*ResumeState))
+	}
+	manager.resumeStates.Store(state.token, state)
+	svrSess.setResumeState(state)
 	return nil
 }
 
+// discardResume
+//
+//	@Description: 废弃恢复状态
+//	@receiver manager
+//	@param state
+func (manager *Manager) discardResume(state *ResumeState) {
+	manager.resumeStates.Delete(state.token)
+	state.discard()
+}
+
+// ResumeSession
+//
+//	@Description: 在新连接的会话上恢复会话，关联原业务对象并重发客户端缺失的消息，原会话未关闭时将其关闭
+//	@receiver manager
+//	@param svrSess 新连接的未注册会话
+//	@param token 注册时签发的恢复令牌
+//	@param lastSeq 客户端已收到的最后消息序号
+//	@param replay 重发函数，参数为缺失消息的重放数据
+//	@return error 令牌无效或已过期时返回 pnet.ErrInvalidResumeToken，缺失的消息已不在重放缓冲中时返回 pnet.ErrResumeSeqMissed
+func (manager *Manager) ResumeSession(svrSess Session, token string, lastSeq uint64, replay func(items []any) error) error {
+	if svrSess.Context() != nil {
+		return pnet.ErrRegisteredSession
+	}
+	value, ok := manager.resumeStates.Load(token)
+	if !ok {
+		return pnet.ErrInvalidResumeToken
+	}
+	state, ok := value.(*ResumeState)
+	if !ok {
+		manager.resumeStates.Delete(token)
+		plog.Error("there is invalid type value in resumeStates",
+			pfield.String("valueType", reflect.TypeOf(value).String()))
+		return pnet.ErrInvalidResumeToken
+	}
+	oldSess, err := manager.rebind(state, svrSess, lastSeq, replay)
+	if oldSess != nil {
+		if cErr := closeSession(oldSess, pnet.ErrSessionKicked); cErr != nil {
+			plog.Error("close resumed session error:", pfield.Error(cErr))
+		}
+	}
+	return err
+}
+
+// rebind
+//
+//	@Description: 将恢复状态绑定到新会话并重发缺失的消息，重发在锁内完成以保证先于新消息写出，原会话的属性复制到新会话
+//	@receiver manager
+//	@param state
+//	@param svrSess
+//	@param lastSeq
+//	@param replay
+//	@return oldSess 原绑定的会话
+//	@return err
+func (manager *Manager) rebind(state *ResumeState, svrSess Session, lastSeq uint64,
+	replay func(items []any) error) (oldSess Session, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.expiredLocked(time.Now()) {
+		return nil, pnet.ErrInvalidResumeToken
+	}
+	items, err := state.missed(lastSeq)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		// 先重发，无法重发时不绑定
+		if err = replay(items); err != nil {
+			return nil, err
+		}
+	}
+	oldSess = state.owner
+	if oldSess != nil {
+		oldSess.Attributes().copyTo(svrSess.Attributes())
+	} else if state.attrs != nil {
+		state.attrs.copyTo(svrSess.Attributes())
+		state.attrs = nil
+	}
+	svrSess.setContext(state.context)
+	manager.unregisterSessions.Delete(svrSess)
+	manager.registerSessions.Store(state.context.Id(), svrSess)
+	svrSess.setResumeState(state)
+	state.owner = svrSess
+	return
+}
+
 // GetSession
 //
 //	@Description: 获取会话
@@ -130,6 +263,39 @@ func (manager *Manager) GetSession(sessionId uint64) Session {
 	}
 }
 
+// RangeSessions
+//
+//	@Description: 遍历所有会话（含未注册会话）
+//	@receiver manager
+//	@param op 返回false时停止遍历
+func (manager *Manager) RangeSessions(op func(sess Session) bool) {
+	stopped := false
+	manager.unregisterSessions.Range(func(key, _ any) bool {
+		if sess, ok := key.(Session); ok {
+			stopped = !op(sess)
+		}
+		return !stopped
+	})
+	if stopped {
+		return
+	}
+	manager.RangeRegisteredSessions(op)
+}
+
+// RangeRegisteredSessions
+//
+//	@Description: 遍历已注册会话
+//	@receiver manager
+//	@param op 返回false时停止遍历
+func (manager *Manager) RangeRegisteredSessions(op func(sess Session) bool) {
+	manager.registerSessions.Range(func(_, value any) bool {
+		if sess, ok := value.(Session); ok {
+			return op(sess)
+		}
+		return true
+	})
+}
+
 // CheckSessions
 //
 //	@Description: 检查会话的有效性
@@ -137,6 +303,32 @@ func (manager *Manager) GetSession(sessionId uint64) Session {
 func (manager *Manager) CheckSessions() {
 	manager.checkUnregisteredSessions()
 	manager.checkRegisteredSessions()
+	manager.checkResumeStates()
+}
+
+// checkResumeStates
+//
+//	@Description: 清理已过期的恢复状态
+//	@receiver manager
+func (manager *Manager) checkResumeStates() {
+	if manager.resumeOptions == nil {
+		return
+	}
+	now := time.Now()
+	manager.resumeStates.Range(func(key, value any) bool {
+		state, ok := value.(*ResumeState)
+		if !ok {
+			manager.resumeStates.Delete(key)
+			plog.Error("there is invalid type value in resumeStates",
+				pfield.String("valueType", reflect.TypeOf(value).String()))
+			return true
+		}
+		if state.expired(now) {
+			manager.resumeIds.CompareAndDelete(state.context.Id(), state)
+			manager.discardResume(state)
+		}
+		return true
+	})
 }
 
 // checkUnregisteredSessions
@@ -163,7 +355,7 @@ func (manager *Manager) checkUnregisteredSessions() {
 					pfield.String("manager", manager.name),
 					pfield.Uint64("conn", svrSess.Connection().Hash()))
 				// 过期关闭
-				if err := svrSess.Close(); err != nil {
+				if err := closeSession(svrSess, pnet.ErrSessionExpired); err != nil {
 					plog.Error("close session error:", pfield.Error(err))
 				}
 			} else {
@@ -197,7 +389,7 @@ func (manager *Manager) checkRegisteredSessions() {
 					manager.registerSessions.Delete(key)
 				} else {
 					// 失活关闭
-					if err := svrSess.Close(); err != nil {
+					if err := closeSession(svrSess, pnet.ErrSessionExpired); err != nil {
 						plog.Error("close deadline session error:", pfield.Error(err))
 					}
 					// 等到连接关闭时再移除该session
@@ -211,3 +403,14 @@ func (manager *Manager) checkRegisteredSessions() {
 		return true
 	})
 }
+
+// closeSession
+//
+//	@Description: 记录关闭原因后关闭会话
+//	@param sess
+//	@param reason 关闭原因
+//	@return error
+func closeSession(sess Session, reason error) error {
+	sess.Connection().ToClosed(reason)
+	return sess.Close()
+}
@@ -0,0 +1,232 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/panjf2000/gnet/v2"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+	ErrLessProxyHeader    = errors.New("less proxy protocol header")
+)
+
+// ProxyMode
+//
+//	@Description: PROXY协议模式
+type ProxyMode uint8
+
+const (
+	// ProxyOff 不解析PROXY协议头
+	ProxyOff ProxyMode = iota
+	// ProxyOptional 有PROXY协议头时解析
+	ProxyOptional
+	// ProxyRequired 必须有PROXY协议头，否则拒绝连接
+	ProxyRequired
+)
+
+const (
+	// proxyV1MaxLen v1头的最大长度
+	proxyV1MaxLen = 107
+	// proxyV2HeaderLen v2头固定部分的长度
+	proxyV2HeaderLen = 16
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ProxyHeader
+//
+//	@Description: PROXY协议头
+type ProxyHeader struct {
+	// 协议版本，1或2
+	Version int
+	// 是否为负载均衡自身的连接（v1的UNKNOWN或v2的LOCAL），此时没有真实地址
+	Local bool
+	// 客户端地址
+	Source net.Addr
+	// 客户端连接的目标地址
+	Destination net.Addr
+}
+
+// ReadProxyHeader
+//
+//	@Description: 从连接的输入中读取PROXY协议头，读取成功后才消耗输入
+//	@param reader 连接的输入
+//	@param mode 协议模式
+//	@return header 协议头，可选模式下没有协议头时为nil
+//	@return done 是否已完成读取，数据不完整时为false
+//	@return err
+func ReadProxyHeader(reader gnet.Reader, mode ProxyMode) (header *ProxyHeader, done bool, err error) {
+	buf, err := reader.Peek(reader.InboundBuffered())
+	if err != nil || len(buf) <= 0 {
+		return nil, false, err
+	}
+	var n int
+	switch {
+	case matchPrefix(buf, proxyV2Signature):
+		if len(buf) < len(proxyV2Signature) {
+			return nil, false, nil
+		}
+		header, n, err = parseProxyV2(buf)
+	case matchPrefix(buf, proxyV1Prefix):
+		if len(buf) < len(proxyV1Prefix) {
+			return nil, false, nil
+		}
+		header, n, err = parseProxyV1(buf)
+	default:
+		if mode == ProxyRequired {
+			return nil, false, ErrLessProxyHeader
+		}
+		return nil, true, nil
+	}
+	if err != nil || n <= 0 {
+		return nil, false, err
+	}
+	if _, err = reader.Discard(n); err != nil {
+		return nil, false, err
+	}
+	return header, true, nil
+}
+
+// AcceptProxyHeader
+//
+//	@Description: 读取PROXY协议头并设置到连接，在事件循环中执行
+//	@param reader 连接的原始输入
+//	@param conn 连接
+//	@param mode 协议模式
+//	@param name 服务名
+//	@return done 是否已完成读取，完成后可开启会话
+//	@return err 协议头非法或缺失时返回，需关闭连接
+func AcceptProxyHeader(reader gnet.Reader, conn *BaseConn, mode ProxyMode, name string) (done bool, err error) {
+	header, done, err := ReadProxyHeader(reader, mode)
+	if err != nil {
+		plog.Debug("read proxy header error:",
+			pfield.String("server", name),
+			pfield.Uint64("conn", conn.Hash()),
+			pfield.Error(err))
+		return false, err
+	}
+	if done && header != nil {
+		conn.SetProxyHeader(header)
+	}
+	return done, nil
+}
+
+// matchPrefix
+//
+//	@Description: 已收到的数据是否与前缀相符，数据不足前缀长度时比较已收到的部分
+func matchPrefix(buf []byte, prefix []byte) bool {
+	if len(buf) < len(prefix) {
+		return len(buf) > 0 && bytes.Equal(buf, prefix[:len(buf)])
+	}
+	return bytes.Equal(buf[:len(prefix)], prefix)
+}
+
+// parseProxyV1
+//
+//	@Description: 解析v1文本协议头，如 PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n
+//	@return header
+//	@return n 协议头长度，数据不完整时为0
+//	@return err
+func parseProxyV1(buf []byte) (header *ProxyHeader, n int, err error) {
+	end := bytes.Index(buf, []byte("\r\n"))
+	if end < 0 {
+		if len(buf) >= proxyV1MaxLen {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+		return nil, 0, nil
+	}
+	if end+2 > proxyV1MaxLen {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	fields := strings.Split(string(buf[:end]), " ")
+	header = &ProxyHeader{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		header.Local = true
+		return header, end + 2, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	if header.Source, err = parseTCPAddr(fields[2], fields[4]); err != nil {
+		return nil, 0, err
+	}
+	if header.Destination, err = parseTCPAddr(fields[3], fields[5]); err != nil {
+		return nil, 0, err
+	}
+	return header, end + 2, nil
+}
+
+func parseTCPAddr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if ip == nil || err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(portNum)}, nil
+}
+
+// parseProxyV2
+//
+//	@Description: 解析v2二进制协议头
+//	@return header
+//	@return n 协议头长度，数据不完整时为0
+//	@return err
+func parseProxyV2(buf []byte) (header *ProxyHeader, n int, err error) {
+	if len(buf) < proxyV2HeaderLen {
+		return nil, 0, nil
+	}
+	verCmd, family := buf[12], buf[13]
+	if verCmd>>4 != 2 {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	n = proxyV2HeaderLen + int(binary.BigEndian.Uint16(buf[14:16]))
+	if len(buf) < n {
+		return nil, 0, nil
+	}
+	header = &ProxyHeader{Version: 2}
+	switch verCmd & 0x0f {
+	case 0x00:
+		// LOCAL
+		header.Local = true
+		return header, n, nil
+	case 0x01:
+		// PROXY
+	default:
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	addrs := buf[proxyV2HeaderLen:n]
+	switch family >> 4 {
+	case 0x1:
+		// AF_INET
+		if len(addrs) < 12 {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+		header.Source = &net.TCPAddr{
+			IP: net.IP(bytes.Clone(addrs[0:4])), Port: int(binary.BigEndian.Uint16(addrs[8:10]))}
+		header.Destination = &net.TCPAddr{
+			IP: net.IP(bytes.Clone(addrs[4:8])), Port: int(binary.BigEndian.Uint16(addrs[10:12]))}
+	case 0x2:
+		// AF_INET6
+		if len(addrs) < 36 {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+		header.Source = &net.TCPAddr{
+			IP: net.IP(bytes.Clone(addrs[0:16])), Port: int(binary.BigEndian.Uint16(addrs[32:34]))}
+		header.Destination = &net.TCPAddr{
+			IP: net.IP(bytes.Clone(addrs[16:32])), Port: int(binary.BigEndian.Uint16(addrs[34:36]))}
+	default:
+		// AF_UNSPEC或AF_UNIX，没有可用的地址
+		header.Local = true
+	}
+	return header, n, nil
+}
@@ -0,0 +1,158 @@
+package session
+
+import (
+	"context"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/utils/rand"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultReconnectMaxDelay 未配置最大等待时间时使用的值
+	DefaultReconnectMaxDelay = time.Minute
+)
+
+// ReconnectEventKind
+//
+//	@Description: 重连事件类型
+type ReconnectEventKind uint8
+
+const (
+	// ReconnectAttempt 开始一次重连尝试
+	ReconnectAttempt ReconnectEventKind = iota
+	// ReconnectFailed 一次重连尝试失败
+	ReconnectFailed
+	// ReconnectSucceeded 重连成功
+	ReconnectSucceeded
+	// ReconnectGaveUp 达到最大尝试次数，放弃重连
+	ReconnectGaveUp
+)
+
+// ReconnectEvent
+//
+//	@Description: 重连事件
+type ReconnectEvent struct {
+	Kind ReconnectEventKind
+	// 第几次尝试，从1开始
+	Attempt int
+	// 断开原因或本次尝试的错误
+	Err error
+}
+
+// ReconnectOptions
+//
+//	@Description: 客户端重连配置
+type ReconnectOptions struct {
+	// 首次重连等待时间
+	InitialDelay time.Duration
+	// 最大等待时间，为0时使用 DefaultReconnectMaxDelay
+	MaxDelay time.Duration
+	// 等待时间增长倍数
+	Multiplier float64
+	// 随机抖动比例，取值[0,1]
+	Jitter float64
+	// 最大尝试次数，小于等于0时不限制
+	MaxAttempts int
+	// 单次连接超时
+	DialTimeout time.Duration
+	// 重连事件回调，在重连协程中执行
+	OnEvent func(event ReconnectEvent)
+}
+
+// Delay
+//
+//	@Description: 计算第 attempt 次尝试前的等待时间
+//	@receiver opts
+//	@param attempt 从1开始
+//	@return time.Duration
+func (opts *ReconnectOptions) Delay(attempt int) time.Duration {
+	maxDelay := opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultReconnectMaxDelay
+	}
+	delay := float64(opts.InitialDelay)
+	if opts.Multiplier > 1 && attempt > 1 {
+		// 增长后可能溢出为+Inf，先按最大等待时间截断
+		delay = math.Min(delay*math.Pow(opts.Multiplier, float64(attempt-1)), float64(maxDelay))
+	}
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	if opts.Jitter > 0 {
+		jitter := math.Min(opts.Jitter, 1)
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// Notify
+//
+//	@Description: 通知重连事件
+//	@receiver opts
+//	@param event
+func (opts *ReconnectOptions) Notify(event ReconnectEvent) {
+	if opts.OnEvent != nil {
+		opts.OnEvent(event)
+	}
+}
+
+// Reconnect
+//
+//	@Description: 断线后按重连配置重新连接，在重连协程中执行
+//	@receiver opts
+//	@param name 客户端名
+//	@param reason 断线原因
+//	@param attrs 客户端会话属性，放弃重连或被主动关闭时清空
+//	@param closing 客户端是否正被主动关闭
+//	@param dial 发起一次连接，客户端状态已变化无法连接时返回false
+//	@param close 重连过程中被主动关闭时，在连接成功后关闭客户端
+func (opts *ReconnectOptions) Reconnect(name string, reason error, attrs *Attributes,
+	closing func() bool, dial func(ctx context.Context) (bool, error), close func() error) {
+	for attempt := 1; opts.MaxAttempts <= 0 || attempt <= opts.MaxAttempts; attempt++ {
+		time.Sleep(opts.Delay(attempt))
+		if closing() {
+			attrs.Clear()
+			return
+		}
+		opts.Notify(ReconnectEvent{Kind: ReconnectAttempt, Attempt: attempt, Err: reason})
+		ctx, cancel := opts.dialContext()
+		started, err := dial(ctx)
+		cancel()
+		if !started {
+			return
+		}
+		if err == nil {
+			plog.Info("client reconnected",
+				pfield.String("client", name), pfield.Int("attempt", attempt))
+			opts.Notify(ReconnectEvent{Kind: ReconnectSucceeded, Attempt: attempt})
+			if closing() {
+				// 重连过程中被主动关闭
+				_ = close()
+			}
+			return
+		}
+		plog.Warn("client reconnect error:",
+			pfield.String("client", name), pfield.Int("attempt", attempt), pfield.Error(err))
+		opts.Notify(ReconnectEvent{Kind: ReconnectFailed, Attempt: attempt, Err: err})
+	}
+	opts.Notify(ReconnectEvent{Kind: ReconnectGaveUp, Attempt: opts.MaxAttempts, Err: reason})
+	attrs.Clear()
+}
+
+// dialContext
+//
+//	@Description: 构造单次连接的上下文
+//	@receiver opts
+//	@return context.Context
+//	@return context.CancelFunc
+func (opts *ReconnectOptions) dialContext() (context.Context, context.CancelFunc) {
+	if opts.DialTimeout > 0 {
+		return context.WithTimeout(context.Background(), opts.DialTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/meow-pad/persian/frame/pnet"
+	"sync"
+	"time"
+)
+
+const (
+	// resumeTokenSize 恢复令牌的随机字节数
+	resumeTokenSize = 16
+)
+
+// ResumeOptions
+//
+//	@Description: 会话恢复配置
+//		会话注册时签发恢复令牌，注册后发出的消息按发送顺序从1开始编号并保留在重放缓冲中；
+//		会话关闭后在保留时长内，客户端可在新连接上携带令牌及已收到的最后序号恢复会话，服务端重发缺失的消息；
+//		恢复后的会话保留原会话的属性
+type ResumeOptions struct {
+	// 会话关闭后保留恢复状态的时长
+	GracePeriod time.Duration
+	// 重放缓冲保留的消息数
+	BufferSize int
+}
+
+// newResumeState
+//
+//	@Description: 构造恢复状态
+//	@param opts
+//	@param context 会话的业务对象
+//	@param owner 绑定的会话
+//	@return *ResumeState
+//	@return error
+func newResumeState(opts *ResumeOptions, context Context, owner Session) (*ResumeState, error) {
+	token := make([]byte, resumeTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return &ResumeState{
+		opts:    opts,
+		token:   hex.EncodeToString(token),
+		context: context,
+		owner:   owner,
+		buffer:  make([]any, opts.BufferSize),
+	}, nil
+}
+
+// ResumeState
+//
+//	@Description: 会话的恢复状态，协程安全
+type ResumeState struct {
+	opts    *ResumeOptions
+	token   string
+	context Context
+
+	mu sync.Mutex
+	// 当前绑定的会话，会话关闭后为nil
+	owner Session
+	// 最后发出的消息序号
+	seq uint64
+	// 重放缓冲，环形保存序号 seq-count+1 至 seq 的消息
+	buffer []any
+	head   int
+	count  int
+	// 会话关闭时的属性，恢复时复制到新会话
+	attrs *Attributes
+	// 会话关闭后的过期时间
+	expireAt time.Time
+	// 是否已废弃，废弃后不可恢复
+	discarded bool
+}
+
+// Token
+//
+//	@Description: 恢复令牌
+//	@receiver state
+//	@return string
+func (state *ResumeState) Token() string {
+	return state.token
+}
+
+// Seq
+//
+//	@Description: 最后发出的消息序号
+//	@receiver state
+//	@return uint64
+func (state *ResumeState) Seq() uint64 {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.seq
+}
+
+// Send
+//
+//	@Description: 记录消息到重放缓冲后写出，记录与写出在锁内完成以保证写出顺序与序号一致
+//	@receiver state
+//	@param sess 发送的会话，不再绑定该状态时不写出
+//	@param items 重放时需要的消息数据，每项对应一个序号
+//	@param write 写出函数
+//	@return error
+func (state *ResumeState) Send(sess Session, items []any, write func() error) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.owner != sess {
+		return pnet.ErrClosedConn
+	}
+	for _, item := range items {
+		state.push(item)
+	}
+	return write()
+}
+
+func (state *ResumeState) push(item any) {
+	state.seq++
+	if len(state.buffer) <= 0 {
+		return
+	}
+	tail := (state.head + state.count) % len(state.buffer)
+	state.buffer[tail] = item
+	if state.count < len(state.buffer) {
+		state.count++
+	} else {
+		state.head = (state.head + 1) % len(state.buffer)
+	}
+}
+
+// missed
+//
+//	@Description: 获取序号之后的消息
+//	@receiver state
+//	@param lastSeq 客户端已收到的最后序号
+//	@return []any
+//	@return error 序号超出重放缓冲时返回 pnet.ErrResumeSeqMissed
+func (state *ResumeState) missed(lastSeq uint64) ([]any, error) {
+	if lastSeq > state.seq || state.seq-lastSeq > uint64(state.count) {
+		return nil, pnet.ErrResumeSeqMissed
+	}
+	num := int(state.seq - lastSeq)
+	items := make([]any, 0, num)
+	for i := state.count - num; i < state.count; i++ {
+		items = append(items, state.buffer[(state.head+i)%len(state.buffer)])
+	}
+	return items, nil
+}
+
+// detach
+//
+//	@Description: 会话关闭后解除绑定，开始计算保留时长
+//	@receiver state
+//	@param sess 关闭的会话
+func (state *ResumeState) detach(sess Session) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.owner != sess {
+		return
+	}
+	state.owner = nil
+	state.attrs = &Attributes{}
+	sess.Attributes().copyTo(state.attrs)
+	state.expireAt = time.Now().Add(state.opts.GracePeriod)
+}
+
+// discard
+//
+//	@Description: 废弃恢复状态
+//	@receiver state
+func (state *ResumeState) discard() {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.owner = nil
+	state.discarded = true
+	state.attrs = nil
+	state.buffer = nil
+	state.count = 0
+}
+
+// expired
+//
+//	@Description: 是否已不可恢复
+//	@receiver state
+//	@param now
+//	@return bool
+func (state *ResumeState) expired(now time.Time) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.expiredLocked(now)
+}
+
+func (state *ResumeState) expiredLocked(now time.Time) bool {
+	return state.discarded || (state.owner == nil && !now.Before(state.expireAt))
+}
@@ -1,6 +1,9 @@
 package session
 
-import "github.com/meow-pad/persian/frame/plog"
+import (
+	"github.com/meow-pad/persian/frame/plog"
+	"sync/atomic"
+)
 
 const (
 	InvalidSessionId = 0
@@ -36,6 +39,24 @@ type Session interface {
 	//
 	setContext(context Context)
 
+	// ResumeState
+	//	@Description: 会话的恢复状态，未开启会话恢复或未注册时为nil
+	//	@return *ResumeState
+	//
+	ResumeState() *ResumeState
+
+	// setResumeState
+	//	@Description: 设置恢复状态
+	//	@param state
+	//
+	setResumeState(state *ResumeState)
+
+	// Attributes
+	//	@Description: 会话属性，可使用 GetAttribute、SetAttribute、DeleteAttribute 按类型访问
+	//	@return *Attributes
+	//
+	Attributes() *Attributes
+
 	// Connection
 	//	@Description: 连接
 	//	@return Conn
@@ -70,6 +91,10 @@ type Session interface {
 type BaseSession struct {
 	// 关联的业务对象
 	context Context
+	// 恢复状态
+	resume atomic.Pointer[ResumeState]
+	// 会话属性
+	attrs Attributes
 }
 
 func (sess *BaseSession) Id() uint64 {
@@ -83,6 +108,18 @@ func (sess *BaseSession) Context() Context {
 	return sess.context
 }
 
+func (sess *BaseSession) Attributes() *Attributes {
+	return &sess.attrs
+}
+
+func (sess *BaseSession) ResumeState() *ResumeState {
+	return sess.resume.Load()
+}
+
+func (sess *BaseSession) setResumeState(state *ResumeState) {
+	sess.resume.Store(state)
+}
+
 func (sess *BaseSession) setContext(context Context) {
 	if context != nil && sess.context != nil {
 		plog.Error("set session context again?")
@@ -0,0 +1,205 @@
+package session
+
+import "github.com/meow-pad/persian/frame/pnet"
+
+// TypedSession
+//
+//	@Description: 带类型的会话，发送消息的类型在编译期检查
+//		需要以 Session 传递时使用内嵌的 Session 字段
+type TypedSession[Out any] struct {
+	Session
+}
+
+// Typed
+//
+//	@Description: 获取会话的带类型视图
+//	@param sess
+//	@return TypedSession[Out]
+func Typed[Out any](sess Session) TypedSession[Out] {
+	return TypedSession[Out]{Session: sess}
+}
+
+// SendMessage
+//
+//	@Description: 发送消息
+//	@receiver sess
+//	@param message
+func (sess TypedSession[Out]) SendMessage(message Out) {
+	sess.Session.SendMessage(message)
+}
+
+// SendMessages
+//
+//	@Description: 批量发送消息
+//	@receiver sess
+//	@param messages
+func (sess TypedSession[Out]) SendMessages(messages ...Out) {
+	sess.Session.SendMessages(toAnySlice(messages)...)
+}
+
+// TypedListener
+//
+//	@Description: 带类型的会话监听器，In 为接收消息类型，Out 为发送消息类型
+//		可同时实现 LimitListener 或 BackpressureListener，适配后仍会被通知
+type TypedListener[In, Out any] interface {
+
+	// OnOpened
+	//	@Description: 当会话开启
+	//	@param session
+	//
+	OnOpened(session TypedSession[Out])
+
+	// OnClosed
+	//	@Description: 当会话关闭
+	//	@param session
+	//	@param reason 关闭原因，非nil
+	//
+	OnClosed(session TypedSession[Out], reason *CloseReason)
+
+	// OnReceive
+	//	@Description: 成功接收消息
+	//	@param session
+	//	@param msg 接收的消息体
+	//	@param msgLen 消息长度
+	//	@return err
+	//
+	OnReceive(session TypedSession[Out], msg In, msgLen int) (err error)
+
+	// OnReceiveMulti
+	//	@Description: 成功接收消息
+	//	@param session
+	//	@param msg 接收的消息体
+	//	@param totalLen 消息长度
+	//	@return err
+	//
+	OnReceiveMulti(session TypedSession[Out], msg []In, totalLen int) (err error)
+
+	// OnSend
+	//	@Description: 成功发送消息
+	//	@param session
+	//	@param msg 要发送的消息体
+	//	@param msgLen 消息长度
+	//	@return err
+	//
+	OnSend(session TypedSession[Out], msg Out, msgLen int) (err error)
+
+	// OnSendMulti
+	//	@Description: 成功发送消息
+	//	@param session
+	//	@param msg 要发送的消息体
+	//	@param totalLen 消息长度
+	//	@return err
+	//
+	OnSendMulti(session TypedSession[Out], msg []Out, totalLen int) (err error)
+}
+
+// EmptyTypedListener 空处理的带类型会话监听器
+type EmptyTypedListener[In, Out any] struct {
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnOpened(session TypedSession[Out]) {
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnClosed(session TypedSession[Out], reason *CloseReason) {
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnReceive(session TypedSession[Out], msg In, msgLen int) (err error) {
+	return
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnReceiveMulti(session TypedSession[Out], msg []In, totalLen int) (err error) {
+	return
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnSend(session TypedSession[Out], msg Out, msgLen int) (err error) {
+	return
+}
+
+func (listener *EmptyTypedListener[In, Out]) OnSendMulti(session TypedSession[Out], msg []Out, totalLen int) (err error) {
+	return
+}
+
+// NewListenerAdapter
+//
+//	@Description: 将带类型的监听器适配为 Listener
+//	@param typed 带类型的监听器
+//	@return Listener 消息类型不符时回调返回 pnet.ErrMismatchedMessage
+func NewListenerAdapter[In, Out any](typed TypedListener[In, Out]) Listener {
+	return &listenerAdapter[In, Out]{typed: typed}
+}
+
+type listenerAdapter[In, Out any] struct {
+	typed TypedListener[In, Out]
+}
+
+func (adapter *listenerAdapter[In, Out]) OnOpened(session Session) {
+	adapter.typed.OnOpened(Typed[Out](session))
+}
+
+func (adapter *listenerAdapter[In, Out]) OnClosed(session Session, reason *CloseReason) {
+	adapter.typed.OnClosed(Typed[Out](session), reason)
+}
+
+func (adapter *listenerAdapter[In, Out]) OnReceive(session Session, msg any, msgLen int) error {
+	in, ok := msg.(In)
+	if !ok {
+		return pnet.ErrMismatchedMessage
+	}
+	return adapter.typed.OnReceive(Typed[Out](session), in, msgLen)
+}
+
+func (adapter *listenerAdapter[In, Out]) OnReceiveMulti(session Session, msg []any, totalLen int) error {
+	inArr, ok := fromAnySlice[In](msg)
+	if !ok {
+		return pnet.ErrMismatchedMessage
+	}
+	return adapter.typed.OnReceiveMulti(Typed[Out](session), inArr, totalLen)
+}
+
+func (adapter *listenerAdapter[In, Out]) OnSend(session Session, msg any, msgLen int) error {
+	out, ok := msg.(Out)
+	if !ok {
+		return pnet.ErrMismatchedMessage
+	}
+	return adapter.typed.OnSend(Typed[Out](session), out, msgLen)
+}
+
+func (adapter *listenerAdapter[In, Out]) OnSendMulti(session Session, msg []any, totalLen int) error {
+	outArr, ok := fromAnySlice[Out](msg)
+	if !ok {
+		return pnet.ErrMismatchedMessage
+	}
+	return adapter.typed.OnSendMulti(Typed[Out](session), outArr, totalLen)
+}
+
+func (adapter *listenerAdapter[In, Out]) OnLimited(session Session, event LimitEvent) {
+	if listener, ok := adapter.typed.(LimitListener); ok {
+		listener.OnLimited(session, event)
+	}
+}
+
+func (adapter *listenerAdapter[In, Out]) OnBackpressure(session Session, slow bool) {
+	if listener, ok := adapter.typed.(BackpressureListener); ok {
+		listener.OnBackpressure(session, slow)
+	}
+}
+
+func toAnySlice[T any](items []T) []any {
+	result := make([]any, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
+
+func fromAnySlice[T any](items []any) ([]T, bool) {
+	result := make([]T, len(items))
+	for i, item := range items {
+		typed, ok := item.(T)
+		if !ok {
+			return nil, false
+		}
+		result[i] = typed
+	}
+	return result, true
+}
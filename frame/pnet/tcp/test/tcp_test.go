@@ -2,11 +2,28 @@ package test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/message"
 	"github.com/meow-pad/persian/frame/pnet/tcp/client"
 	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
 	"github.com/meow-pad/persian/frame/pnet/tcp/server"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/utils/worker"
+	"github.com/panjf2000/gnet/v2"
 	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,6 +37,14 @@ func newServer(protoAddr string, listener session.Listener) (*server.Server, err
 	return server.NewServer("test-server", protoAddr, newCodec(), listener)
 }
 
+// reuseAddr
+//
+//	@Description: 服务端主动关闭连接的测试需复用地址，避免重复执行时端口仍处于TIME_WAIT
+//	@return server.Option
+func reuseAddr() server.Option {
+	return server.WithGNetOption(gnet.WithReuseAddr(true))
+}
+
 func newClient(listener session.Listener) (*client.Client, error) {
 	return client.NewClient(newCodec(), listener, client.WithName("test-client"))
 }
@@ -33,7 +58,7 @@ func (listener *svrListener) OnOpened(session session.Session) {
 	listener.t.Logf("tcp-svr open conn:%v", session.Connection().Hash())
 }
 
-func (listener *svrListener) OnClosed(session session.Session) {
+func (listener *svrListener) OnClosed(session session.Session, reason *session.CloseReason) {
 	listener.t.Logf("tcp-svr close conn:%v", session.Connection().Hash())
 }
 
@@ -60,7 +85,7 @@ func (listener *cliListener) OnOpened(session session.Session) {
 	listener.t.Logf("tcp-cli open conn:%v", session.Connection().Hash())
 }
 
-func (listener *cliListener) OnClosed(session session.Session) {
+func (listener *cliListener) OnClosed(session session.Session, reason *session.CloseReason) {
 	listener.t.Logf("tcp-cli close conn:%v", session.Connection().Hash())
 }
 
@@ -96,3 +121,1201 @@ func TestTCP_Echo(t *testing.T) {
 	err = echoSvr.Stop(context.Background())
 	should.Nil(err)
 }
+
+type orderListener struct {
+	session.EmptyListener
+	received chan any
+}
+
+func (listener *orderListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	listener.received <- msg
+	return nil
+}
+
+func (listener *orderListener) OnReceiveMulti(session session.Session, msgArr []any, totalLen int) error {
+	for _, msg := range msgArr {
+		listener.received <- msg
+	}
+	return nil
+}
+
+func TestTCP_Dispatch(t *testing.T) {
+	should := require.New(t)
+	pool, err := worker.NewFixedWorkerPool(4, 16, true)
+	should.Nil(err)
+	dispatcher, err := session.NewDispatcher(pool, session.DispatchBlock)
+	should.Nil(err)
+	addr := "127.0.0.1:12081"
+	listener := &orderListener{received: make(chan any, 16)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener,
+		server.WithDispatcher(dispatcher), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cli, err := newClient(&cliListener{t: t})
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	expected := []any{"1", "2", "3", "4", "5", "6", "7", "8"}
+	for _, msg := range expected {
+		cli.SendMessage(msg)
+	}
+	received := make([]any, 0, len(expected))
+	for range expected {
+		select {
+		case msg := <-listener.received:
+			received = append(received, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	should.Equal(expected, received)
+	should.Equal(uint64(0), dispatcher.Stats().Dropped)
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+	should.Nil(pool.Shutdown(context.Background()))
+}
+
+// rawCodec
+//
+//	@Description: 解码结果直接引用输入数据
+type rawCodec struct {
+	message.TextCodec
+}
+
+func (codec *rawCodec) Decode(in []byte) (any, error) {
+	return in, nil
+}
+
+func TestTCP_DispatchRaw(t *testing.T) {
+	should := require.New(t)
+	pool, err := worker.NewFixedWorkerPool(4, 16, true)
+	should.Nil(err)
+	dispatcher, err := session.NewDispatcher(pool, session.DispatchBlock)
+	should.Nil(err)
+	addr := "127.0.0.1:12104"
+	svrCodec, err := codec.NewLengthFieldCodec(codec.WithMessageCodec[*codec.LengthOptions](&rawCodec{}))
+	should.Nil(err)
+	listener := &gateListener{gate: make(chan struct{}), received: make(chan any, 16)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, svrCodec, listener,
+		server.WithDispatcher(dispatcher), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cli, err := newClient(&cliListener{t: t})
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	// 处理被阻塞期间继续读取，读缓冲被复用
+	expected := []string{"aaaa", "bbbb", "cccc", "dddd"}
+	for _, msg := range expected {
+		cli.SendMessage(msg)
+		time.Sleep(50 * time.Millisecond)
+	}
+	close(listener.gate)
+	received := make([]string, 0, len(expected))
+	for range expected {
+		select {
+		case msg := <-listener.received:
+			received = append(received, string(msg.([]byte)))
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	should.Equal(expected, received)
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+	should.Nil(pool.Shutdown(context.Background()))
+}
+
+// gateListener
+//
+//	@Description: 消息处理等待放行，用于验证关闭通知排在已提交的消息之后
+type gateListener struct {
+	session.EmptyListener
+	gate     chan struct{}
+	received chan any
+}
+
+func (listener *gateListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	<-listener.gate
+	listener.received <- msg
+	return nil
+}
+
+func (listener *gateListener) OnReceiveMulti(session session.Session, msgArr []any, totalLen int) error {
+	for _, msg := range msgArr {
+		_ = listener.OnReceive(session, msg, 0)
+	}
+	return nil
+}
+
+func (listener *gateListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.received <- "closed"
+}
+
+func TestTCP_DispatchClosed(t *testing.T) {
+	should := require.New(t)
+	pool, err := worker.NewFixedWorkerPool(4, 16, true)
+	should.Nil(err)
+	dispatcher, err := session.NewDispatcher(pool, session.DispatchBlock)
+	should.Nil(err)
+	addr := "127.0.0.1:12100"
+	listener := &gateListener{gate: make(chan struct{}), received: make(chan any, 8)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener,
+		server.WithDispatcher(dispatcher), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cli, err := newClient(&cliListener{t: t})
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage("1")
+	cli.SendMessage("2")
+	// 消息处理被阻塞时断开连接
+	time.Sleep(200 * time.Millisecond)
+	should.Nil(cli.Close())
+	time.Sleep(200 * time.Millisecond)
+	close(listener.gate)
+	received := make([]any, 0, 3)
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-listener.received:
+			received = append(received, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	should.Equal([]any{"1", "2", "closed"}, received)
+	should.Nil(svr.Stop(context.Background()))
+	should.Nil(pool.Shutdown(context.Background()))
+}
+
+type idleListener struct {
+	session.EmptyListener
+	closed chan error
+}
+
+func (listener *idleListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func TestTCP_Heartbeat(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12082"
+	listener := &idleListener{closed: make(chan error, 1)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener,
+		server.WithHeartbeat(200*time.Millisecond, 2, func() any { return "ping" }), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cli, err := newClient(&cliListener{t: t})
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	select {
+	case reason := <-listener.closed:
+		should.True(errors.Is(reason, pnet.ErrIdleTimeout))
+		should.Equal(session.CloseIdle, reason.(*session.CloseReason).Category)
+	case <-time.After(3 * time.Second):
+		should.FailNow("idle session is not closed")
+	}
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestTCP_HeartbeatMaxMissed(t *testing.T) {
+	should := require.New(t)
+	opts := &session.HeartbeatOptions{Interval: time.Second}
+	conn := &session.BaseConn{}
+	conn.UpdateReadTime()
+	now := conn.LastReadTime()
+	// 未配置次数上限时，关闭前仍发送一次心跳
+	should.Equal(session.IdleHeartbeat, conn.CheckIdle(opts, now.Add(time.Second)))
+	should.Equal(session.IdleNone, conn.CheckIdle(opts, now.Add(1500*time.Millisecond)))
+	should.Equal(session.IdleClose, conn.CheckIdle(opts, now.Add(2*time.Second)))
+}
+
+type groupListener struct {
+	session.EmptyListener
+	server *server.Server
+	joined chan session.Session
+}
+
+func (listener *groupListener) OnOpened(session session.Session) {
+	if err := listener.server.JoinGroup(1, session); err == nil {
+		listener.joined <- session
+	}
+}
+
+func TestTCP_Broadcast(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12083"
+	listener := &groupListener{joined: make(chan session.Session, 2)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr())
+	should.Nil(err)
+	listener.server = svr
+	should.Nil(svr.CreateGroup(1))
+	should.Nil(svr.Start(context.Background()))
+	receivers := make([]*orderListener, 2)
+	clients := make([]*client.Client, 2)
+	sessions := make([]session.Session, 2)
+	for i := range clients {
+		receivers[i] = &orderListener{received: make(chan any, 1)}
+		clients[i], err = client.NewClient(newCodec(), receivers[i])
+		should.Nil(err)
+		should.Nil(clients[i].Dial(context.Background(), addr))
+		sessions[i] = <-listener.joined
+	}
+	members, err := svr.GroupMembers(1)
+	should.Nil(err)
+	should.Len(members, 2)
+	should.Nil(svr.Broadcast(1, "hello"))
+	for _, receiver := range receivers {
+		select {
+		case msg := <-receiver.received:
+			should.Equal("hello", msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("broadcast timeout")
+		}
+	}
+	should.Nil(clients[0].Close())
+	time.Sleep(200 * time.Millisecond)
+	members, err = svr.GroupMembers(1)
+	should.Nil(err)
+	should.Len(members, 1)
+	// 已关闭的会话无法再加入分组
+	should.ErrorIs(svr.JoinGroup(1, sessions[0]), session.ErrInvalidSession)
+	should.Empty(svr.SessionGroups(sessions[0]))
+	should.Nil(clients[1].Close())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type kickListener struct {
+	session.EmptyListener
+	opened atomic.Int32
+}
+
+func (listener *kickListener) OnOpened(session session.Session) {
+	// 首个连接建立后立刻断开
+	if listener.opened.Add(1) == 1 {
+		_ = session.Close()
+	}
+}
+
+type reopenListener struct {
+	session.EmptyListener
+	opened chan struct{}
+}
+
+func (listener *reopenListener) OnOpened(session session.Session) {
+	listener.opened <- struct{}{}
+}
+
+func TestTCP_Reconnect(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12084"
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), &kickListener{}, reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	succeeded := make(chan session.ReconnectEvent, 1)
+	listener := &reopenListener{opened: make(chan struct{}, 2)}
+	cli, err := client.NewClient(newCodec(), listener, client.WithReconnect(&session.ReconnectOptions{
+		InitialDelay: 50 * time.Millisecond,
+		MaxAttempts:  5,
+		OnEvent: func(event session.ReconnectEvent) {
+			if event.Kind == session.ReconnectSucceeded {
+				succeeded <- event
+			}
+		},
+	}))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	select {
+	case event := <-succeeded:
+		should.Equal(1, event.Attempt)
+	case <-time.After(3 * time.Second):
+		should.FailNow("reconnect timeout")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-listener.opened:
+		case <-time.After(3 * time.Second):
+			should.FailNow("session is not reopened")
+		}
+	}
+	should.Equal(uint32(client.StatusConnected), cli.Status())
+	should.Nil(cli.Close())
+	time.Sleep(200 * time.Millisecond)
+	should.Equal(uint32(client.StatusClosed), cli.Status())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestTCP_ReconnectDelay(t *testing.T) {
+	should := require.New(t)
+	opts := &session.ReconnectOptions{InitialDelay: time.Second, Multiplier: 2}
+	should.Equal(time.Second, opts.Delay(1))
+	should.Equal(4*time.Second, opts.Delay(3))
+	// 未配置最大等待时间时，增长溢出也不会超过默认值
+	should.Equal(session.DefaultReconnectMaxDelay, opts.Delay(10000))
+}
+
+// newTLSConfigs
+//
+//	@Description: 生成自签名证书，返回服务端与客户端TLS配置
+//	@param should
+//	@return *tls.Config
+//	@return *tls.Config
+func newTLSConfigs(should *require.Assertions) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	should.Nil(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "persian-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	should.Nil(err)
+	cert, err := x509.ParseCertificate(der)
+	should.Nil(err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	svrConfig := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	return svrConfig, &tls.Config{RootCAs: pool}
+}
+
+func TestTCP_TLS(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12085"
+	svrConfig, cliConfig := newTLSConfigs(should)
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), &svrListener{t: t},
+		server.WithTLSConfig(svrConfig), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	receiver := &orderListener{received: make(chan any, 8)}
+	cli, err := client.NewClient(newCodec(), receiver, client.WithTLSConfig(cliConfig))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	// 多条消息跨越多个TLS记录
+	expects := []any{"hello", strings.Repeat("a", 6000), strings.Repeat("b", 6000), strings.Repeat("c", 6000)}
+	cli.SendMessage(expects[0])
+	cli.SendMessages(expects[1:]...)
+	for _, expected := range expects {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("tls echo timeout")
+		}
+	}
+	should.Nil(cli.Close())
+	// 未配置证书的客户端无法完成握手
+	plain, err := client.NewClient(newCodec(), &cliListener{t: t},
+		client.WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()}))
+	should.Nil(err)
+	should.NotNil(plain.Dial(context.Background(), addr))
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type greetListener struct {
+	session.EmptyListener
+}
+
+func (listener *greetListener) OnOpened(session session.Session) {
+	session.SendMessage("welcome")
+}
+
+func (listener *greetListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	session.SendMessage(msg)
+	return nil
+}
+
+func TestTCP_TLSGreeting(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12101"
+	svrConfig, cliConfig := newTLSConfigs(should)
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), &greetListener{},
+		server.WithTLSConfig(svrConfig), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	// 开启时发送的消息先于回显到达
+	receiver := &orderListener{received: make(chan any, 2)}
+	cli, err := client.NewClient(newCodec(), receiver, client.WithTLSConfig(cliConfig))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage("hello")
+	for _, expected := range []any{"welcome", "hello"} {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("tls receive timeout")
+		}
+	}
+	should.Nil(cli.Close())
+	should.Eventually(func() bool {
+		count := 0
+		svr.RangeSessions(func(sess session.Session) bool {
+			count++
+			return true
+		})
+		return count == 0
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// 握手完成前发送的消息暂存至握手完成后写出
+	raw, err := net.Dial("tcp", addr)
+	should.Nil(err)
+	defer func() {
+		_ = raw.Close()
+	}()
+	var sess session.Session
+	should.Eventually(func() bool {
+		svr.RangeSessions(func(s session.Session) bool {
+			sess = s
+			return false
+		})
+		return sess != nil
+	}, 3*time.Second, 10*time.Millisecond)
+	sess.SendMessage("early")
+	tlsConn := tls.Client(raw, &tls.Config{RootCAs: cliConfig.RootCAs, ServerName: "127.0.0.1"})
+	should.Nil(tlsConn.Handshake())
+	should.Nil(tlsConn.SetReadDeadline(time.Now().Add(3 * time.Second)))
+	var received []byte
+	buf := make([]byte, 1024)
+	for !strings.Contains(string(received), "welcome") {
+		n, rErr := tlsConn.Read(buf)
+		should.Nil(rErr)
+		received = append(received, buf[:n]...)
+	}
+	early := strings.Index(string(received), "early")
+	should.GreaterOrEqual(early, 0)
+	should.Less(early, strings.Index(string(received), "welcome"))
+}
+
+type drainListener struct {
+	session.EmptyListener
+	nextId atomic.Uint64
+	opened chan struct{}
+	closed chan error
+}
+
+func (listener *drainListener) OnOpened(sess session.Session) {
+	ctx := &session.BaseContext{}
+	ctx.Init(listener.nextId.Add(1))
+	ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+	if err := sess.Register(ctx); err == nil {
+		listener.opened <- struct{}{}
+	}
+}
+
+func (listener *drainListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func TestTCP_Drain(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12086"
+	listener := &drainListener{opened: make(chan struct{}, 2), closed: make(chan error, 2)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener,
+		server.WithDrain(time.Second, func() any { return "shutting down" }), reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	receivers := make([]*orderListener, 2)
+	clients := make([]*client.Client, 2)
+	for i := range clients {
+		receivers[i] = &orderListener{received: make(chan any, 1)}
+		clients[i], err = client.NewClient(newCodec(), receivers[i])
+		should.Nil(err)
+		should.Nil(clients[i].Dial(context.Background(), addr))
+		<-listener.opened
+	}
+	stopped := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		stopped <- svr.Stop(ctx)
+	}()
+	for _, receiver := range receivers {
+		select {
+		case msg := <-receiver.received:
+			should.Equal("shutting down", msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("drain message timeout")
+		}
+	}
+	// 仅一个客户端主动断开，另一个在截止时间后被强制关闭
+	should.Nil(clients[0].Close())
+	reason := (<-listener.closed).(*session.CloseReason)
+	should.NotErrorIs(reason, pnet.ErrServerDraining)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+	reason = (<-listener.closed).(*session.CloseReason)
+	should.ErrorIs(reason, pnet.ErrServerDraining)
+	should.Equal(session.CloseShutdown, reason.Category)
+	// 强制关闭及停止引擎均不超出停止上下文的截止时间
+	select {
+	case err = <-stopped:
+		should.ErrorIs(err, context.DeadlineExceeded)
+		should.Less(time.Since(start), time.Second)
+	case <-time.After(5 * time.Second):
+		should.FailNow("stop timeout")
+	}
+	// 引擎在截止时间后继续停止，再次调用等待其结束，已结束时同样返回nil
+	should.Nil(svr.Stop(context.Background()))
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type limitListener struct {
+	svrListener
+	events chan session.LimitEvent
+}
+
+func (listener *limitListener) OnLimited(session session.Session, event session.LimitEvent) {
+	listener.events <- event
+}
+
+func TestTCP_Limit(t *testing.T) {
+	should := require.New(t)
+	expectEvent := func(events chan session.LimitEvent, kind session.LimitKind) session.LimitEvent {
+		select {
+		case event := <-events:
+			should.Equal(kind, event.Kind)
+			return event
+		case <-time.After(3 * time.Second):
+			should.FailNow("limit event timeout")
+		}
+		return session.LimitEvent{}
+	}
+	expectEcho := func(receiver *orderListener, expected any, timeout time.Duration) bool {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(expected, msg)
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+	for _, policy := range []session.LimitPolicy{session.LimitDrop, session.LimitDelay} {
+		addr := "127.0.0.1:12087"
+		if policy == session.LimitDelay {
+			addr = "127.0.0.1:12088"
+		}
+		listener := &limitListener{svrListener: svrListener{t: t}, events: make(chan session.LimitEvent, 4)}
+		svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr(),
+			server.WithLimit(&session.LimitOptions{
+				MessagesPerSecond:   2,
+				MessageBurst:        1,
+				Policy:              policy,
+				MaxConnectionsPerIP: 1,
+			}))
+		should.Nil(err)
+		should.Nil(svr.Start(context.Background()))
+		receiver := &orderListener{received: make(chan any, 2)}
+		cli, err := client.NewClient(newCodec(), receiver)
+		should.Nil(err)
+		should.Nil(cli.Dial(context.Background(), addr))
+		cli.SendMessage("a")
+		should.True(expectEcho(receiver, "a", 3*time.Second))
+		begin := time.Now()
+		cli.SendMessage("b")
+		event := expectEvent(listener.events, session.LimitMessageRate)
+		should.Equal(policy, event.Policy)
+		if policy == session.LimitDrop {
+			should.False(expectEcho(receiver, "b", 300*time.Millisecond))
+		} else {
+			should.True(expectEcho(receiver, "b", 3*time.Second))
+			should.GreaterOrEqual(time.Since(begin), 200*time.Millisecond)
+		}
+		// 同一IP的第二个连接被拒绝
+		other, err := client.NewClient(newCodec(), &orderListener{})
+		should.Nil(err)
+		should.Nil(other.Dial(context.Background(), addr))
+		expectEvent(listener.events, session.LimitIPConnections)
+		_ = other.Close()
+		should.Nil(cli.Close())
+		should.Nil(svr.Stop(context.Background()))
+	}
+}
+
+type proxyListener struct {
+	session.EmptyListener
+	addrs chan net.Addr
+}
+
+func (listener *proxyListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	listener.addrs <- session.Connection().RemoteAddr()
+	return
+}
+
+func TestTCP_ConnLimit(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12102"
+	listener := &limitListener{svrListener: svrListener{t: t}, events: make(chan session.LimitEvent, 4)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr(),
+		server.WithLimit(&session.LimitOptions{MaxConnections: 1}))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	dial := func() (*client.Client, *orderListener) {
+		receiver := &orderListener{received: make(chan any, 1)}
+		cli, dErr := client.NewClient(newCodec(), receiver)
+		should.Nil(dErr)
+		should.Nil(cli.Dial(context.Background(), addr))
+		return cli, receiver
+	}
+	expectRejected := func() {
+		cli, _ := dial()
+		select {
+		case event := <-listener.events:
+			should.Equal(session.LimitConnections, event.Kind)
+		case <-time.After(3 * time.Second):
+			should.FailNow("limit event timeout")
+		}
+		_ = cli.Close()
+	}
+	expectEcho := func(cli *client.Client, receiver *orderListener) {
+		cli.SendMessage("hi")
+		select {
+		case msg := <-receiver.received:
+			should.Equal("hi", msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("echo timeout")
+		}
+	}
+	first, receiver := dial()
+	expectEcho(first, receiver)
+	// 被拒绝的连接不释放已占用的连接数，多次拒绝后仍保持上限
+	expectRejected()
+	expectRejected()
+	// 关闭后释放一次，可再接受一个连接
+	should.Nil(first.Close())
+	time.Sleep(200 * time.Millisecond)
+	second, receiver := dial()
+	expectEcho(second, receiver)
+	expectRejected()
+	should.Nil(second.Close())
+}
+
+func TestTCP_Proxy(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12089"
+	listener := &proxyListener{addrs: make(chan net.Addr, 1)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr(),
+		server.WithProxyProtocol(session.ProxyRequired))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	data, err := newCodec().Encode("hello")
+	should.Nil(err)
+	v2Header := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x21, 0x11, 0, 12,
+		10, 0, 0, 1, 10, 0, 0, 2, 0x1f, 0x90, 0, 80)
+	for header, expected := range map[string]string{
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n": "1.2.3.4:1234",
+		string(v2Header):                         "10.0.0.1:8080",
+	} {
+		conn, err := net.Dial("tcp", addr)
+		should.Nil(err)
+		// 协议头与消息分两次发送
+		_, err = conn.Write([]byte(header[:10]))
+		should.Nil(err)
+		time.Sleep(50 * time.Millisecond)
+		_, err = conn.Write(append([]byte(header[10:]), data...))
+		should.Nil(err)
+		select {
+		case remoteAddr := <-listener.addrs:
+			should.Equal(expected, remoteAddr.String())
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+		should.Nil(conn.Close())
+	}
+	// 缺少协议头的连接被拒绝
+	conn, err := net.Dial("tcp", addr)
+	should.Nil(err)
+	_, err = conn.Write(data)
+	should.Nil(err)
+	should.Nil(conn.SetReadDeadline(time.Now().Add(3 * time.Second)))
+	_, err = conn.Read(make([]byte, 16))
+	should.NotNil(err)
+	should.False(os.IsTimeout(err))
+	_ = conn.Close()
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type resumeListener struct {
+	session.EmptyListener
+	svr    *server.Server
+	closed chan struct{}
+}
+
+func (listener *resumeListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.closed <- struct{}{}
+}
+
+func (listener *resumeListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	text, _ := msg.(string)
+	switch {
+	case text == "login":
+		ctx := &session.BaseContext{}
+		ctx.Init(1)
+		ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+		if err = sess.Register(ctx); err != nil {
+			return
+		}
+		session.SetAttribute(sess, "user", "tester")
+		sess.SendMessages("token:"+sess.ResumeState().Token(), "m1")
+		sess.SendMessage("m2")
+	case strings.HasPrefix(text, "resume:"):
+		fields := strings.Split(text, ":")
+		seq, _ := strconv.ParseUint(fields[2], 10, 64)
+		if err = listener.svr.ResumeSession(sess, fields[1], seq); err != nil {
+			sess.SendMessage("resume failed")
+			return nil
+		}
+	default:
+		sess.SendMessage(msg)
+	}
+	return
+}
+
+func (listener *resumeListener) OnReceiveMulti(sess session.Session, msgArr []any, totalLen int) error {
+	for _, msg := range msgArr {
+		_ = listener.OnReceive(sess, msg, 0)
+	}
+	return nil
+}
+
+func TestTCP_Resume(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12103"
+	listener := &resumeListener{closed: make(chan struct{}, 4)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr(),
+		server.WithResume(time.Minute, 8))
+	should.Nil(err)
+	listener.svr = svr
+	should.Nil(svr.Start(context.Background()))
+	expect := func(receiver *orderListener, expected any) {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	receiver := &orderListener{received: make(chan any, 8)}
+	cli, err := newClient(receiver)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage("login")
+	var token string
+	select {
+	case msg := <-receiver.received:
+		token = strings.TrimPrefix(msg.(string), "token:")
+	case <-time.After(3 * time.Second):
+		should.FailNow("login timeout")
+	}
+	expect(receiver, "m1")
+	expect(receiver, "m2")
+	should.Nil(cli.Close())
+	select {
+	case <-listener.closed:
+	case <-time.After(3 * time.Second):
+		should.FailNow("close timeout")
+	}
+	// 假设客户端只收到了前两条消息
+	receiver = &orderListener{received: make(chan any, 8)}
+	cli, err = newClient(receiver)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage("resume:" + token + ":2")
+	expect(receiver, "m2")
+	cli.SendMessage("echo")
+	expect(receiver, "echo")
+	resumed := svr.GetSession(1)
+	should.NotNil(resumed)
+	should.False(resumed.IsClosed())
+	should.Equal(uint64(4), resumed.ResumeState().Seq())
+	// 恢复后保留原会话的属性
+	user, ok := session.GetAttribute[string](resumed, "user")
+	should.True(ok)
+	should.Equal("tester", user)
+	// 无效令牌
+	other, err := newClient(receiver)
+	should.Nil(err)
+	should.Nil(other.Dial(context.Background(), addr))
+	other.SendMessage("resume:invalid:0")
+	expect(receiver, "resume failed")
+	_ = other.Close()
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+// stateMessage 慢速时可合并的状态消息
+type stateMessage struct {
+	key  string
+	text string
+}
+
+func (msg stateMessage) CoalesceKey() string {
+	return msg.key
+}
+
+type stateCodec struct {
+	message.TextCodec
+}
+
+func (codec *stateCodec) Encode(msg any) ([]byte, error) {
+	if state, ok := msg.(stateMessage); ok {
+		return []byte(state.text), nil
+	}
+	return codec.TextCodec.Encode(msg)
+}
+
+type backpressureListener struct {
+	session.EmptyListener
+	opened chan session.Session
+	events chan bool
+}
+
+func (listener *backpressureListener) OnOpened(session session.Session) {
+	listener.opened <- session
+}
+
+func (listener *backpressureListener) OnBackpressure(session session.Session, slow bool) {
+	listener.events <- slow
+}
+
+func TestTCP_Backpressure(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12091"
+	listener := &backpressureListener{opened: make(chan session.Session, 1), events: make(chan bool, 4)}
+	svrCodec, err := codec.NewLengthFieldCodec(codec.WithMessageCodec[*codec.LengthOptions](&stateCodec{}))
+	should.Nil(err)
+	svr, err := server.NewServer("test-server", "tcp://"+addr, svrCodec, listener, reuseAddr(),
+		server.WithBackpressure(256*1024, 0, session.BackpressureCoalesce))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	// 客户端暂不读取
+	conn, err := net.Dial("tcp", addr)
+	should.Nil(err)
+	var sess session.Session
+	select {
+	case sess = <-listener.opened:
+	case <-time.After(3 * time.Second):
+		should.FailNow("open timeout")
+	}
+	chunk := strings.Repeat("x", 4000)
+	slow := false
+	for i := 0; i < 16384 && !slow; i++ {
+		sess.SendMessage(chunk)
+		select {
+		case slow = <-listener.events:
+		default:
+			if i%16 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	should.True(slow)
+	should.True(sess.Connection().IsSlow())
+	// 慢速时同键消息只保留最新的一条
+	sess.SendMessage(stateMessage{key: "pos", text: "p1"})
+	sess.SendMessage(stateMessage{key: "pos", text: "p2"})
+	received := make([]byte, 0, 1024*1024)
+	buf := make([]byte, 64*1024)
+	should.Nil(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	for !strings.Contains(string(received), "p2") {
+		n, err := conn.Read(buf)
+		should.Nil(err)
+		received = append(received, buf[:n]...)
+	}
+	should.NotContains(string(received), "p1")
+	select {
+	case slow = <-listener.events:
+		should.False(slow)
+	case <-time.After(3 * time.Second):
+		should.FailNow("recover timeout")
+	}
+	should.False(sess.Connection().IsSlow())
+	_ = conn.Close()
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type attrListener struct {
+	session.EmptyListener
+	closed chan session.Session
+}
+
+func (listener *attrListener) OnOpened(sess session.Session) {
+	session.SetAttribute(sess, "locale", "zh-CN")
+}
+
+func (listener *attrListener) OnClosed(sess session.Session, reason *session.CloseReason) {
+	if locale, ok := session.GetAttribute[string](sess, "locale"); ok && locale == "zh-CN" {
+		listener.closed <- sess
+	}
+}
+
+func (listener *attrListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	if version, ok := session.GetAttribute[int](sess, "locale"); ok {
+		// 类型不符时不返回属性
+		sess.SendMessage(strconv.Itoa(version))
+		return
+	}
+	locale, _ := session.GetAttribute[string](sess, "locale")
+	sess.SendMessage(locale)
+	return
+}
+
+func TestTCP_Attributes(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12092"
+	listener := &attrListener{closed: make(chan session.Session, 1)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	receiver := &orderListener{received: make(chan any, 1)}
+	cli, err := newClient(receiver)
+	should.Nil(err)
+	session.SetAttribute(cli, "user", uint64(1001))
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage("locale")
+	select {
+	case msg := <-receiver.received:
+		should.Equal("zh-CN", msg)
+	case <-time.After(3 * time.Second):
+		should.FailNow("receive timeout")
+	}
+	user, ok := session.GetAttribute[uint64](cli, "user")
+	should.True(ok)
+	should.Equal(uint64(1001), user)
+	should.Nil(cli.Close())
+	// 关闭后清空属性
+	should.Eventually(func() bool {
+		_, ok := session.GetAttribute[uint64](cli, "user")
+		return !ok
+	}, 3*time.Second, 10*time.Millisecond)
+	select {
+	case sess := <-listener.closed:
+		should.Eventually(func() bool {
+			_, ok := session.GetAttribute[string](sess, "locale")
+			return !ok
+		}, 3*time.Second, 10*time.Millisecond)
+	case <-time.After(3 * time.Second):
+		should.FailNow("close timeout")
+	}
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type reasonListener struct {
+	session.EmptyListener
+	opened chan struct{}
+	closed chan *session.CloseReason
+}
+
+func (listener *reasonListener) OnOpened(sess session.Session) {
+	if listener.opened != nil {
+		listener.opened <- struct{}{}
+	}
+}
+
+func (listener *reasonListener) OnClosed(sess session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func (listener *reasonListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	switch msg {
+	case "login":
+		ctx := &session.BaseContext{}
+		ctx.Init(1)
+		ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+		if err = sess.Register(ctx); err == nil {
+			sess.SendMessage("ok")
+		}
+	case "bye":
+		err = sess.Close()
+	}
+	return
+}
+
+func TestTCP_CloseReason(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12093"
+	listener := &reasonListener{closed: make(chan *session.CloseReason, 1)}
+	svr, err := server.NewServer("test-server", "tcp://"+addr, newCodec(), listener, reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	expectReason := func(closed chan *session.CloseReason) *session.CloseReason {
+		select {
+		case reason := <-closed:
+			should.NotNil(reason)
+			return reason
+		case <-time.After(3 * time.Second):
+			should.FailNow("close timeout")
+		}
+		return nil
+	}
+	login := func() (*client.Client, *reasonListener) {
+		receiver := &reasonListener{opened: make(chan struct{}, 1), closed: make(chan *session.CloseReason, 1)}
+		cli, err := newClient(receiver)
+		should.Nil(err)
+		should.Nil(cli.Dial(context.Background(), addr))
+		<-receiver.opened
+		cli.SendMessage("login")
+		return cli, receiver
+	}
+
+	// 重复登录时旧会话被踢下线
+	_, first := login()
+	time.Sleep(100 * time.Millisecond)
+	second, receiver := login()
+	reason := expectReason(listener.closed)
+	should.Equal(session.CloseKicked, reason.Category)
+	should.Equal(session.CloseByLocal, reason.Initiator)
+	should.ErrorIs(reason, pnet.ErrSessionKicked)
+	reason = expectReason(first.closed)
+	should.Equal(session.CloseNetwork, reason.Category)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+
+	// 服务端主动关闭
+	second.SendMessage("bye")
+	reason = expectReason(listener.closed)
+	should.Equal(session.CloseNormal, reason.Category)
+	should.Equal(session.CloseByLocal, reason.Initiator)
+	expectReason(receiver.closed)
+
+	// 客户端主动关闭
+	third, _ := login()
+	time.Sleep(100 * time.Millisecond)
+	should.Nil(third.Close())
+	reason = expectReason(listener.closed)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type typedReq struct {
+	Text string `json:"text"`
+}
+
+type typedResp struct {
+	Text string `json:"text"`
+}
+
+func newTypedMessageCodec() *message.JsonCodec {
+	jsonCodec := message.NewJsonCodec()
+	_ = jsonCodec.RegisterMessage("req", func() any { return &typedReq{} })
+	_ = jsonCodec.RegisterMessage("resp", func() any { return &typedResp{} })
+	return jsonCodec
+}
+
+type typedListener struct {
+	session.EmptyTypedListener[*typedReq, *typedResp]
+	closed chan *session.CloseReason
+}
+
+func (listener *typedListener) OnReceive(sess session.TypedSession[*typedResp], msg *typedReq, msgLen int) error {
+	sess.SendMessages(&typedResp{Text: strings.ToUpper(msg.Text)}, &typedResp{Text: msg.Text})
+	return nil
+}
+
+func (listener *typedListener) OnClosed(sess session.TypedSession[*typedResp], reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func TestTCP_Typed(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12094"
+	listener := &typedListener{closed: make(chan *session.CloseReason, 1)}
+	svr, err := server.NewTypedServer[*typedReq, *typedResp]("test-server", "tcp://"+addr, newCodec(),
+		message.AsTypedCodec[*typedReq, *typedResp](newTypedMessageCodec()), listener, reuseAddr())
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cliCodec, err := codec.NewLengthFieldCodec(
+		codec.WithMessageCodec[*codec.LengthOptions](newTypedMessageCodec()))
+	should.Nil(err)
+	receiver := &orderListener{received: make(chan any, 2)}
+	cli, err := client.NewClient(cliCodec, receiver)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	cli.SendMessage(&typedReq{Text: "meow"})
+	for _, expected := range []string{"MEOW", "meow"} {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(&typedResp{Text: expected}, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	// 接收到非声明类型的消息时按协议错误关闭
+	cli.SendMessage(&typedResp{Text: "meow"})
+	select {
+	case reason := <-listener.closed:
+		should.Equal(session.CloseProtocol, reason.Category)
+		should.ErrorIs(reason, pnet.ErrMismatchedMessage)
+	case <-time.After(3 * time.Second):
+		should.FailNow("close timeout")
+	}
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestTCP_Unix(t *testing.T) {
+	should := require.New(t)
+	// gnet会将地址转为小写，不使用可能含大写的系统临时目录
+	dir, err := os.MkdirTemp("/tmp", "pnet")
+	should.Nil(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := dir + "/server.sock"
+	// 残留的socket文件
+	ln, err := net.Listen("unix", path)
+	should.Nil(err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	should.Nil(ln.Close())
+	_, err = os.Stat(path)
+	should.Nil(err)
+
+	svr, err := newServer("unix://"+path, &svrListener{t: t})
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	receivers := make([]*orderListener, 2)
+	clients := make([]*client.Client, 2)
+	for i := range clients {
+		receivers[i] = &orderListener{received: make(chan any, 1)}
+		clients[i], err = newClient(receivers[i])
+		should.Nil(err)
+		should.Nil(clients[i].Dial(context.Background(), "unix://"+path))
+	}
+	should.NotEqual(clients[0].Connection().Hash(), clients[1].Connection().Hash())
+	for i, cli := range clients {
+		cli.SendMessage("unix" + strconv.Itoa(i))
+		select {
+		case msg := <-receivers[i].received:
+			should.Equal("unix"+strconv.Itoa(i), msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	// 仍在监听的socket文件不会被清理
+	other, err := newServer("unix://"+path, &svrListener{t: t})
+	should.Nil(err)
+	should.NotNil(other.Start(context.Background()))
+	for _, cli := range clients {
+		should.Nil(cli.Close())
+	}
+	should.Nil(svr.Stop(context.Background()))
+	_, err = os.Stat(path)
+	should.True(os.IsNotExist(err))
+
+	// 非socket文件不会被清理
+	should.Nil(os.WriteFile(path, []byte("data"), 0600))
+	svr, err = newServer("unix://"+path, &svrListener{t: t})
+	should.Nil(err)
+	should.NotNil(svr.Start(context.Background()))
+	_, err = newServer("unix://"+dir+"/Server.sock", &svrListener{t: t})
+	should.NotNil(err)
+}
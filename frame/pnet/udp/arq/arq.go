@@ -0,0 +1,710 @@
+package arq
+
+import (
+	"github.com/meow-pad/persian/frame/pnet"
+	"math"
+)
+
+const (
+	// rtoMax 重传超时上限，单位毫秒
+	rtoMax = 60000
+	// rtoDefault 初始重传超时，单位毫秒
+	rtoDefault = 200
+	// threshInit 初始慢启动阈值
+	threshInit = 2
+	// threshMin 慢启动阈值下限
+	threshMin = 2
+	// probeInit 对端接收窗口为0时首次询问的等待时间，单位毫秒
+	probeInit = 7000
+	// probeLimit 询问等待时间上限，单位毫秒
+	probeLimit = 120000
+	// maxFragments 单条消息的分片数上限
+	maxFragments = 255
+)
+
+const (
+	// askSend 需询问对端窗口
+	askSend = 1 << iota
+	// askTell 需告知本端窗口
+	askTell
+)
+
+type ackItem struct {
+	sn uint32
+	ts uint32
+}
+
+// NewARQ
+//
+//	@Description: 构造可靠传输状态机
+//	@param conv 会话编号，两端需一致
+//	@param opts 配置，为nil时使用默认配置
+//	@param output 数据包输出函数，数据包在返回后会被复用
+//	@return *ARQ
+func NewARQ(conv uint32, opts *Options, output func(packet []byte)) *ARQ {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	// 复制后补全，配置可在多个连接间共享
+	options := *opts
+	options.complete()
+	opts = &options
+	arq := &ARQ{
+		opts:     opts,
+		conv:     conv,
+		mss:      uint32(opts.MTU - overhead),
+		sndWnd:   uint32(opts.SendWindow),
+		rcvWnd:   uint32(opts.RecvWindow),
+		rmtWnd:   uint32(opts.RecvWindow),
+		rxRto:    rtoDefault,
+		rxMinRto: uint32(opts.MinRTO.Milliseconds()),
+		interval: uint32(opts.Interval.Milliseconds()),
+		ssthresh: threshInit,
+		cwnd:     1,
+		output:   output,
+		buffer:   make([]byte, opts.MTU),
+	}
+	arq.incr = arq.mss
+	if arq.interval <= 0 {
+		arq.interval = 1
+	}
+	return arq
+}
+
+// ARQ
+//
+//	@Description: 选择确认的自动重传状态机，按消息收发，不是协程安全的
+type ARQ struct {
+	opts *Options
+	conv uint32
+	mss  uint32
+
+	// 发送方已确认的最小序号
+	sndUna uint32
+	// 下一个发送序号
+	sndNxt uint32
+	// 下一个待接收序号
+	rcvNxt uint32
+
+	// 往返时间估计，单位毫秒
+	rxRttVal uint32
+	rxSrtt   uint32
+	rxRto    uint32
+	rxMinRto uint32
+
+	sndWnd uint32
+	rcvWnd uint32
+	// 对端剩余接收窗口
+	rmtWnd uint32
+	// 拥塞窗口及慢启动阈值
+	cwnd     uint32
+	ssthresh uint32
+	incr     uint32
+
+	// 窗口询问
+	probe     uint32
+	tsProbe   uint32
+	probeWait uint32
+
+	interval uint32
+	tsFlush  uint32
+	updated  bool
+	// 当前时间，单位毫秒
+	current uint32
+	// 是否已断开
+	dead bool
+
+	sndQueue []*segment
+	sndBuf   []*segment
+	rcvQueue []*segment
+	rcvBuf   []*segment
+	ackList  []ackItem
+
+	output func(packet []byte)
+	buffer []byte
+}
+
+// Send
+//
+//	@Description: 提交消息，超过最大分片长度时拆分为多个分片
+//	@receiver arq
+//	@param data 消息数据，会被复制
+//	@return error 分片数超过上限或接收窗口时返回 pnet.ErrMessageTooLarge，发送队列已满时返回 pnet.ErrWriteQueueFull
+func (arq *ARQ) Send(data []byte) error {
+	count := arq.fragments(len(data))
+	if count > maxFragments || count > arq.rcvWnd {
+		return pnet.ErrMessageTooLarge
+	}
+	if !arq.Acceptable(int(count)) {
+		return pnet.ErrWriteQueueFull
+	}
+	for i := uint32(0); i < count; i++ {
+		size := uint32(len(data))
+		if size > arq.mss {
+			size = arq.mss
+		}
+		seg := &segment{
+			frg:  uint8(count - i - 1),
+			data: append([]byte(nil), data[:size]...),
+		}
+		arq.sndQueue = append(arq.sndQueue, seg)
+		data = data[size:]
+	}
+	return nil
+}
+
+// Fragments
+//
+//	@Description: 消息拆分的分片数
+//	@receiver arq
+//	@param bs 消息数据
+//	@return int
+func (arq *ARQ) Fragments(bs [][]byte) (count int) {
+	for _, b := range bs {
+		count += int(arq.fragments(len(b)))
+	}
+	return
+}
+
+func (arq *ARQ) fragments(size int) uint32 {
+	count := (uint32(size) + arq.mss - 1) / arq.mss
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// Acceptable
+//
+//	@Description: 发送队列能否再容纳指定数量的分片
+//	@receiver arq
+//	@param count 分片数
+//	@return bool
+func (arq *ARQ) Acceptable(count int) bool {
+	return arq.WaitSend()+count <= arq.opts.SendQueue
+}
+
+// Recv
+//
+//	@Description: 取出一条完整的消息
+//	@receiver arq
+//	@return []byte 消息数据，可能为空
+//	@return bool 是否有完整的消息
+func (arq *ARQ) Recv() ([]byte, bool) {
+	if len(arq.rcvQueue) <= 0 {
+		return nil, false
+	}
+	count := int(arq.rcvQueue[0].frg) + 1
+	if len(arq.rcvQueue) < count {
+		return nil, false
+	}
+	recover := len(arq.rcvQueue) >= int(arq.rcvWnd)
+	var data []byte
+	if count == 1 {
+		data = arq.rcvQueue[0].data
+	} else {
+		size := 0
+		for _, seg := range arq.rcvQueue[:count] {
+			size += len(seg.data)
+		}
+		data = make([]byte, 0, size)
+		for _, seg := range arq.rcvQueue[:count] {
+			data = append(data, seg.data...)
+		}
+	}
+	arq.rcvQueue = removeFront(arq.rcvQueue, count)
+	arq.moveReceived()
+	if recover && len(arq.rcvQueue) < int(arq.rcvWnd) {
+		// 接收窗口从满恢复，主动告知对端
+		arq.probe |= askTell
+	}
+	return data, true
+}
+
+// moveReceived
+//
+//	@Description: 将接收缓冲中连续的分片移入接收队列
+//	@receiver arq
+func (arq *ARQ) moveReceived() {
+	count := 0
+	for _, seg := range arq.rcvBuf {
+		if seg.sn != arq.rcvNxt || len(arq.rcvQueue) >= int(arq.rcvWnd) {
+			break
+		}
+		arq.rcvQueue = append(arq.rcvQueue, seg)
+		arq.rcvNxt++
+		count++
+	}
+	arq.rcvBuf = removeFront(arq.rcvBuf, count)
+}
+
+// Input
+//
+//	@Description: 输入收到的数据包
+//	@receiver arq
+//	@param packet 数据包
+//	@return fin 对端是否已关闭
+//	@return err
+func (arq *ARQ) Input(packet []byte) (fin bool, err error) {
+	prevUna := arq.sndUna
+	var maxAck, latestTs uint32
+	ackFound := false
+	for len(packet) > 0 {
+		var seg segment
+		if packet, err = seg.decode(packet); err != nil {
+			return
+		}
+		if seg.conv != arq.conv {
+			return fin, ErrMismatchedConv
+		}
+		if seg.cmd == cmdFin {
+			fin = true
+			continue
+		}
+		arq.rmtWnd = uint32(seg.wnd)
+		arq.parseUna(seg.una)
+		arq.shrinkBuf()
+		switch seg.cmd {
+		case cmdAck:
+			if timeDiff(arq.current, seg.ts) >= 0 {
+				arq.updateAck(uint32(timeDiff(arq.current, seg.ts)))
+			}
+			arq.parseAck(seg.sn)
+			arq.shrinkBuf()
+			if !ackFound || timeDiff(seg.sn, maxAck) > 0 {
+				ackFound = true
+				maxAck, latestTs = seg.sn, seg.ts
+			}
+		case cmdPush:
+			if timeDiff(seg.sn, arq.rcvNxt+arq.rcvWnd) < 0 {
+				arq.ackList = append(arq.ackList, ackItem{sn: seg.sn, ts: seg.ts})
+				if timeDiff(seg.sn, arq.rcvNxt) >= 0 {
+					arq.parseData(&seg)
+				}
+			}
+		case cmdWindowAsk:
+			arq.probe |= askTell
+		case cmdWindowTell:
+		}
+	}
+	if ackFound {
+		arq.parseFastAck(maxAck, latestTs)
+	}
+	if timeDiff(arq.sndUna, prevUna) > 0 && arq.cwnd < arq.rmtWnd {
+		arq.growWindow()
+	}
+	return
+}
+
+// growWindow
+//
+//	@Description: 有新的确认时扩大拥塞窗口，慢启动阶段按分片数增长，之后按字节数线性增长
+//	@receiver arq
+func (arq *ARQ) growWindow() {
+	mss := arq.mss
+	if arq.cwnd < arq.ssthresh {
+		arq.cwnd++
+		arq.incr += mss
+	} else {
+		if arq.incr < mss {
+			arq.incr = mss
+		}
+		arq.incr += (mss*mss)/arq.incr + mss/16
+		if (arq.cwnd+1)*mss <= arq.incr {
+			arq.cwnd = (arq.incr + mss - 1) / mss
+		}
+	}
+	if arq.cwnd > arq.rmtWnd {
+		arq.cwnd = arq.rmtWnd
+		arq.incr = arq.rmtWnd * mss
+	}
+}
+
+// updateAck
+//
+//	@Description: 按往返时间更新重传超时
+//	@receiver arq
+//	@param rtt 往返时间，单位毫秒
+func (arq *ARQ) updateAck(rtt uint32) {
+	if arq.rxSrtt == 0 {
+		arq.rxSrtt = rtt
+		arq.rxRttVal = rtt / 2
+	} else {
+		delta := int64(rtt) - int64(arq.rxSrtt)
+		if delta < 0 {
+			delta = -delta
+		}
+		arq.rxRttVal = uint32((3*int64(arq.rxRttVal) + delta) / 4)
+		arq.rxSrtt = (7*arq.rxSrtt + rtt) / 8
+		if arq.rxSrtt < 1 {
+			arq.rxSrtt = 1
+		}
+	}
+	rto := arq.rxSrtt + maxUint32(arq.interval, 4*arq.rxRttVal)
+	arq.rxRto = minUint32(maxUint32(arq.rxMinRto, rto), rtoMax)
+}
+
+// parseUna
+//
+//	@Description: 移除对端已连续接收的分片
+//	@receiver arq
+//	@param una
+func (arq *ARQ) parseUna(una uint32) {
+	count := 0
+	for _, seg := range arq.sndBuf {
+		if timeDiff(una, seg.sn) <= 0 {
+			break
+		}
+		count++
+	}
+	arq.sndBuf = removeFront(arq.sndBuf, count)
+}
+
+// parseAck
+//
+//	@Description: 移除被单独确认的分片
+//	@receiver arq
+//	@param sn
+func (arq *ARQ) parseAck(sn uint32) {
+	if timeDiff(sn, arq.sndUna) < 0 || timeDiff(sn, arq.sndNxt) >= 0 {
+		return
+	}
+	for i, seg := range arq.sndBuf {
+		if seg.sn == sn {
+			arq.sndBuf = append(arq.sndBuf[:i], arq.sndBuf[i+1:]...)
+			return
+		}
+		if timeDiff(sn, seg.sn) < 0 {
+			return
+		}
+	}
+}
+
+// parseFastAck
+//
+//	@Description: 统计被跳跃确认的次数，用于快速重传
+//	@receiver arq
+//	@param sn 本次收到的最大确认序号
+//	@param ts 该确认对应的发送时间
+func (arq *ARQ) parseFastAck(sn, ts uint32) {
+	if timeDiff(sn, arq.sndUna) < 0 || timeDiff(sn, arq.sndNxt) >= 0 {
+		return
+	}
+	for _, seg := range arq.sndBuf {
+		if timeDiff(sn, seg.sn) < 0 {
+			break
+		}
+		if sn != seg.sn && timeDiff(seg.ts, ts) <= 0 {
+			seg.fastAck++
+		}
+	}
+}
+
+// shrinkBuf
+//
+//	@Description: 更新已确认的最小序号
+//	@receiver arq
+func (arq *ARQ) shrinkBuf() {
+	if len(arq.sndBuf) > 0 {
+		arq.sndUna = arq.sndBuf[0].sn
+	} else {
+		arq.sndUna = arq.sndNxt
+	}
+}
+
+// parseData
+//
+//	@Description: 按序号插入接收缓冲，重复的分片被忽略
+//	@receiver arq
+//	@param seg 收到的分片，数据引用输入
+func (arq *ARQ) parseData(seg *segment) {
+	sn := seg.sn
+	if timeDiff(sn, arq.rcvNxt+arq.rcvWnd) >= 0 || timeDiff(sn, arq.rcvNxt) < 0 {
+		return
+	}
+	index := len(arq.rcvBuf)
+	for i := len(arq.rcvBuf) - 1; i >= 0; i-- {
+		cur := arq.rcvBuf[i]
+		if cur.sn == sn {
+			return
+		}
+		if timeDiff(sn, cur.sn) > 0 {
+			break
+		}
+		index = i
+	}
+	received := &segment{sn: sn, frg: seg.frg, data: append([]byte(nil), seg.data...)}
+	arq.rcvBuf = append(arq.rcvBuf, nil)
+	copy(arq.rcvBuf[index+1:], arq.rcvBuf[index:])
+	arq.rcvBuf[index] = received
+	arq.moveReceived()
+}
+
+// wndUnused
+//
+//	@Description: 剩余接收窗口
+//	@receiver arq
+//	@return uint16
+func (arq *ARQ) wndUnused() uint16 {
+	if len(arq.rcvQueue) < int(arq.rcvWnd) {
+		return uint16(minUint32(arq.rcvWnd-uint32(len(arq.rcvQueue)), math.MaxUint16))
+	}
+	return 0
+}
+
+// Update
+//
+//	@Description: 按刷新间隔驱动重传与确认，需定时调用
+//	@receiver arq
+//	@param current 当前时间，单位毫秒，可回绕
+func (arq *ARQ) Update(current uint32) {
+	arq.current = current
+	if !arq.updated {
+		arq.updated = true
+		arq.tsFlush = current
+	}
+	slap := timeDiff(current, arq.tsFlush)
+	if slap >= 10000 || slap < -10000 {
+		arq.tsFlush = current
+		slap = 0
+	}
+	if slap >= 0 {
+		arq.tsFlush += arq.interval
+		if timeDiff(current, arq.tsFlush) >= 0 {
+			arq.tsFlush = current + arq.interval
+		}
+		arq.Flush()
+	}
+}
+
+// SetCurrent
+//
+//	@Description: 更新当前时间，用于在两次 Update 之间收发时计算往返时间
+//	@receiver arq
+//	@param current 当前时间，单位毫秒
+func (arq *ARQ) SetCurrent(current uint32) {
+	arq.current = current
+}
+
+// Flush
+//
+//	@Description: 立即发出确认、窗口探测、新分片及需重传的分片
+//	@receiver arq
+func (arq *ARQ) Flush() {
+	current := arq.current
+	buf := arq.buffer[:0]
+	writeSeg := func(seg *segment) {
+		if len(buf)+overhead+len(seg.data) > arq.opts.MTU {
+			arq.output(buf)
+			buf = arq.buffer[:0]
+		}
+		end := len(buf) + overhead + len(seg.data)
+		seg.encode(arq.buffer[len(buf):end])
+		buf = arq.buffer[:end]
+	}
+	base := segment{conv: arq.conv, wnd: arq.wndUnused(), una: arq.rcvNxt}
+
+	// 确认
+	ack := base
+	ack.cmd = cmdAck
+	for _, item := range arq.ackList {
+		ack.sn, ack.ts = item.sn, item.ts
+		writeSeg(&ack)
+	}
+	arq.ackList = arq.ackList[:0]
+
+	// 对端接收窗口为0时定时询问
+	if arq.rmtWnd == 0 {
+		if arq.probeWait == 0 {
+			arq.probeWait = probeInit
+			arq.tsProbe = current + arq.probeWait
+		} else if timeDiff(current, arq.tsProbe) >= 0 {
+			if arq.probeWait < probeInit {
+				arq.probeWait = probeInit
+			}
+			arq.probeWait += arq.probeWait / 2
+			if arq.probeWait > probeLimit {
+				arq.probeWait = probeLimit
+			}
+			arq.tsProbe = current + arq.probeWait
+			arq.probe |= askSend
+		}
+	} else {
+		arq.tsProbe = 0
+		arq.probeWait = 0
+	}
+	if arq.probe&askSend != 0 {
+		probe := base
+		probe.cmd = cmdWindowAsk
+		writeSeg(&probe)
+	}
+	if arq.probe&askTell != 0 {
+		tell := base
+		tell.cmd = cmdWindowTell
+		writeSeg(&tell)
+	}
+	arq.probe = 0
+
+	// 按窗口将待发送的分片移入发送缓冲
+	cwnd := minUint32(arq.sndWnd, arq.rmtWnd)
+	if !arq.opts.NoCongestion {
+		cwnd = minUint32(arq.cwnd, cwnd)
+	}
+	count := 0
+	for _, seg := range arq.sndQueue {
+		if timeDiff(arq.sndNxt, arq.sndUna+cwnd) >= 0 {
+			break
+		}
+		seg.conv = arq.conv
+		seg.cmd = cmdPush
+		seg.sn = arq.sndNxt
+		arq.sndNxt++
+		seg.rto = arq.rxRto
+		arq.sndBuf = append(arq.sndBuf, seg)
+		count++
+	}
+	arq.sndQueue = removeFront(arq.sndQueue, count)
+
+	resent := uint32(math.MaxUint32)
+	if arq.opts.FastResend > 0 {
+		resent = uint32(arq.opts.FastResend)
+	}
+	var rtoMin uint32
+	if !arq.opts.NoDelay {
+		rtoMin = arq.rxRto >> 3
+	}
+	changed, lost := false, false
+	for _, seg := range arq.sndBuf {
+		needSend := false
+		if seg.xmit == 0 {
+			needSend = true
+			seg.rto = arq.rxRto
+			seg.resendTs = current + seg.rto + rtoMin
+		} else if timeDiff(current, seg.resendTs) >= 0 {
+			// 超时重传
+			needSend = true
+			if arq.opts.NoDelay {
+				seg.rto += seg.rto / 2
+			} else {
+				seg.rto += maxUint32(seg.rto, arq.rxRto)
+			}
+			seg.rto = minUint32(seg.rto, rtoMax)
+			seg.resendTs = current + seg.rto
+			lost = true
+		} else if seg.fastAck >= resent {
+			// 快速重传
+			if arq.opts.FastLimit <= 0 || seg.xmit <= uint32(arq.opts.FastLimit) {
+				needSend = true
+				seg.fastAck = 0
+				seg.resendTs = current + seg.rto
+				changed = true
+			}
+		}
+		if !needSend {
+			continue
+		}
+		seg.xmit++
+		seg.ts = current
+		seg.wnd = base.wnd
+		seg.una = arq.rcvNxt
+		writeSeg(seg)
+		if seg.xmit >= uint32(arq.opts.DeadLink) {
+			arq.dead = true
+		}
+	}
+	if len(buf) > 0 {
+		arq.output(buf)
+	}
+
+	// 按丢包调整拥塞窗口
+	if changed {
+		inflight := arq.sndNxt - arq.sndUna
+		arq.ssthresh = maxUint32(inflight/2, threshMin)
+		arq.cwnd = arq.ssthresh + resent
+		arq.incr = arq.cwnd * arq.mss
+	}
+	if lost {
+		arq.ssthresh = maxUint32(arq.cwnd/2, threshMin)
+		arq.cwnd = 1
+		arq.incr = arq.mss
+	}
+	if arq.cwnd < 1 {
+		arq.cwnd = 1
+		arq.incr = arq.mss
+	}
+}
+
+// Probe
+//
+//	@Description: 在下次刷新时告知对端接收窗口，可用于保活
+//	@receiver arq
+func (arq *ARQ) Probe() {
+	arq.probe |= askTell
+}
+
+// WaitSend
+//
+//	@Description: 待发送及未确认的分片数
+//	@receiver arq
+//	@return int
+func (arq *ARQ) WaitSend() int {
+	return len(arq.sndBuf) + len(arq.sndQueue)
+}
+
+// WaitSendBytes
+//
+//	@Description: 待发送及未确认的数据字节数
+//	@receiver arq
+//	@return int
+func (arq *ARQ) WaitSendBytes() (n int) {
+	for _, seg := range arq.sndBuf {
+		n += len(seg.data)
+	}
+	for _, seg := range arq.sndQueue {
+		n += len(seg.data)
+	}
+	return
+}
+
+// Dead
+//
+//	@Description: 是否有分片的重传次数达到上限
+//	@receiver arq
+//	@return bool
+func (arq *ARQ) Dead() bool {
+	return arq.dead
+}
+
+// Conv
+//
+//	@Description: 会话编号
+//	@receiver arq
+//	@return uint32
+func (arq *ARQ) Conv() uint32 {
+	return arq.conv
+}
+
+func removeFront(segs []*segment, count int) []*segment {
+	if count <= 0 {
+		return segs
+	}
+	n := copy(segs, segs[count:])
+	for i := n; i < len(segs); i++ {
+		segs[i] = nil
+	}
+	return segs[:n]
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,170 @@
+package arq
+
+import (
+	"bytes"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+type delayedPacket struct {
+	at   uint32
+	data []byte
+}
+
+// lossyPipe 单向的模拟链路，按概率丢包并固定延迟
+type lossyPipe struct {
+	rand    *rand.Rand
+	loss    float64
+	delay   uint32
+	packets []delayedPacket
+	sent    int
+	dropped int
+}
+
+func (pipe *lossyPipe) send(now uint32, packet []byte) {
+	pipe.sent++
+	if pipe.rand.Float64() < pipe.loss {
+		pipe.dropped++
+		return
+	}
+	pipe.packets = append(pipe.packets, delayedPacket{at: now + pipe.delay, data: append([]byte(nil), packet...)})
+}
+
+func (pipe *lossyPipe) deliver(now uint32, arq *ARQ) {
+	count := 0
+	for _, packet := range pipe.packets {
+		if timeDiff(now, packet.at) < 0 {
+			break
+		}
+		_, _ = arq.Input(packet.data)
+		count++
+	}
+	pipe.packets = pipe.packets[count:]
+}
+
+func transfer(t *testing.T, opts *Options, loss float64, messages [][]byte) (received [][]byte, elapsed uint32) {
+	should := require.New(t)
+	rnd := rand.New(rand.NewSource(1))
+	var now uint32
+	toB := &lossyPipe{rand: rnd, loss: loss, delay: 20}
+	toA := &lossyPipe{rand: rnd, loss: loss, delay: 20}
+	a := NewARQ(1, opts, func(packet []byte) { toB.send(now, packet) })
+	b := NewARQ(1, opts, func(packet []byte) { toA.send(now, packet) })
+	for _, message := range messages {
+		should.Nil(a.Send(message))
+	}
+	for ; now < 600000 && len(received) < len(messages); now++ {
+		toB.deliver(now, b)
+		toA.deliver(now, a)
+		a.Update(now)
+		b.Update(now)
+		for {
+			data, ok := b.Recv()
+			if !ok {
+				break
+			}
+			received = append(received, data)
+		}
+		should.False(a.Dead())
+	}
+	t.Logf("loss:%.2f sent:%d dropped:%d elapsed:%dms", loss, toB.sent+toA.sent, toB.dropped+toA.dropped, now)
+	return received, now
+}
+
+func testMessages() [][]byte {
+	rnd := rand.New(rand.NewSource(2))
+	messages := make([][]byte, 0, 300)
+	for i := 0; i < 300; i++ {
+		size := rnd.Intn(200)
+		if i%30 == 0 {
+			// 多个分片的消息
+			size = DefaultMTU*3 + rnd.Intn(DefaultMTU)
+		}
+		message := make([]byte, size)
+		rnd.Read(message)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestARQ_Lossy(t *testing.T) {
+	should := require.New(t)
+	messages := testMessages()
+	for _, opts := range []*Options{DefaultOptions(), FastOptions()} {
+		for _, loss := range []float64{0, 0.1, 0.3} {
+			received, _ := transfer(t, opts, loss, messages)
+			should.Equal(len(messages), len(received))
+			for i := range messages {
+				should.True(bytes.Equal(messages[i], received[i]), "message %d", i)
+			}
+		}
+	}
+}
+
+func TestARQ_FastResend(t *testing.T) {
+	should := require.New(t)
+	messages := testMessages()
+	slow := DefaultOptions()
+	slow.NoCongestion = true
+	_, slowElapsed := transfer(t, slow, 0.1, messages)
+	fast := DefaultOptions()
+	fast.NoCongestion = true
+	fast.FastResend = 2
+	_, fastElapsed := transfer(t, fast, 0.1, messages)
+	should.Less(fastElapsed, slowElapsed)
+}
+
+func TestARQ_Send(t *testing.T) {
+	should := require.New(t)
+	arq := NewARQ(1, nil, func(packet []byte) {})
+	should.ErrorIs(arq.Send(make([]byte, (DefaultMTU-overhead)*DefaultRecvWindow+1)), pnet.ErrMessageTooLarge)
+	should.Nil(arq.Send(nil))
+	should.Equal(1, arq.WaitSend())
+	// 对端无响应时达到重传上限
+	for now := uint32(0); now < 3600000 && !arq.Dead(); now += 10 {
+		arq.Update(now)
+	}
+	should.True(arq.Dead())
+}
+
+func TestARQ_SendQueue(t *testing.T) {
+	should := require.New(t)
+	opts := DefaultOptions()
+	opts.SendQueue = 4
+	arq := NewARQ(1, opts, func(packet []byte) {})
+	should.Nil(arq.Send(make([]byte, DefaultMTU)))
+	should.Equal(2, arq.Fragments([][]byte{make([]byte, DefaultMTU)}))
+	should.Nil(arq.Send(nil))
+	should.True(arq.Acceptable(1))
+	should.False(arq.Acceptable(2))
+	should.ErrorIs(arq.Send(make([]byte, DefaultMTU)), pnet.ErrWriteQueueFull)
+	should.Nil(arq.Send(nil))
+	should.ErrorIs(arq.Send(nil), pnet.ErrWriteQueueFull)
+	should.Equal(4, arq.WaitSend())
+}
+
+func TestSegment(t *testing.T) {
+	should := require.New(t)
+	var packets [][]byte
+	arq := NewARQ(7, nil, func(packet []byte) { packets = append(packets, append([]byte(nil), packet...)) })
+	should.Nil(arq.Send([]byte("hello")))
+	arq.Update(0)
+	should.Len(packets, 1)
+	conv, err := PacketConv(packets[0])
+	should.Nil(err)
+	should.Equal(uint32(7), conv)
+	should.True(IsOpening(packets[0]))
+	should.False(IsClosing(packets[0]))
+	should.True(IsClosing(FinPacket(7)))
+	_, err = PacketConv([]byte{1, 2})
+	should.ErrorIs(err, ErrInvalidPacket)
+
+	other := NewARQ(8, nil, func(packet []byte) {})
+	_, err = other.Input(packets[0])
+	should.ErrorIs(err, ErrMismatchedConv)
+	fin, err := other.Input(FinPacket(8))
+	should.Nil(err)
+	should.True(fin)
+}
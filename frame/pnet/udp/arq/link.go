@@ -0,0 +1,305 @@
+package arq
+
+import (
+	"bytes"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// epoch 时间戳起点
+var epoch = time.Now()
+
+// Now
+//
+//	@Description: 当前时间戳，单位毫秒，用于驱动 Link.Update
+//	@return uint32
+func Now() uint32 {
+	return uint32(time.Since(epoch).Milliseconds())
+}
+
+// NewLink
+//
+//	@Description: 构造可靠传输连接
+//	@param conv 会话编号
+//	@param opts 可靠传输配置
+//	@param local 本端地址
+//	@param remote 对端地址
+//	@param fromClient 是否为客户端连接，客户端以本端地址计算hash
+//	@param output 数据包输出函数，在持有连接锁时调用
+//	@return *Link
+//	@return error
+func NewLink(conv uint32, opts *Options, local, remote net.Addr, fromClient bool,
+	output func(packet []byte)) (*Link, error) {
+	link := &Link{
+		local:  local,
+		remote: remote,
+		output: output,
+	}
+	link.arq = NewARQ(conv, opts, output)
+	if err := link.BaseConn.Init(link, fromClient); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// Link
+//
+//	@Description: 基于 ARQ 的连接，实现 session.Conn
+//		发送方法是协程安全的，读取方法与 Input 仅能在同一个协程中调用
+//		关闭仅转换到关闭状态，由所属的服务或客户端在已提交的数据发出后断开
+type Link struct {
+	session.BaseConn
+
+	// 保护 arq
+	mutex  sync.Mutex
+	arq    *ARQ
+	local  net.Addr
+	remote net.Addr
+	output func(packet []byte)
+	// 已接收待解码的数据
+	inbound []byte
+	// 用户自定义上下文
+	ctx any
+}
+
+// Input
+//
+//	@Description: 输入收到的数据包，完整的消息追加到读缓冲
+//	@receiver link
+//	@param packet 数据包
+//	@return fin 对端是否已关闭
+//	@return err
+func (link *Link) Input(packet []byte) (fin bool, err error) {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	link.arq.SetCurrent(Now())
+	if fin, err = link.arq.Input(packet); err != nil {
+		return
+	}
+	link.UpdateReadTime()
+	for {
+		data, ok := link.arq.Recv()
+		if !ok {
+			break
+		}
+		link.inbound = append(link.inbound, data...)
+	}
+	return
+}
+
+// Update
+//
+//	@Description: 驱动重传与确认
+//	@receiver link
+//	@param now 当前时间戳，由 Now 获取
+//	@return dead 是否有分片的重传次数达到上限
+func (link *Link) Update(now uint32) (dead bool) {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	link.arq.Update(now)
+	return link.arq.Dead()
+}
+
+// KeepAlive
+//
+//	@Description: 告知对端接收窗口，保持连接活跃
+//	@receiver link
+func (link *Link) KeepAlive() {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	link.arq.Probe()
+	link.arq.Flush()
+}
+
+// Fin
+//
+//	@Description: 通知对端关闭连接，不保证送达
+//	@receiver link
+func (link *Link) Fin() {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	link.output(FinPacket(link.arq.Conv()))
+}
+
+// WaitSend
+//
+//	@Description: 待发送及未确认的分片数
+//	@receiver link
+//	@return int
+func (link *Link) WaitSend() int {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	return link.arq.WaitSend()
+}
+
+// Conv
+//
+//	@Description: 会话编号
+//	@receiver link
+//	@return uint32
+func (link *Link) Conv() uint32 {
+	return link.arq.Conv()
+}
+
+// send
+//
+//	@Description: 提交数据并立即刷新，每项数据作为一条消息
+//	@receiver link
+//	@param bs
+//	@return n 提交的字节数
+//	@return err 发送队列容纳不下全部数据时返回 pnet.ErrWriteQueueFull，此时不提交任何数据
+func (link *Link) send(bs [][]byte) (n int, err error) {
+	if closed, _ := link.IsClosed(); closed {
+		return 0, pnet.ErrClosedConn
+	}
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	if !link.arq.Acceptable(link.arq.Fragments(bs)) {
+		return 0, pnet.ErrWriteQueueFull
+	}
+	for _, b := range bs {
+		if err = link.arq.Send(b); err != nil {
+			return
+		}
+		n += len(b)
+	}
+	link.arq.SetCurrent(Now())
+	link.arq.Flush()
+	link.UpdateWriteTime()
+	return
+}
+
+func (link *Link) Write(b []byte) (n int, err error) {
+	return link.send([][]byte{b})
+}
+
+func (link *Link) Writev(bs [][]byte) (n int, err error) {
+	return link.send(bs)
+}
+
+func (link *Link) ReadFrom(r io.Reader) (n int64, err error) {
+	var buf bytes.Buffer
+	if n, err = buf.ReadFrom(r); err != nil {
+		return
+	}
+	_, err = link.send([][]byte{buf.Bytes()})
+	return
+}
+
+func (link *Link) Flush() error {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	link.arq.SetCurrent(Now())
+	link.arq.Flush()
+	return nil
+}
+
+func (link *Link) OutboundBuffered() int {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	return link.arq.WaitSendBytes()
+}
+
+// AsyncWrite
+//
+//	@Description: 提交数据，回调在提交后同步执行
+//	@receiver link
+//	@param buf
+//	@param callback
+//	@return error
+func (link *Link) AsyncWrite(buf []byte, callback func(c session.Conn, err error) error) error {
+	return link.AsyncWritev([][]byte{buf}, callback)
+}
+
+func (link *Link) AsyncWritev(bs [][]byte, callback func(c session.Conn, err error) error) error {
+	if _, err := link.send(bs); err != nil {
+		return err
+	}
+	if callback != nil {
+		_ = callback(link, nil)
+	}
+	return nil
+}
+
+func (link *Link) Read(b []byte) (n int, err error) {
+	if len(link.inbound) <= 0 {
+		return 0, io.EOF
+	}
+	n = copy(b, link.inbound)
+	link.inbound = link.inbound[n:]
+	return
+}
+
+func (link *Link) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := w.Write(link.inbound)
+	link.inbound = link.inbound[m:]
+	return int64(m), err
+}
+
+func (link *Link) Next(n int) (buf []byte, err error) {
+	if buf, err = link.Peek(n); err != nil {
+		return
+	}
+	link.inbound = link.inbound[len(buf):]
+	return
+}
+
+func (link *Link) Peek(n int) (buf []byte, err error) {
+	if n > len(link.inbound) {
+		return nil, io.ErrShortBuffer
+	} else if n <= 0 {
+		n = len(link.inbound)
+	}
+	return link.inbound[:n], nil
+}
+
+func (link *Link) Discard(n int) (discarded int, err error) {
+	buf, err := link.Next(n)
+	return len(buf), err
+}
+
+func (link *Link) InboundBuffered() int {
+	return len(link.inbound)
+}
+
+func (link *Link) Context() any {
+	return link.ctx
+}
+
+func (link *Link) SetContext(ctx any) {
+	link.ctx = ctx
+}
+
+func (link *Link) LocalAddr() net.Addr {
+	return link.local
+}
+
+func (link *Link) RemoteAddr() net.Addr {
+	return link.remote
+}
+
+// Close
+//
+//	@Description: 转换到关闭状态，不再接受新的数据，已提交的数据发出后断开
+//	@receiver link
+//	@return error
+func (link *Link) Close() error {
+	link.ToClosed(nil)
+	return nil
+}
+
+func (link *Link) SetDeadline(_ time.Time) error {
+	return nil
+}
+
+func (link *Link) SetReadDeadline(_ time.Time) error {
+	return nil
+}
+
+func (link *Link) SetWriteDeadline(_ time.Time) error {
+	return nil
+}
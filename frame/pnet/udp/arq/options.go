@@ -0,0 +1,118 @@
+package arq
+
+import "time"
+
+const (
+	DefaultMTU        = 1400
+	DefaultSendWindow = 32
+	DefaultRecvWindow = 128
+	// 发送队列上限，单位为分片数
+	DefaultSendQueue = 1024
+	DefaultInterval  = 10 * time.Millisecond
+	DefaultDeadLink  = 20
+	DefaultFastLimit = 5
+	// 普通模式的最小重传超时
+	DefaultMinRTO = 100 * time.Millisecond
+	// 无延迟模式的最小重传超时
+	NoDelayMinRTO = 30 * time.Millisecond
+)
+
+// DefaultOptions
+//
+//	@Description: 普通模式配置，开启拥塞控制，不快速重传
+//	@return *Options
+func DefaultOptions() *Options {
+	return &Options{
+		MTU:        DefaultMTU,
+		SendWindow: DefaultSendWindow,
+		RecvWindow: DefaultRecvWindow,
+		SendQueue:  DefaultSendQueue,
+		Interval:   DefaultInterval,
+		DeadLink:   DefaultDeadLink,
+		FastLimit:  DefaultFastLimit,
+	}
+}
+
+// FastOptions
+//
+//	@Description: 低延迟模式配置，无延迟、收到2次跳跃确认即快速重传并关闭拥塞控制
+//	@return *Options
+func FastOptions() *Options {
+	opts := DefaultOptions()
+	opts.NoDelay = true
+	opts.FastResend = 2
+	opts.NoCongestion = true
+	opts.SendWindow = DefaultRecvWindow
+	return opts
+}
+
+// Options
+//
+//	@Description: 可靠传输配置
+type Options struct {
+	// 数据包最大长度，包含协议头
+	MTU int
+	// 发送窗口，单位为分片数
+	SendWindow int
+	// 接收窗口，单位为分片数，单条消息的分片数不能超过接收窗口
+	RecvWindow int
+	// 发送队列上限，单位为分片数，包含待发送及未确认的分片，超出时拒绝提交
+	SendQueue int
+	// 刷新间隔，重传与确认在刷新时发出
+	Interval time.Duration
+	// 是否为无延迟模式，重传超时下限更低且超时后退避更慢
+	NoDelay bool
+	// 收到多少次跳跃确认后快速重传，为0时不快速重传
+	FastResend int
+	// 单个分片快速重传的次数上限，为0时不限制
+	FastLimit int
+	// 是否关闭拥塞控制，关闭后仅受发送窗口与对端接收窗口限制
+	NoCongestion bool
+	// 单个分片重传多少次后认为连接已断开
+	DeadLink int
+	// 最小重传超时，为0时按是否无延迟模式取默认值
+	MinRTO time.Duration
+}
+
+// complete
+//
+//	@Description: 补全未设置的配置
+//	@receiver opts
+func (opts *Options) complete() {
+	if opts.MTU <= overhead {
+		opts.MTU = DefaultMTU
+	}
+	if opts.SendWindow <= 0 {
+		opts.SendWindow = DefaultSendWindow
+	}
+	if opts.RecvWindow <= 0 {
+		opts.RecvWindow = DefaultRecvWindow
+	}
+	if opts.SendQueue <= 0 {
+		opts.SendQueue = DefaultSendQueue
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.DeadLink <= 0 {
+		opts.DeadLink = DefaultDeadLink
+	}
+	if opts.MinRTO <= 0 {
+		opts.MinRTO = DefaultMinRTO
+		if opts.NoDelay {
+			opts.MinRTO = NoDelayMinRTO
+		}
+	}
+}
+
+// UpdateInterval
+//
+//	@Description: 驱动 Update 的间隔，未设置刷新间隔时为默认值
+//	@receiver opts
+//	@return time.Duration
+func (opts *Options) UpdateInterval() time.Duration {
+	if opts == nil || opts.Interval <= 0 {
+		return DefaultInterval
+	}
+	return opts.Interval
+}
@@ -0,0 +1,178 @@
+package arq
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrInvalidPacket  = errors.New("invalid arq packet")
+	ErrMismatchedConv = errors.New("mismatched arq conversation")
+	ErrDeadLink       = errors.New("arq link is dead")
+	ErrPeerClosed     = errors.New("closed by peer")
+)
+
+const (
+	// cmdPush 数据分片
+	cmdPush uint8 = 81
+	// cmdAck 确认
+	cmdAck uint8 = 82
+	// cmdWindowAsk 询问对端接收窗口
+	cmdWindowAsk uint8 = 83
+	// cmdWindowTell 告知接收窗口
+	cmdWindowTell uint8 = 84
+	// cmdFin 关闭连接
+	cmdFin uint8 = 85
+)
+
+// overhead 分片头长度
+//
+// * 0               4       5       6               8
+// * +---------------+-------+-------+---------------+
+// * |     conv      |  cmd  |  frg  |      wnd      |
+// * +---------------+-------+-------+---------------+
+// * |      ts       |      sn       |      una      |
+// * +---------------+---------------+---------------+
+// * |      len      |            data ...           |
+// * +---------------+-------------------------------+
+const overhead = 24
+
+// segment
+//
+//	@Description: 分片
+type segment struct {
+	conv uint32
+	cmd  uint8
+	// 消息剩余的分片数，最后一个分片为0
+	frg uint8
+	// 发送方的剩余接收窗口
+	wnd uint16
+	// 发送时间
+	ts uint32
+	// 序号
+	sn uint32
+	// 发送方待接收的下一个序号
+	una  uint32
+	data []byte
+
+	// 以下仅发送缓冲中使用
+	// 下次重传时间
+	resendTs uint32
+	// 重传超时
+	rto uint32
+	// 被跳跃确认的次数
+	fastAck uint32
+	// 发送次数
+	xmit uint32
+}
+
+// encode
+//
+//	@Description: 编码分片头及数据
+//	@receiver seg
+//	@param buf 输出，长度需足够
+//	@return []byte 剩余的输出
+func (seg *segment) encode(buf []byte) []byte {
+	binary.LittleEndian.PutUint32(buf, seg.conv)
+	buf[4] = seg.cmd
+	buf[5] = seg.frg
+	binary.LittleEndian.PutUint16(buf[6:], seg.wnd)
+	binary.LittleEndian.PutUint32(buf[8:], seg.ts)
+	binary.LittleEndian.PutUint32(buf[12:], seg.sn)
+	binary.LittleEndian.PutUint32(buf[16:], seg.una)
+	binary.LittleEndian.PutUint32(buf[20:], uint32(len(seg.data)))
+	n := copy(buf[overhead:], seg.data)
+	return buf[overhead+n:]
+}
+
+// decode
+//
+//	@Description: 解码分片，数据引用输入
+//	@receiver seg
+//	@param buf 输入
+//	@return []byte 剩余的输入
+//	@return error
+func (seg *segment) decode(buf []byte) ([]byte, error) {
+	if len(buf) < overhead {
+		return nil, ErrInvalidPacket
+	}
+	seg.conv = binary.LittleEndian.Uint32(buf)
+	seg.cmd = buf[4]
+	seg.frg = buf[5]
+	seg.wnd = binary.LittleEndian.Uint16(buf[6:])
+	seg.ts = binary.LittleEndian.Uint32(buf[8:])
+	seg.sn = binary.LittleEndian.Uint32(buf[12:])
+	seg.una = binary.LittleEndian.Uint32(buf[16:])
+	length := binary.LittleEndian.Uint32(buf[20:])
+	buf = buf[overhead:]
+	if uint32(len(buf)) < length {
+		return nil, ErrInvalidPacket
+	}
+	switch seg.cmd {
+	case cmdPush, cmdAck, cmdWindowAsk, cmdWindowTell, cmdFin:
+	default:
+		return nil, ErrInvalidPacket
+	}
+	seg.data = buf[:length]
+	return buf[length:], nil
+}
+
+// PacketConv
+//
+//	@Description: 获取数据包的会话编号
+//	@param packet 数据包
+//	@return uint32
+//	@return error
+func PacketConv(packet []byte) (uint32, error) {
+	if len(packet) < overhead {
+		return 0, ErrInvalidPacket
+	}
+	return binary.LittleEndian.Uint32(packet), nil
+}
+
+// IsOpening
+//
+//	@Description: 数据包是否为会话的首个数据分片，服务端仅以此创建新会话
+//	@param packet 数据包
+//	@return bool
+func IsOpening(packet []byte) bool {
+	for len(packet) > 0 {
+		var seg segment
+		var err error
+		if packet, err = seg.decode(packet); err != nil {
+			return false
+		}
+		if seg.cmd == cmdPush && seg.sn == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FinPacket
+//
+//	@Description: 构造关闭连接的数据包
+//	@param conv 会话编号
+//	@return []byte
+func FinPacket(conv uint32) []byte {
+	buf := make([]byte, overhead)
+	seg := segment{conv: conv, cmd: cmdFin}
+	seg.encode(buf)
+	return buf
+}
+
+// timeDiff
+//
+//	@Description: 时间戳差值，处理回绕
+func timeDiff(later, earlier uint32) int32 {
+	return int32(later - earlier)
+}
+
+// IsClosing
+//
+//	@Description: 数据包是否为关闭连接的通知
+//	@param packet 数据包
+//	@return bool
+func IsClosing(packet []byte) bool {
+	return len(packet) >= overhead && packet[4] == cmdFin
+}
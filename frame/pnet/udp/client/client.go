@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"github.com/meow-pad/persian/frame/pnet/utils"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	StatusInitial = iota
+	StatusConnecting
+	StatusConnected
+	StatusClosed
+)
+
+var (
+	ErrInvalidStatus = errors.New("invalid client status")
+)
+
+func NewClient(codec codec.Codec, listener session.Listener, opts ...Option) (*Client, error) {
+	if codec == nil || listener == nil {
+		return nil, errdef.ErrInvalidParams
+	}
+	client := &Client{
+		Options:  newOptions(opts...),
+		codec:    codec,
+		listener: listener,
+	}
+	client.status.Store(StatusInitial)
+	return client, nil
+}
+
+type Client struct {
+	*Options
+	session.BaseSession
+
+	// 状态
+	status atomic.Uint32
+	// 编解码器
+	codec codec.Codec
+	// 会话监听器
+	listener session.Listener
+	// socket，仅连接成功后有值
+	conn *net.UDPConn
+	// 连接，仅连接成功后有值
+	linkPT atomic.Pointer[arq.Link]
+	// 已读取待处理的数据包
+	packets chan []byte
+	// 事件循环结束通知
+	doneChan chan struct{}
+}
+
+// Dial
+//
+//	@Description: 连接，以随机的会话编号发出首个数据分片，服务端收到后开启会话
+//	@receiver client
+//	@param ctx
+//	@param address 如：127.0.0.1:9999 或 udp://127.0.0.1:9999
+//	@return error
+func (client *Client) Dial(ctx context.Context, address string) error {
+	if !client.status.CompareAndSwap(StatusInitial, StatusConnecting) {
+		return ErrInvalidStatus
+	}
+	network, addr, err := utils.SplitAddress(address, utils.ProtoUDP)
+	if err != nil {
+		client.status.Store(StatusInitial)
+		return err
+	}
+	var dialer net.Dialer
+	pConn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		client.status.Store(StatusInitial)
+		return err
+	}
+	conn := pConn.(*net.UDPConn)
+	if err = conn.SetReadBuffer(client.SocketRecvBuffer); err != nil {
+		plog.Warn("set socket recv buffer error:", pfield.Error(err))
+	}
+	if err = conn.SetWriteBuffer(client.SocketSendBuffer); err != nil {
+		plog.Warn("set socket send buffer error:", pfield.Error(err))
+	}
+	conv, err := newConv()
+	if err != nil {
+		client.status.Store(StatusInitial)
+		if cErr := conn.Close(); cErr != nil {
+			plog.Error("", pfield.Error(cErr))
+		}
+		return err
+	}
+	link, err := arq.NewLink(conv, client.ARQ, conn.LocalAddr(), conn.RemoteAddr(), true,
+		func(packet []byte) {
+			if _, wErr := conn.Write(packet); wErr != nil {
+				plog.Debug("write packet error:", pfield.Error(wErr))
+			}
+		})
+	if err != nil {
+		client.status.Store(StatusInitial)
+		if cErr := conn.Close(); cErr != nil {
+			plog.Error("", pfield.Error(cErr))
+		}
+		return err
+	}
+	link.SetContext(client)
+	client.conn = conn
+	client.packets = make(chan []byte, client.PacketQueueCap)
+	client.doneChan = make(chan struct{})
+	client.linkPT.Store(link)
+	client.status.Store(StatusConnected)
+	loop := newEventLoop(client, link)
+	go client.read(link)
+	go loop.run()
+	// 空消息仅用于开启服务端会话
+	_, err = link.Write(nil)
+	return err
+}
+
+// newConv
+//
+//	@Description: 生成非0的随机会话编号，使用加密随机数以免被对端猜测
+//	@return uint32
+//	@return error
+func newConv() (uint32, error) {
+	buf := make([]byte, 4)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return 0, err
+		}
+		if conv := binary.BigEndian.Uint32(buf); conv != 0 {
+			return conv, nil
+		}
+	}
+}
+
+// read
+//
+//	@Description: 读取数据包并投递到事件循环，socket关闭后退出
+//	@receiver client
+//	@param link
+func (client *Client) read(link *arq.Link) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := client.conn.Read(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				// 如服务端不可达
+				link.ToClosed(session.NewCloseReason(session.CloseNetwork, session.CloseByPeer, err))
+			}
+			return
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if filter := client.PacketFilter; filter != nil && !filter(data) {
+			continue
+		}
+		select {
+		case client.packets <- data:
+		default:
+			plog.Debug("packet queue is full", pfield.String("client", client.Name))
+		}
+	}
+}
+
+func (client *Client) Status() uint32 {
+	return client.status.Load()
+}
+
+func (client *Client) Connection() session.Conn {
+	if link := client.linkPT.Load(); link != nil {
+		return link
+	}
+	return nil
+}
+
+// Close
+//
+//	@Description: 关闭连接，已提交的数据发出后断开，断开后通知监听器
+//	@receiver client
+//	@return error
+func (client *Client) Close() error {
+	if !client.toClosed() {
+		return pnet.ErrClosedClient
+	}
+	client.linkPT.Load().ToClosed(pnet.ErrClientActiveClose)
+	return nil
+}
+
+// Done
+//
+//	@Description: 连接断开并通知监听器后关闭的通道，连接前为nil
+//	@receiver client
+//	@return <-chan struct{}
+func (client *Client) Done() <-chan struct{} {
+	return client.doneChan
+}
+
+func (client *Client) toClosed() bool {
+	return client.status.CompareAndSwap(StatusConnected, StatusClosed)
+}
+
+func (client *Client) IsClosed() bool {
+	return client.status.Load() != StatusConnected
+}
+
+// SendMessage
+//
+//	@Description: 发送消息
+//	@receiver client
+//	@param message
+func (client *Client) SendMessage(message any) {
+	if client.status.Load() != StatusConnected {
+		plog.Error("connect first")
+		return
+	}
+	buf, err := client.codec.Encode(message)
+	if err != nil {
+		plog.Error("encode message error:", pfield.Error(err))
+		return
+	}
+	bufLen := len(buf)
+	err = client.linkPT.Load().AsyncWrite(buf, func(c session.Conn, err error) error {
+		if err = client.listener.OnSend(client, message, bufLen); err != nil {
+			plog.Error("on send error:", pfield.Error(err))
+		}
+		return nil
+	})
+	if err != nil {
+		client.onSendingError("async write error:", err)
+	}
+}
+
+// SendMessages
+//
+//	@Description: 发送多条消息
+//	@receiver client
+//	@param messages
+func (client *Client) SendMessages(messages ...any) {
+	if client.status.Load() != StatusConnected {
+		plog.Error("connect first")
+		return
+	}
+	totalLen := 0
+	dataArr := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		data, err := client.codec.Encode(message)
+		if err != nil {
+			client.onSendingError("encode message error:", err)
+			return
+		}
+		dataArr = append(dataArr, data)
+		totalLen += len(data)
+	}
+	err := client.linkPT.Load().AsyncWritev(dataArr, func(c session.Conn, err error) error {
+		if err = client.listener.OnSendMulti(client, messages, totalLen); err != nil {
+			plog.Error("on send multi error:", pfield.Error(err))
+		}
+		return nil
+	})
+	if err != nil {
+		client.onSendingError("async writev error:", err)
+	}
+}
+
+// onSendingError
+//
+//	@Description: 发送消息时错误处理
+//	@receiver client
+//	@param tip 日志消息
+//	@param err 错误
+func (client *Client) onSendingError(tip string, err error) {
+	plog.Error(tip, pfield.Error(err))
+	if errors.Is(err, pnet.ErrWriteQueueFull) {
+		// 发送队列已满时仅丢弃本次消息
+		return
+	}
+	// 无法处理的状态，关闭连接
+	if client.toClosed() {
+		client.linkPT.Load().ToClosed(session.NewCloseReason(session.CloseNetwork, session.CloseByLocal, err))
+	}
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"time"
+)
+
+func newEventLoop(client *Client, link *arq.Link) *eventLoop {
+	return &eventLoop{client: client, link: link}
+}
+
+// eventLoop
+//
+//	@Description: 客户端的事件循环，串行处理数据包与重传，监听器回调均在其中执行
+type eventLoop struct {
+	client *Client
+	link   *arq.Link
+	// 关闭后等待数据发出的截止时间
+	lingerDeadline time.Time
+	// 最后一次发送保活探测的时间
+	lastKeepAlive time.Time
+}
+
+func (loop *eventLoop) run() {
+	client := loop.client
+	ticker := time.NewTicker(client.ARQ.UpdateInterval())
+	defer ticker.Stop()
+	client.listener.OnOpened(client)
+	for {
+		select {
+		case data := <-client.packets:
+			if loop.onPacket(data) {
+				return
+			}
+		case now := <-ticker.C:
+			if loop.onTick(now) {
+				return
+			}
+		}
+	}
+}
+
+// onPacket
+//
+//	@Description: 输入数据包并解码收到的消息
+//	@receiver loop
+//	@param data
+//	@return finished 是否已断开
+func (loop *eventLoop) onPacket(data []byte) (finished bool) {
+	client, link := loop.client, loop.link
+	closed, _ := link.IsClosed()
+	fin, err := link.Input(data)
+	if err != nil {
+		plog.Error("input packet error", pfield.Error(err))
+		link.ToClosed(session.NewCloseReason(session.CloseProtocol, session.CloseByLocal, err))
+		loop.finish(true)
+		return true
+	}
+	if fin {
+		link.ToClosed(session.NewCloseReason(session.CloseNormal, session.CloseByPeer, arq.ErrPeerClosed))
+		loop.finish(false)
+		return true
+	}
+	if closed || link.InboundBuffered() <= 0 {
+		return false
+	}
+	msgArr, totalLen, err := client.codec.Decode(link)
+	if err != nil {
+		plog.Error("decode error", pfield.Error(err))
+		client.toClosed()
+		link.ToClosed(session.NewCloseReason(session.CloseProtocol, session.CloseByLocal, err))
+		return false
+	}
+	msgNum := len(msgArr)
+	if msgNum > 1 {
+		_ = client.listener.OnReceiveMulti(client, msgArr, totalLen)
+	} else if msgNum == 1 {
+		_ = client.listener.OnReceive(client, msgArr[0], totalLen)
+	}
+	return false
+}
+
+// onTick
+//
+//	@Description: 驱动重传、保活与空闲检测，已关闭且数据已发出时断开
+//	@receiver loop
+//	@param now
+//	@return finished 是否已断开
+func (loop *eventLoop) onTick(now time.Time) (finished bool) {
+	client, link := loop.client, loop.link
+	if link.Update(arq.Now()) {
+		link.ToClosed(session.NewCloseReason(session.CloseNetwork, session.CloseByPeer, arq.ErrDeadLink))
+		loop.finish(false)
+		return true
+	}
+	if closed, _ := link.IsClosed(); closed {
+		client.toClosed()
+		if loop.lingerDeadline.IsZero() {
+			loop.lingerDeadline = now.Add(client.Linger)
+		}
+		if link.WaitSend() <= 0 || now.After(loop.lingerDeadline) {
+			loop.finish(true)
+			return true
+		}
+		return false
+	}
+	if heartbeat := client.Heartbeat; heartbeat != nil {
+		switch link.CheckIdle(heartbeat, now) {
+		case session.IdleHeartbeat:
+			if heartbeat.Message != nil {
+				client.SendMessage(heartbeat.Message())
+			}
+		case session.IdleClose:
+			plog.Debug("close idle client", pfield.String("client", client.Name))
+			client.toClosed()
+			link.ToClosed(pnet.ErrIdleTimeout)
+			return false
+		}
+	}
+	if keepAlive := client.KeepAlive; keepAlive > 0 &&
+		now.Sub(link.LastWriteTime()) >= keepAlive && now.Sub(loop.lastKeepAlive) >= keepAlive {
+		loop.lastKeepAlive = now
+		link.KeepAlive()
+	}
+	return false
+}
+
+// finish
+//
+//	@Description: 关闭socket并通知关闭
+//	@receiver loop
+//	@param notify 是否通知对端关闭
+func (loop *eventLoop) finish(notify bool) {
+	client, link := loop.client, loop.link
+	if notify {
+		link.Fin()
+	}
+	client.toClosed()
+	if err := client.conn.Close(); err != nil {
+		plog.Error("close socket error:", pfield.Error(err))
+	}
+	_, err := link.IsClosed()
+	plog.Debug("close client", pfield.String("client", client.Name), pfield.NamedError("reason", err))
+	client.listener.OnClosed(client, session.ResolveCloseReason(err))
+	client.Attributes().Clear()
+	close(client.doneChan)
+}
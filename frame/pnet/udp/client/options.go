@@ -0,0 +1,119 @@
+package client
+
+import (
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"time"
+)
+
+func newOptions(opts ...Option) *Options {
+	options := &Options{
+		ARQ:              arq.FastOptions(),
+		Linger:           time.Second,
+		KeepAlive:        10 * time.Second,
+		PacketQueueCap:   256,
+		SocketRecvBuffer: 256 * 1024,
+		SocketSendBuffer: 256 * 1024,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+type Options struct {
+	Name string
+	// 可靠传输配置，需与服务端一致
+	ARQ *arq.Options
+	// 关闭时等待已提交数据被确认的最长时间
+	Linger time.Duration
+	// 未发送数据多久后发送保活探测，为0时不发送
+	KeepAlive time.Duration
+	// 已读取待处理的数据包队列上限，队列满时丢弃新的数据包
+	PacketQueueCap int
+	// socket读缓冲区
+	SocketRecvBuffer int
+	// socket写缓冲区
+	SocketSendBuffer int
+	// 入站数据包过滤，返回false时丢弃，可用于模拟丢包
+	PacketFilter func(packet []byte) bool
+	// 心跳与空闲检测配置，为nil时不检测
+	Heartbeat *session.HeartbeatOptions
+}
+
+type Option func(*Options)
+
+func WithName(value string) Option {
+	return func(options *Options) {
+		options.Name = value
+	}
+}
+
+// WithARQ
+//
+//	@Description: 设置可靠传输配置，默认为 arq.FastOptions
+//	@param value
+//	@return Option
+func WithARQ(value *arq.Options) Option {
+	return func(options *Options) {
+		options.ARQ = value
+	}
+}
+
+func WithLinger(value time.Duration) Option {
+	return func(options *Options) {
+		options.Linger = value
+	}
+}
+
+func WithKeepAlive(value time.Duration) Option {
+	return func(options *Options) {
+		options.KeepAlive = value
+	}
+}
+
+func WithPacketQueueCap(value int) Option {
+	return func(options *Options) {
+		options.PacketQueueCap = value
+	}
+}
+
+func WithSocketRecvBuffer(cap int) Option {
+	return func(options *Options) {
+		options.SocketRecvBuffer = cap
+	}
+}
+
+func WithSocketSendBuffer(cap int) Option {
+	return func(options *Options) {
+		options.SocketSendBuffer = cap
+	}
+}
+
+// WithPacketFilter
+//
+//	@Description: 设置入站数据包过滤
+//	@param filter 返回false时丢弃数据包
+//	@return Option
+func WithPacketFilter(filter func(packet []byte) bool) Option {
+	return func(options *Options) {
+		options.PacketFilter = filter
+	}
+}
+
+// WithHeartbeat
+//
+//	@Description: 开启心跳与空闲检测
+//	@param interval 未收到数据多久后发送心跳
+//	@param maxMissed 连续未响应的心跳次数上限，小于1时按1处理
+//	@param message 心跳消息构造函数，为nil时仅做空闲检测
+//	@return Option
+func WithHeartbeat(interval time.Duration, maxMissed int, message func() any) Option {
+	return func(options *Options) {
+		options.Heartbeat = &session.HeartbeatOptions{
+			Interval:  interval,
+			MaxMissed: maxMissed,
+			Message:   message,
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"net"
+	"time"
+)
+
+func newEventLoop(server *Server) *eventLoop {
+	return &eventLoop{server: server}
+}
+
+// eventLoop
+//
+//	@Description: 服务的事件循环，串行处理数据包、重传与会话检查，监听器回调均在其中执行
+type eventLoop struct {
+	server *Server
+	// 下次检查会话有效性的时间
+	nextCheckTime time.Time
+	// 下次检查空闲会话的时间
+	nextIdleTime time.Time
+}
+
+func (loop *eventLoop) run() {
+	server := loop.server
+	ticker := time.NewTicker(server.options.ARQ.UpdateInterval())
+	defer ticker.Stop()
+	stopChan := server.stopChan
+	for {
+		select {
+		case pkt := <-server.packets:
+			loop.onPacket(pkt)
+		case now := <-ticker.C:
+			loop.onTick(now)
+			if server.stopping.Load() && len(server.sessions) <= 0 {
+				loop.shutdown()
+				return
+			}
+		case <-stopChan:
+			stopChan = nil
+			for _, sess := range server.sessions {
+				if err := sess.closeWithReason(pnet.ErrServerStopped); err != nil {
+					plog.Error("close session error:", pfield.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// shutdown
+//
+//	@Description: 所有会话结束后关闭socket
+//	@receiver loop
+func (loop *eventLoop) shutdown() {
+	server := loop.server
+	if err := server.conn.Close(); err != nil {
+		plog.Debug("close socket error:", pfield.String("server", server.name), pfield.Error(err))
+	}
+	close(server.doneChan)
+}
+
+// onPacket
+//
+//	@Description: 按地址与会话编号投递数据包，会话的首个数据分片创建新会话
+//	@receiver loop
+//	@param pkt
+func (loop *eventLoop) onPacket(pkt packet) {
+	server := loop.server
+	conv, err := arq.PacketConv(pkt.data)
+	if err != nil {
+		server.invalidPackets.Add(1)
+		return
+	}
+	key := linkKey{addr: pkt.addr.String(), conv: conv}
+	sess, ok := server.sessions[key]
+	if !ok {
+		if arq.IsClosing(pkt.data) {
+			return
+		}
+		if !arq.IsOpening(pkt.data) || server.stopping.Load() {
+			// 未知的会话，通知对端关闭
+			server.writeTo(arq.FinPacket(conv), pkt.addr)
+			return
+		}
+		counter := server.connCounter
+		if counter != nil {
+			if event := counter.Acquire(pkt.addr); event != nil {
+				// 超出会话数限制
+				session.RejectConn(server.name, server.listener, event)
+				server.writeTo(arq.FinPacket(conv), pkt.addr)
+				return
+			}
+		}
+		if sess, err = loop.open(key, pkt.addr); err != nil {
+			plog.Error("open session error:", pfield.String("server", server.name), pfield.Error(err))
+			if counter != nil {
+				counter.Release(pkt.addr)
+			}
+			server.writeTo(arq.FinPacket(conv), pkt.addr)
+			return
+		}
+	}
+	loop.onTraffic(sess, pkt.data)
+}
+
+// open
+//
+//	@Description: 创建会话并通知开启
+//	@receiver loop
+//	@param key 会话索引
+//	@param addr 对端地址
+//	@return *svrSession
+//	@return error
+func (loop *eventLoop) open(key linkKey, addr *net.UDPAddr) (*svrSession, error) {
+	server := loop.server
+	link, err := arq.NewLink(key.conv, server.options.ARQ, server.conn.LocalAddr(), addr, false,
+		func(packet []byte) {
+			server.writeTo(packet, addr)
+		})
+	if err != nil {
+		return nil, err
+	}
+	sess := newSession(server, link, key)
+	if err = server.AddSession(sess); err != nil {
+		return nil, err
+	}
+	sess.counted = server.connCounter != nil
+	server.sessions[key] = sess
+	plog.Debug("open connecting:",
+		pfield.String("server", server.name),
+		pfield.Uint64("conn", link.Hash()))
+	server.listener.OnOpened(sess)
+	return sess, nil
+}
+
+// onTraffic
+//
+//	@Description: 输入数据包并解码收到的消息，已关闭的会话仅处理确认
+//	@receiver loop
+//	@param sess
+//	@param data
+func (loop *eventLoop) onTraffic(sess *svrSession, data []byte) {
+	server := loop.server
+	closed, _ := sess.conn.IsClosed()
+	fin, err := sess.conn.Input(data)
+	if err != nil {
+		// 地址可被伪造，无效的数据包仅丢弃，不关闭会话
+		plog.Debug("input packet error", pfield.String("server", server.name), pfield.Error(err))
+		server.invalidPackets.Add(1)
+		return
+	}
+	if fin {
+		sess.conn.ToClosed(session.NewCloseReason(session.CloseNormal, session.CloseByPeer, arq.ErrPeerClosed))
+		loop.finish(sess, false)
+		return
+	}
+	if closed || sess.conn.InboundBuffered() <= 0 {
+		return
+	}
+	msgArr, totalLen, err := server.codec.Decode(sess.conn)
+	if err != nil {
+		plog.Error("decode error", pfield.Error(err))
+		if cErr := sess.closeWithReason(
+			session.NewCloseReason(session.CloseProtocol, session.CloseByLocal, err)); cErr != nil {
+			plog.Error("close session error:", pfield.Error(cErr))
+		}
+		return
+	}
+	if dispatcher := server.options.Dispatcher; dispatcher != nil {
+		_ = dispatcher.Dispatch(sess, server.listener, msgArr, totalLen)
+		return
+	}
+	msgNum := len(msgArr)
+	if msgNum > 1 {
+		_ = server.listener.OnReceiveMulti(sess, msgArr, totalLen)
+	} else if msgNum == 1 {
+		_ = server.listener.OnReceive(sess, msgArr[0], totalLen)
+	}
+}
+
+// onTick
+//
+//	@Description: 驱动重传，结束已关闭且数据已发出的会话，并定时检查会话
+//	@receiver loop
+//	@param now
+func (loop *eventLoop) onTick(now time.Time) {
+	server := loop.server
+	ts := arq.Now()
+	for _, sess := range server.sessions {
+		if sess.conn.Update(ts) {
+			sess.conn.ToClosed(session.NewCloseReason(session.CloseNetwork, session.CloseByPeer, arq.ErrDeadLink))
+			loop.finish(sess, false)
+			continue
+		}
+		if closed, _ := sess.conn.IsClosed(); !closed {
+			continue
+		}
+		if sess.lingerDeadline.IsZero() {
+			sess.lingerDeadline = now.Add(server.options.Linger)
+		}
+		if sess.conn.WaitSend() <= 0 || now.After(sess.lingerDeadline) {
+			loop.finish(sess, true)
+		}
+	}
+	options := server.options
+	if options.Heartbeat != nil && !now.Before(loop.nextIdleTime) {
+		loop.checkIdleSessions(now)
+		loop.nextIdleTime = now.Add(options.Heartbeat.TickInterval())
+	}
+	if !now.Before(loop.nextCheckTime) {
+		server.CheckSessions()
+		loop.nextCheckTime = now.Add(options.CheckSessionInterval)
+	}
+}
+
+// checkIdleSessions
+//
+//	@Description: 检查空闲会话，发送心跳或关闭超时会话
+//	@receiver loop
+//	@param now
+func (loop *eventLoop) checkIdleSessions(now time.Time) {
+	server := loop.server
+	heartbeat := server.options.Heartbeat
+	for _, sess := range server.sessions {
+		if sess.IsClosed() {
+			continue
+		}
+		switch sess.conn.CheckIdle(heartbeat, now) {
+		case session.IdleHeartbeat:
+			if heartbeat.Message != nil {
+				sess.SendMessage(heartbeat.Message())
+			}
+		case session.IdleClose:
+			plog.Debug("close idle session:",
+				pfield.String("server", server.name),
+				pfield.Uint64("conn", sess.conn.Hash()))
+			if err := sess.closeWithReason(pnet.ErrIdleTimeout); err != nil {
+				plog.Error("close idle session error:", pfield.Error(err))
+			}
+		}
+	}
+}
+
+// finish
+//
+//	@Description: 移除会话并通知关闭
+//	@receiver loop
+//	@param sess 已转换到关闭状态的会话
+//	@param notify 是否通知对端关闭
+func (loop *eventLoop) finish(sess *svrSession, notify bool) {
+	server := loop.server
+	if notify {
+		sess.conn.Fin()
+	}
+	delete(server.sessions, sess.key)
+	server.RemoveSession(sess)
+	if sess.counted {
+		server.connCounter.Release(sess.conn.RemoteAddr())
+	}
+	plog.Debug("close udp-server connecting:",
+		pfield.String("server", server.name),
+		pfield.Uint64("conn", sess.conn.Hash()))
+	_, err := sess.conn.IsClosed()
+	if dispatcher := server.options.Dispatcher; dispatcher != nil {
+		// 与已提交的消息在同一队列中执行
+		dispatcher.DispatchClosed(sess, server.listener, session.ResolveCloseReason(err))
+		return
+	}
+	server.listener.OnClosed(sess, session.ResolveCloseReason(err))
+	sess.Attributes().Clear()
+}
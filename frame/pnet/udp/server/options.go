@@ -0,0 +1,180 @@
+package server
+
+import (
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"time"
+)
+
+// NewOptions
+//
+//	@Description: 创建 Options
+//	@param opts
+//	@return Options
+func NewOptions(opts ...Option) (*Options, error) {
+	options := &Options{
+		ARQ:                   arq.FastOptions(),
+		UnregisterSessionLife: 20,
+		CheckSessionInterval:  30 * time.Second,
+		Linger:                time.Second,
+		PacketQueueCap:        1024,
+		SocketRecvBuffer:      1024 * 1024,
+		SocketSendBuffer:      1024 * 1024,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options, nil
+}
+
+type Options struct {
+	// 可靠传输配置
+	ARQ *arq.Options
+	// 未注册session的存活时间，单位秒
+	UnregisterSessionLife int64
+	// 检查session间隔
+	CheckSessionInterval time.Duration
+	// 关闭会话时等待已提交数据被确认的最长时间
+	Linger time.Duration
+	// 已读取待处理的数据包队列上限，队列满时丢弃新的数据包
+	PacketQueueCap int
+	// socket读缓冲区
+	SocketRecvBuffer int
+	// socket写缓冲区
+	SocketSendBuffer int
+	// 入站数据包过滤，返回false时丢弃，可用于模拟丢包
+	PacketFilter func(packet []byte) bool
+	// 消息分发器，设置后监听器的接收回调将在工作池中执行
+	Dispatcher *session.Dispatcher
+	// 心跳与空闲检测配置，为nil时不检测
+	Heartbeat *session.HeartbeatOptions
+	// 会话恢复配置，为nil时不支持恢复
+	Resume *session.ResumeOptions
+	// 共享的会话管理器，为nil时服务单独创建
+	Manager *session.Manager
+	// 会话数限制，为nil时不限制
+	Limit *session.LimitOptions
+}
+
+type Option func(options *Options)
+
+// WithARQ
+//
+//	@Description: 设置可靠传输配置，默认为 arq.FastOptions
+//	@param value
+//	@return Option
+func WithARQ(value *arq.Options) Option {
+	return func(opts *Options) {
+		opts.ARQ = value
+	}
+}
+
+func WithUnregisterSessionLife(value int64) Option {
+	return func(opts *Options) {
+		opts.UnregisterSessionLife = value
+	}
+}
+
+func WithCheckSessionInterval(value time.Duration) Option {
+	return func(opts *Options) {
+		opts.CheckSessionInterval = value
+	}
+}
+
+func WithLinger(value time.Duration) Option {
+	return func(opts *Options) {
+		opts.Linger = value
+	}
+}
+
+func WithPacketQueueCap(value int) Option {
+	return func(opts *Options) {
+		opts.PacketQueueCap = value
+	}
+}
+
+func WithSocketRecvBuffer(value int) Option {
+	return func(opts *Options) {
+		opts.SocketRecvBuffer = value
+	}
+}
+
+func WithSocketSendBuffer(value int) Option {
+	return func(opts *Options) {
+		opts.SocketSendBuffer = value
+	}
+}
+
+// WithPacketFilter
+//
+//	@Description: 设置入站数据包过滤
+//	@param filter 返回false时丢弃数据包
+//	@return Option
+func WithPacketFilter(filter func(packet []byte) bool) Option {
+	return func(opts *Options) {
+		opts.PacketFilter = filter
+	}
+}
+
+func WithDispatcher(value *session.Dispatcher) Option {
+	return func(opts *Options) {
+		opts.Dispatcher = value
+	}
+}
+
+// WithHeartbeat
+//
+//	@Description: 开启心跳与空闲检测
+//	@param interval 未收到数据多久后发送心跳
+//	@param maxMissed 连续未响应的心跳次数上限，小于1时按1处理
+//	@param message 心跳消息构造函数，为nil时仅做空闲检测
+//	@return Option
+func WithHeartbeat(interval time.Duration, maxMissed int, message func() any) Option {
+	return func(opts *Options) {
+		opts.Heartbeat = &session.HeartbeatOptions{
+			Interval:  interval,
+			MaxMissed: maxMissed,
+			Message:   message,
+		}
+	}
+}
+
+// WithResume
+//
+//	@Description: 开启会话恢复，注册后发出的消息保留在重放缓冲中，客户端重连后可通过 Server.ResumeSession 恢复
+//	@param gracePeriod 会话关闭后保留恢复状态的时长
+//	@param bufferSize 重放缓冲保留的消息数
+//	@return Option
+func WithResume(gracePeriod time.Duration, bufferSize int) Option {
+	return func(opts *Options) {
+		opts.Resume = &session.ResumeOptions{
+			GracePeriod: gracePeriod,
+			BufferSize:  bufferSize,
+		}
+	}
+}
+
+// WithLimit
+//
+//	@Description: 开启会话数限制，仅使用全局及单IP的并发连接数上限，监听器可实现 session.LimitListener 获知被拒绝的会话
+//	@param value 限制配置
+//	@return Option
+func WithLimit(value *session.LimitOptions) Option {
+	return func(opts *Options) {
+		opts.Limit = value
+	}
+}
+
+// WithManager
+//
+//	@Description: 使用共享的会话管理器，多个服务共享时会话注册与顶号跨服务生效
+//		共享时 UnregisterSessionLife 以管理器的配置为准，恢复配置应保持一致
+//	@param manager 会话管理器
+//	@return Option
+func WithManager(manager *session.Manager) Option {
+	return func(opts *Options) {
+		opts.Manager = manager
+	}
+}
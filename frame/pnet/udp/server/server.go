@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/meow-pad/persian/errdef"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/utils"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	statusInitial = iota
+	statusStarting
+	statusRunning
+)
+
+var (
+	ErrServerStarted    = errors.New("udp server is already started")
+	ErrServerNotStarted = errors.New("udp server is not started")
+)
+
+func NewServer(name string, protoAddr string,
+	codec codec.Codec, listener session.Listener, opts ...Option) (server *Server, err error) {
+	var options *Options
+	options, err = NewOptions(opts...)
+	if err != nil {
+		return
+	}
+	protoAddr, err = utils.CompleteAddress(protoAddr, utils.ProtoUDP)
+	if err != nil {
+		return
+	}
+	if codec == nil {
+		err = errors.New("less codec")
+		return
+	}
+	if listener == nil {
+		err = errors.New("less listener")
+		return
+	}
+	manager := options.Manager
+	if manager == nil {
+		if manager, err = session.NewManager(name, options.UnregisterSessionLife); err != nil {
+			return
+		}
+	}
+	if options.Resume != nil {
+		manager.EnableResume(options.Resume)
+	}
+	server = &Server{
+		Manager:   manager,
+		options:   options,
+		name:      name,
+		protoAddr: protoAddr,
+		codec:     codec,
+		listener:  listener,
+		sessions:  make(map[linkKey]*svrSession),
+
+		connCounter: session.NewConnCounter(options.Limit),
+	}
+	server.Broadcaster = session.NewBroadcaster(manager, server, server.codec.Encode,
+		func(sess *svrSession, data []byte, message any) {
+			sess.sendData(data, message)
+		})
+	return
+}
+
+type Server struct {
+	*session.Manager
+	// 广播，仅发送给本服务接受的会话
+	*session.Broadcaster[*svrSession, []byte]
+	options *Options
+	// 服务名称
+	name string
+	// 带协议地址
+	protoAddr string
+	// 编解码器
+	codec codec.Codec
+	// 会话监听器
+	listener session.Listener
+	// 监听的socket
+	conn *net.UDPConn
+	// 会话，仅在事件循环中访问
+	sessions map[linkKey]*svrSession
+	// 已读取待处理的数据包
+	packets chan packet
+	// 停服通知
+	stopChan chan struct{}
+	// 事件循环结束通知
+	doneChan chan struct{}
+	// 启动状态
+	status atomic.Uint32
+	// 是否已开始停服
+	stopping atomic.Bool
+	// 会话计数，未限制会话数时为nil
+	connCounter *session.ConnCounter
+	// 丢弃的无效数据包数
+	invalidPackets atomic.Uint64
+}
+
+func (server *Server) Start(ctx context.Context) (err error) {
+	if !server.status.CompareAndSwap(statusInitial, statusStarting) {
+		return ErrServerStarted
+	}
+	defer func() {
+		if err != nil {
+			server.status.Store(statusInitial)
+		}
+	}()
+	_, address, err := utils.SplitAddress(server.protoAddr, utils.ProtoUDP)
+	if err != nil {
+		return err
+	}
+	udpAddr, err := net.ResolveUDPAddr(utils.ProtoUDP, address)
+	if err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP(utils.ProtoUDP, udpAddr)
+	if err != nil {
+		return err
+	}
+	if err = conn.SetReadBuffer(server.options.SocketRecvBuffer); err != nil {
+		plog.Warn("set socket recv buffer error:", pfield.String("server", server.name), pfield.Error(err))
+	}
+	if err = conn.SetWriteBuffer(server.options.SocketSendBuffer); err != nil {
+		plog.Warn("set socket send buffer error:", pfield.String("server", server.name), pfield.Error(err))
+	}
+	server.conn = conn
+	server.packets = make(chan packet, server.options.PacketQueueCap)
+	server.stopChan = make(chan struct{})
+	server.doneChan = make(chan struct{})
+	loop := newEventLoop(server)
+	server.status.Store(statusRunning)
+	go server.read()
+	go loop.run()
+	return nil
+}
+
+// Stop
+//
+//	@Description: 停止服务，关闭所有会话并等待已提交的数据发出，可作为 pboot.LifeCycle 按注册顺序逆序停止
+//	@receiver server
+//	@param ctx 停止上下文，超时后直接关闭socket
+//	@return error 未启动时返回 ErrServerNotStarted
+func (server *Server) Stop(ctx context.Context) error {
+	if server.status.Load() != statusRunning {
+		return ErrServerNotStarted
+	}
+	if server.stopping.CompareAndSwap(false, true) {
+		close(server.stopChan)
+	}
+	select {
+	case <-server.doneChan:
+		return nil
+	case <-ctx.Done():
+		if err := server.conn.Close(); err != nil {
+			plog.Error("close socket error:", pfield.String("server", server.name), pfield.Error(err))
+		}
+		return ctx.Err()
+	}
+}
+
+// Addr
+//
+//	@Description: 监听的地址
+//	@receiver server
+//	@return net.Addr 未启动时为nil
+func (server *Server) Addr() net.Addr {
+	if server.status.Load() != statusRunning {
+		return nil
+	}
+	return server.conn.LocalAddr()
+}
+
+// InvalidPackets
+//
+//	@Description: 丢弃的无效数据包数，如会话编号不符、命令无效或数据截断
+//	@receiver server
+//	@return uint64
+func (server *Server) InvalidPackets() uint64 {
+	return server.invalidPackets.Load()
+}
+
+func (server *Server) Name() string {
+	return server.name
+}
+
+// CName
+//
+//	@Description: 组件名，用于作为 pboot.LifeCycle 注册
+//	@receiver server
+//	@return string
+func (server *Server) CName() string {
+	return server.name
+}
+
+// packet
+//
+//	@Description: 读取的数据包
+type packet struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// read
+//
+//	@Description: 读取数据包并投递到事件循环，socket关闭后退出
+//	@receiver server
+func (server *Server) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := server.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			plog.Debug("read packet error:", pfield.String("server", server.name), pfield.Error(err))
+			continue
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if filter := server.options.PacketFilter; filter != nil && !filter(data) {
+			continue
+		}
+		select {
+		case server.packets <- packet{addr: addr, data: data}:
+		default:
+			plog.Debug("packet queue is full", pfield.String("server", server.name))
+		}
+	}
+}
+
+// writeTo
+//
+//	@Description: 发送数据包，丢包由 ARQ 重传
+//	@receiver server
+//	@param data
+//	@param addr
+func (server *Server) writeTo(data []byte, addr *net.UDPAddr) {
+	if _, err := server.conn.WriteToUDP(data, addr); err != nil {
+		plog.Debug("write packet error:", pfield.String("server", server.name), pfield.Error(err))
+	}
+}
+
+// ResumeSession
+//
+//	@Description: 在新连接的会话上恢复会话，成功后会话关联原业务对象并重发客户端缺失的消息
+//	@receiver server
+//	@param sess 新连接的未注册会话
+//	@param token 注册时签发的恢复令牌，可由 session.Session.ResumeState 获取
+//	@param lastSeq 客户端已收到的最后消息序号，注册后发出的消息从1开始按发送顺序编号
+//	@return error
+func (server *Server) ResumeSession(sess session.Session, token string, lastSeq uint64) error {
+	svrSess, ok := server.ownSession(sess)
+	if !ok {
+		return errdef.ErrInvalidParams
+	}
+	return server.Manager.ResumeSession(svrSess, token, lastSeq, svrSess.replay)
+}
+
+// ownSession
+//
+//	@Description: 获取由本服务接受的会话，共享管理器时忽略其他服务的会话
+//	@receiver server
+//	@param sess
+//	@return *svrSession
+//	@return bool
+func (server *Server) ownSession(sess session.Session) (*svrSession, bool) {
+	return session.OwnSession[*svrSession](sess, server)
+}
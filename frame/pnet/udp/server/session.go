@@ -0,0 +1,212 @@
+package server
+
+import (
+	"errors"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"time"
+)
+
+// linkKey
+//
+//	@Description: 会话的索引，同一地址可有多个会话
+type linkKey struct {
+	addr string
+	conv uint32
+}
+
+// newSession
+//
+//	@Description: 构造session
+//	@param server
+//	@param link
+//	@param key
+//	@return *svrSession
+func newSession(server *Server, link *arq.Link, key linkKey) *svrSession {
+	svrSess := &svrSession{
+		server: server,
+		conn:   link,
+		key:    key,
+	}
+	link.SetContext(svrSess)
+	return svrSess
+}
+
+type svrSession struct {
+	session.BaseSession
+
+	// 关联的服务
+	server *Server
+	// 关联的连接
+	conn *arq.Link
+	// 会话索引
+	key linkKey
+	// 关闭后等待数据发出的截止时间，仅在事件循环中访问
+	lingerDeadline time.Time
+	// 是否占用了会话数，仅在事件循环中访问
+	counted bool
+}
+
+// Owner
+//
+//	@Description: 接受该会话的服务
+//	@receiver sess
+//	@return any
+func (sess *svrSession) Owner() any {
+	return sess.server
+}
+
+func (sess *svrSession) Connection() session.Conn {
+	return sess.conn
+}
+
+func (sess *svrSession) Register(context session.Context) error {
+	return sess.server.RegisterSession(sess, context)
+}
+
+func (sess *svrSession) Close() error {
+	return sess.conn.Close()
+}
+
+func (sess *svrSession) IsClosed() bool {
+	closed, _ := sess.conn.IsClosed()
+	return closed
+}
+
+func (sess *svrSession) SendMessage(message any) {
+	if closed, _ := sess.conn.IsClosed(); closed {
+		plog.Debug("cant send to closed conn")
+		return
+	}
+	data, err := sess.server.codec.Encode(message)
+	if err != nil {
+		sess.onSendingError("encode message error:", err)
+		return
+	}
+	sess.sendData(data, message)
+}
+
+// sendData
+//
+//	@Description: 发送已编码的消息数据，数据可在多个会话间共享
+//	@receiver sess
+//	@param data 编码后的数据
+//	@param message 原始消息
+func (sess *svrSession) sendData(data []byte, message any) {
+	if closed, _ := sess.conn.IsClosed(); closed {
+		return
+	}
+	dataLen := len(data)
+	err := sess.asyncWritev([][]byte{data}, func(c session.Conn, err error) error {
+		if err = sess.server.listener.OnSend(sess, message, dataLen); err != nil {
+			plog.Error("on send error:", pfield.Error(err))
+		}
+		return nil
+	})
+	if err != nil {
+		sess.onSendingError("async write error:", err)
+	}
+}
+
+func (sess *svrSession) SendMessages(messages ...any) {
+	if closed, _ := sess.conn.IsClosed(); closed {
+		plog.Debug("cant send to closed conn")
+		return
+	}
+	totalLen := 0
+	dataArr := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		data, err := sess.server.codec.Encode(message)
+		if err != nil {
+			sess.onSendingError("encode message error:", err)
+			return
+		}
+		dataArr = append(dataArr, data)
+		totalLen += len(data)
+	}
+	err := sess.asyncWritev(dataArr, func(c session.Conn, err error) error {
+		if err = sess.server.listener.OnSendMulti(sess, messages, totalLen); err != nil {
+			plog.Error("on send error:", pfield.Error(err))
+		}
+		return nil
+	})
+	if err != nil {
+		sess.onSendingError("async writev error:", err)
+	}
+}
+
+// asyncWritev
+//
+//	@Description: 提交数据，开启会话恢复时按提交顺序记录到重放缓冲
+//	@receiver sess
+//	@param dataArr 编码后的数据，每项为一条消息
+//	@param callback 提交完成回调
+//	@return error
+func (sess *svrSession) asyncWritev(dataArr [][]byte, callback func(c session.Conn, err error) error) error {
+	write := func() error {
+		return sess.conn.AsyncWritev(dataArr, callback)
+	}
+	state := sess.ResumeState()
+	if state == nil {
+		return write()
+	}
+	items := make([]any, 0, len(dataArr))
+	for _, data := range dataArr {
+		items = append(items, data)
+	}
+	return state.Send(sess, items, write)
+}
+
+// replay
+//
+//	@Description: 恢复会话时重发缺失的消息
+//	@receiver sess
+//	@param items 重放缓冲中的已编码数据
+//	@return error
+func (sess *svrSession) replay(items []any) error {
+	dataArr := make([][]byte, 0, len(items))
+	for _, item := range items {
+		data, ok := item.([]byte)
+		if !ok {
+			// 共享管理器时由其他协议的服务记录，无法重放
+			return pnet.ErrResumeSeqMissed
+		}
+		dataArr = append(dataArr, data)
+	}
+	return sess.conn.AsyncWritev(dataArr, nil)
+}
+
+// closeWithReason
+//
+//	@Description: 记录关闭原因后关闭连接
+//	@receiver sess
+//	@param reason 关闭原因
+//	@return error
+func (sess *svrSession) closeWithReason(reason error) error {
+	sess.conn.ToClosed(reason)
+	return sess.conn.Close()
+}
+
+// onSendingError
+//
+//	@Description: 发送消息时错误处理
+//	@receiver sess
+//	@param tip 日志消息
+//	@param err 错误
+func (sess *svrSession) onSendingError(tip string, err error) {
+	plog.Error(tip, pfield.Error(err))
+	// 无法处理的状态，关闭连接
+	category := session.CloseNetwork
+	if errors.Is(err, pnet.ErrMessageTooLarge) {
+		category = session.CloseProtocol
+	} else if errors.Is(err, pnet.ErrWriteQueueFull) {
+		// 对端确认过慢，发送队列已满
+		category = session.CloseLimited
+	}
+	if cErr := sess.closeWithReason(session.NewCloseReason(category, session.CloseByLocal, err)); cErr != nil {
+		plog.Error("close conn error", pfield.Error(cErr))
+	}
+}
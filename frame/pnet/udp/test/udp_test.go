@@ -0,0 +1,366 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/codec"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"github.com/meow-pad/persian/frame/pnet/udp/arq"
+	"github.com/meow-pad/persian/frame/pnet/udp/client"
+	"github.com/meow-pad/persian/frame/pnet/udp/server"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCodec() codec.Codec {
+	c, _ := codec.NewLengthFieldCodec()
+	return c
+}
+
+// lossyFilter
+//
+//	@Description: 按比例随机丢弃入站数据包，关闭后不再丢包
+type lossyFilter struct {
+	mutex   sync.Mutex
+	rand    *rand.Rand
+	loss    float64
+	enabled atomic.Bool
+	dropped atomic.Int32
+}
+
+func newLossyFilter(loss float64, seed int64) *lossyFilter {
+	filter := &lossyFilter{rand: rand.New(rand.NewSource(seed)), loss: loss}
+	filter.enabled.Store(true)
+	return filter
+}
+
+func (filter *lossyFilter) filter(_ []byte) bool {
+	if !filter.enabled.Load() {
+		return true
+	}
+	filter.mutex.Lock()
+	defer filter.mutex.Unlock()
+	if filter.rand.Float64() < filter.loss {
+		filter.dropped.Add(1)
+		return false
+	}
+	return true
+}
+
+type echoListener struct {
+	session.EmptyListener
+	opened chan session.Session
+	closed chan *session.CloseReason
+}
+
+func (listener *echoListener) OnOpened(sess session.Session) {
+	listener.opened <- sess
+}
+
+func (listener *echoListener) OnClosed(sess session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func (listener *echoListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	switch msg {
+	case "login":
+		ctx := &session.BaseContext{}
+		ctx.Init(1)
+		ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+		if err = sess.Register(ctx); err != nil {
+			return
+		}
+	case "bye":
+		return sess.Close()
+	}
+	sess.SendMessage(msg)
+	return nil
+}
+
+func (listener *echoListener) OnReceiveMulti(sess session.Session, msgArr []any, totalLen int) error {
+	for _, msg := range msgArr {
+		if err := listener.OnReceive(sess, msg, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recvListener struct {
+	session.EmptyListener
+	mutex    sync.Mutex
+	received []any
+	closed   chan *session.CloseReason
+}
+
+func (listener *recvListener) OnClosed(sess session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func (listener *recvListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+	listener.received = append(listener.received, msg)
+	return nil
+}
+
+func (listener *recvListener) OnReceiveMulti(sess session.Session, msgArr []any, totalLen int) error {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+	listener.received = append(listener.received, msgArr...)
+	return nil
+}
+
+func (listener *recvListener) count() int {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+	return len(listener.received)
+}
+
+func expectReason(should *require.Assertions, closed chan *session.CloseReason) *session.CloseReason {
+	select {
+	case reason := <-closed:
+		should.NotNil(reason)
+		return reason
+	case <-time.After(5 * time.Second):
+		should.FailNow("close timeout")
+	}
+	return nil
+}
+
+func TestUDP_Echo(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12096"
+	svrFilter := newLossyFilter(0.2, 1)
+	svrListener := &echoListener{opened: make(chan session.Session, 1), closed: make(chan *session.CloseReason, 1)}
+	svr, err := server.NewServer("test-server", "udp://"+addr, newCodec(), svrListener,
+		server.WithPacketFilter(svrFilter.filter))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+
+	cliFilter := newLossyFilter(0.2, 2)
+	cliListener := &recvListener{closed: make(chan *session.CloseReason, 1)}
+	cli, err := client.NewClient(newCodec(), cliListener,
+		client.WithName("test-client"), client.WithPacketFilter(cliFilter.filter))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), "udp://"+addr))
+	var sess session.Session
+	select {
+	case sess = <-svrListener.opened:
+	case <-time.After(5 * time.Second):
+		should.FailNow("open timeout")
+	}
+
+	// 含多个分片的消息
+	large := strings.Repeat("x", 3*arq.DefaultMTU)
+	expected := []any{"login"}
+	cli.SendMessage("login")
+	for i := 0; i < 200; i++ {
+		msg := fmt.Sprintf("msg-%d", i)
+		if i%50 == 0 {
+			msg += large
+		}
+		expected = append(expected, msg)
+		if i%10 == 0 {
+			cli.SendMessages(msg)
+		} else {
+			cli.SendMessage(msg)
+		}
+	}
+	should.Eventually(func() bool {
+		return cliListener.count() >= len(expected)
+	}, 20*time.Second, 10*time.Millisecond)
+	cliListener.mutex.Lock()
+	should.Equal(expected, cliListener.received)
+	cliListener.mutex.Unlock()
+	should.Greater(svrFilter.dropped.Load()+cliFilter.dropped.Load(), int32(0))
+	t.Logf("dropped server:%d client:%d", svrFilter.dropped.Load(), cliFilter.dropped.Load())
+
+	// 会话经管理器注册
+	should.Equal(uint64(1), sess.Id())
+	should.Equal(sess, svr.GetSession(1))
+
+	// 客户端主动关闭，对端收到关闭通知
+	svrFilter.enabled.Store(false)
+	cliFilter.enabled.Store(false)
+	should.Nil(cli.Close())
+	should.ErrorIs(cli.Close(), pnet.ErrClosedClient)
+	reason := expectReason(should, cliListener.closed)
+	should.Equal(session.CloseNormal, reason.Category)
+	should.Equal(session.CloseByLocal, reason.Initiator)
+	reason = expectReason(should, svrListener.closed)
+	should.Equal(session.CloseNormal, reason.Category)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+	should.ErrorIs(reason, arq.ErrPeerClosed)
+	should.Nil(svr.GetSession(1))
+	<-cli.Done()
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestUDP_CloseReason(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12097"
+	svrListener := &echoListener{opened: make(chan session.Session, 2), closed: make(chan *session.CloseReason, 2)}
+	svr, err := server.NewServer("test-server", "udp://"+addr, newCodec(), svrListener)
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	dial := func() (*client.Client, *recvListener) {
+		listener := &recvListener{closed: make(chan *session.CloseReason, 1)}
+		cli, err := client.NewClient(newCodec(), listener)
+		should.Nil(err)
+		should.Nil(cli.Dial(context.Background(), addr))
+		<-svrListener.opened
+		return cli, listener
+	}
+
+	// 服务端主动关闭，关闭前的消息仍会送达
+	first, firstListener := dial()
+	first.SendMessage("hello")
+	first.SendMessage("bye")
+	reason := expectReason(should, svrListener.closed)
+	should.Equal(session.CloseNormal, reason.Category)
+	should.Equal(session.CloseByLocal, reason.Initiator)
+	reason = expectReason(should, firstListener.closed)
+	should.Equal(session.CloseNormal, reason.Category)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+	should.Equal([]any{"hello"}, firstListener.received)
+	should.True(first.IsClosed())
+
+	// 停服时关闭所有会话
+	_, secondListener := dial()
+	should.Nil(svr.Stop(context.Background()))
+	reason = expectReason(should, svrListener.closed)
+	should.Equal(session.CloseShutdown, reason.Category)
+	should.ErrorIs(reason, pnet.ErrServerStopped)
+	reason = expectReason(should, secondListener.closed)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+}
+
+func TestUDP_DeadLink(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12098"
+	svrListener := &echoListener{opened: make(chan session.Session, 1), closed: make(chan *session.CloseReason, 1)}
+	svr, err := server.NewServer("test-server", "udp://"+addr, newCodec(), svrListener)
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+
+	// 客户端收不到任何数据包，重传达到上限后断开
+	options := arq.FastOptions()
+	options.DeadLink = 5
+	listener := &recvListener{closed: make(chan *session.CloseReason, 1)}
+	cli, err := client.NewClient(newCodec(), listener, client.WithARQ(options),
+		client.WithPacketFilter(func(packet []byte) bool { return false }))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	<-svrListener.opened
+	cli.SendMessage("hello")
+	reason := expectReason(should, listener.closed)
+	should.Equal(session.CloseNetwork, reason.Category)
+	should.ErrorIs(reason, arq.ErrDeadLink)
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type limitListener struct {
+	echoListener
+	limited chan session.LimitEvent
+}
+
+func (listener *limitListener) OnLimited(sess session.Session, event session.LimitEvent) {
+	listener.limited <- event
+}
+
+func TestUDP_Guard(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12110"
+	listener := &limitListener{
+		echoListener: echoListener{opened: make(chan session.Session, 2), closed: make(chan *session.CloseReason, 2)},
+		limited:      make(chan session.LimitEvent, 1),
+	}
+	svr, err := server.NewServer("test-server", "udp://"+addr, newCodec(), listener,
+		server.WithLimit(&session.LimitOptions{MaxConnectionsPerIP: 1}))
+	should.Nil(err)
+	// 未启动
+	should.Nil(svr.Addr())
+	should.ErrorIs(svr.Stop(context.Background()), server.ErrServerNotStarted)
+	should.Nil(svr.Start(context.Background()))
+	should.ErrorIs(svr.Start(context.Background()), server.ErrServerStarted)
+	should.Equal(addr, svr.Addr().String())
+
+	// 以原始socket开启会话
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	should.Nil(err)
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	should.Nil(err)
+	var opening []byte
+	peer := arq.NewARQ(7, nil, func(packet []byte) { opening = append([]byte(nil), packet...) })
+	should.Nil(peer.Send(nil))
+	peer.Update(arq.Now())
+	should.NotEmpty(opening)
+	_, err = conn.Write(opening)
+	should.Nil(err)
+	var sess session.Session
+	select {
+	case sess = <-listener.opened:
+	case <-time.After(3 * time.Second):
+		should.FailNow("open timeout")
+	}
+	// 截断的数据包仅丢弃
+	_, err = conn.Write(opening[:10])
+	should.Nil(err)
+	should.Eventually(func() bool {
+		return svr.InvalidPackets() == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	should.False(sess.IsClosed())
+
+	// 超出单IP会话数上限时拒绝
+	cliListener := &recvListener{closed: make(chan *session.CloseReason, 1)}
+	cli, err := client.NewClient(newCodec(), cliListener)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	select {
+	case event := <-listener.limited:
+		should.Equal(session.LimitIPConnections, event.Kind)
+	case <-time.After(3 * time.Second):
+		should.FailNow("limit timeout")
+	}
+	reason := expectReason(should, cliListener.closed)
+	should.Equal(session.CloseByPeer, reason.Initiator)
+	should.False(sess.IsClosed())
+	_ = conn.Close()
+	should.Nil(svr.Stop(context.Background()))
+	should.Equal(session.CloseShutdown, expectReason(should, listener.closed).Category)
+}
+
+func TestUDP_SendQueue(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:12111"
+	svrListener := &echoListener{opened: make(chan session.Session, 1), closed: make(chan *session.CloseReason, 1)}
+	svr, err := server.NewServer("test-server", "udp://"+addr, newCodec(), svrListener)
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+
+	// 收不到确认时发送队列不会无限增长
+	options := arq.FastOptions()
+	options.SendQueue = 4
+	listener := &recvListener{closed: make(chan *session.CloseReason, 1)}
+	cli, err := client.NewClient(newCodec(), listener, client.WithARQ(options),
+		client.WithPacketFilter(func(packet []byte) bool { return false }))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), addr))
+	<-svrListener.opened
+	for i := 0; i < 10; i++ {
+		cli.SendMessage("hello")
+	}
+	should.Equal(4, cli.Connection().(*arq.Link).WaitSend())
+	should.False(cli.IsClosed())
+	should.Nil(cli.Close())
+	expectReason(should, listener.closed)
+	should.Nil(svr.Stop(context.Background()))
+}
@@ -6,9 +6,11 @@ import (
 )
 
 const (
-	ProtoTCP       = "tcp"
-	ProtoUDP       = "udp"
-	ProtoWebsocket = "ws"
+	ProtoTCP             = "tcp"
+	ProtoUDP             = "udp"
+	ProtoUnix            = "unix"
+	ProtoWebsocket       = "ws"
+	ProtoWebsocketSecure = "wss"
 )
 
 const separator = "://"
@@ -16,22 +18,54 @@ const separator = "://"
 // CompleteAddress
 //
 //	@Description: 补完地址
-//	@param address 原始地址，格式如 `192.168.0.10:9851` 或 `tcp://192.168.0.10:9851`
-//	@param proto  协议，如 `tcp`、`udp`
+//	@param address 原始地址，格式如 `192.168.0.10:9851` 、 `tcp://192.168.0.10:9851` 或 `unix:///tmp/server.sock`
+//	@param proto  缺省协议，如 `tcp`、`udp`
+//	@param others 同样允许的其他协议
 //	@return string 地址格式如 `tcp://192.168.0.10:9851`
 //	@return error
-func CompleteAddress(address, proto string) (string, error) {
-	address = strings.ToLower(address)
+func CompleteAddress(address, proto string, others ...string) (string, error) {
+	addrProto, addr, err := SplitAddress(address, proto, others...)
+	if err != nil {
+		return "", err
+	}
+	return addrProto + separator + addr, nil
+}
+
+// SplitAddress
+//
+//	@Description: 分拆协议和地址，没有协议时使用缺省协议，unix socket路径保留大小写
+//	@param address 原始地址
+//	@param proto 缺省协议
+//	@param others 同样允许的其他协议
+//	@return string 协议
+//	@return string 不带协议的地址
+//	@return error
+func SplitAddress(address, proto string, others ...string) (string, string, error) {
 	index := strings.Index(address, separator)
-	if index >= 0 {
-		addrProto := address[:index]
-		if addrProto != proto {
-			return "", fmt.Errorf("protocol(%s) not match protocol(%s)", addrProto, proto)
+	if index < 0 {
+		return proto, normalizeAddress(proto, address), nil
+	}
+	addrProto := strings.ToLower(address[:index])
+	if addrProto != proto && !containsProto(others, addrProto) {
+		return "", "", fmt.Errorf("protocol(%s) not match protocol(%s)", addrProto, proto)
+	}
+	return addrProto, normalizeAddress(addrProto, address[index+len(separator):]), nil
+}
+
+func normalizeAddress(proto, address string) string {
+	if proto == ProtoUnix {
+		return address
+	}
+	return strings.ToLower(address)
+}
+
+func containsProto(protos []string, proto string) bool {
+	for _, p := range protos {
+		if p == proto {
+			return true
 		}
-	} else {
-		address = fmt.Sprintf("%s%s%s", proto, separator, address)
 	}
-	return address, nil
+	return false
 }
 
 // GetAddress
@@ -42,11 +76,10 @@ func CompleteAddress(address, proto string) (string, error) {
 //	@return address
 //	@return err
 func GetAddress(protoAddr string) (proto string, address string, err error) {
-	protoAddr = strings.ToLower(protoAddr)
 	index := strings.Index(protoAddr, separator)
 	if index >= 0 {
-		proto = protoAddr[:index]
-		address = protoAddr[index+len(separator):]
+		proto = strings.ToLower(protoAddr[:index])
+		address = normalizeAddress(proto, protoAddr[index+len(separator):])
 		return
 	} else {
 		err = fmt.Errorf("invalid protoAddr(%s), something like 'tcp://192.168.0.10:9851'", protoAddr)
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCompleteAddress(t *testing.T) {
+	should := require.New(t)
+	addr, err := CompleteAddress("LocalHost:9851", ProtoTCP, ProtoUnix)
+	should.Nil(err)
+	should.Equal("tcp://localhost:9851", addr)
+	addr, err = CompleteAddress("UNIX:///tmp/Server.sock", ProtoTCP, ProtoUnix)
+	should.Nil(err)
+	should.Equal("unix:///tmp/Server.sock", addr)
+	_, err = CompleteAddress("unix:///tmp/server.sock", ProtoTCP)
+	should.NotNil(err)
+
+	proto, addr, err := SplitAddress("unix:///tmp/server.sock", ProtoTCP, ProtoUnix)
+	should.Nil(err)
+	should.Equal(ProtoUnix, proto)
+	should.Equal("/tmp/server.sock", addr)
+	proto, addr, err = SplitAddress("127.0.0.1:9851", ProtoTCP, ProtoUnix)
+	should.Nil(err)
+	should.Equal(ProtoTCP, proto)
+	should.Equal("127.0.0.1:9851", addr)
+}
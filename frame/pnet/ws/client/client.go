@@ -11,6 +11,7 @@ import (
 	"github.com/meow-pad/persian/frame/pnet/message"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/frame/pnet/utils"
+	"net/http"
 	"net/url"
 	"sync/atomic"
 )
@@ -24,8 +25,19 @@ const (
 
 var (
 	ErrInvalidStatus = errors.New("invalid client status")
+	ErrInvalidUrl    = errors.New("invalid websocket url")
 )
 
+// Handshake
+//
+//	@Description: 握手响应信息
+type Handshake struct {
+	// 服务端选中的子协议，未协商时为空
+	Subprotocol string
+	// 响应头
+	Header http.Header
+}
+
 func NewClient(codec message.Codec, listener session.Listener, opts ...Option) (*Client, error) {
 	options := newOptions(opts...)
 	client := &Client{}
@@ -49,6 +61,12 @@ type Client struct {
 	conn   *Conn
 	loop   *eventLoop
 	connPT atomic.Pointer[Conn]
+	// 连接地址
+	wsUrl *url.URL
+	// 是否已主动关闭，主动关闭后不再重连
+	closing atomic.Bool
+	// 最近一次握手响应
+	handshake atomic.Pointer[Handshake]
 }
 
 func (client *Client) init(codec message.Codec, listener session.Listener, options *Options) error {
@@ -70,30 +88,55 @@ func (client *Client) init(codec message.Codec, listener session.Listener, optio
 //	@Description: 连接
 //	@receiver client
 //	@param ctx
-//	@param address 如：127.0.0.1:9999
+//	@param wsUrl 如：ws://127.0.0.1:9999/ 或 wss://example.com/ws
 //	@return error
 func (client *Client) Dial(ctx context.Context, wsUrl *url.URL) error {
 	status := client.status.Load()
 	if status != StatusInitial && status != StatusClosed {
 		return ErrInvalidStatus
 	}
+	//wsUrl := url.URL{Scheme: utils.ProtoWebsocket, Host: address, Path: "/"}
+	if wsUrl == nil || (wsUrl.Scheme != utils.ProtoWebsocket && wsUrl.Scheme != utils.ProtoWebsocketSecure) {
+		return ErrInvalidUrl
+	}
 	if !client.status.CompareAndSwap(status, StatusConnecting) {
 		return ErrInvalidStatus
 	}
-	client.status.Store(StatusConnecting)
-	//wsUrl := url.URL{Scheme: utils.ProtoWebsocket, Host: address, Path: "/"}
-	if wsUrl == nil || wsUrl.Scheme != utils.ProtoWebsocket {
-		return errors.New("invalid websocket url")
+	client.wsUrl = wsUrl
+	client.closing.Store(false)
+	return client.dial(ctx, status)
+}
+
+// dial
+//
+//	@Description: 连接已记录的地址，失败时恢复到原状态
+//	@receiver client
+//	@param ctx
+//	@param fromStatus 连接前的状态
+//	@return error
+func (client *Client) dial(ctx context.Context, fromStatus uint32) error {
+	dialer := websocket.Dialer{
+		TLSClientConfig:  client.TLSConfig,
+		HandshakeTimeout: client.HandshakeTimeout,
+		Subprotocols:     client.Subprotocols,
+		Jar:              client.CookieJar,
+
+		EnableCompression: client.Compression != nil,
 	}
-	var dialer websocket.Dialer
-	conn, _, err := dialer.DialContext(ctx, wsUrl.String(), nil)
+	conn, resp, err := dialer.DialContext(ctx, client.wsUrl.String(), client.Header)
 	if err != nil {
+		client.status.Store(fromStatus)
 		return err
 	}
+	handshake := &Handshake{Subprotocol: conn.Subprotocol()}
+	if resp != nil {
+		handshake.Header = resp.Header
+	}
+	client.handshake.Store(handshake)
 	client.conn, err = NewConn(client, conn)
 	if err != nil {
 		client.conn = nil
-		client.status.Store(StatusInitial)
+		client.status.Store(fromStatus)
 		if tErr := conn.Close(); tErr != nil {
 			plog.Error("", pfield.Error(tErr))
 		}
@@ -104,7 +147,7 @@ func (client *Client) Dial(ctx context.Context, wsUrl *url.URL) error {
 	if err = client.loop.start(client.conn); err != nil {
 		client.conn = nil
 		client.connPT.Store(nil)
-		client.status.Store(StatusInitial)
+		client.status.Store(fromStatus)
 		if tErr := conn.Close(); tErr != nil {
 			plog.Error("", pfield.Error(tErr))
 		}
@@ -113,6 +156,30 @@ func (client *Client) Dial(ctx context.Context, wsUrl *url.URL) error {
 	return nil
 }
 
+// reconnect
+//
+//	@Description: 断线后按重连配置重新连接
+//	@receiver client
+//	@param reason 断线原因
+func (client *Client) reconnect(reason error) {
+	client.Reconnect.Reconnect(client.Name, reason, client.Attributes(), client.closing.Load,
+		func(ctx context.Context) (bool, error) {
+			if !client.status.CompareAndSwap(StatusClosed, StatusConnecting) {
+				return false, nil
+			}
+			return true, client.dial(ctx, StatusClosed)
+		}, client.Close)
+}
+
+// Handshake
+//
+//	@Description: 获取最近一次连接的握手响应，未连接过时返回nil
+//	@receiver client
+//	@return *Handshake
+func (client *Client) Handshake() *Handshake {
+	return client.handshake.Load()
+}
+
 func (client *Client) Status() uint32 {
 	return client.status.Load()
 }
@@ -122,6 +189,7 @@ func (client *Client) Connection() session.Conn {
 }
 
 func (client *Client) Close() error {
+	client.closing.Store(true)
 	if client.status.Load() == StatusClosed {
 		return pnet.ErrClosedClient
 	}
@@ -129,6 +197,7 @@ func (client *Client) Close() error {
 }
 
 func (client *Client) CloseWithContext(ctx context.Context) error {
+	client.closing.Store(true)
 	if client.status.Load() == StatusClosed {
 		return pnet.ErrClosedClient
 	}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"github.com/gorilla/websocket"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"io"
@@ -30,11 +31,40 @@ func (conn *Conn) Init(client *Client, pConn *websocket.Conn) error {
 	if conn.client.MaxMessageLength > 0 {
 		conn.SetReadLimit(conn.client.MaxMessageLength)
 	}
+	if compression := conn.client.Compression; compression != nil {
+		if err := conn.SetCompressionLevel(compression.Level); err != nil {
+			return err
+		}
+	}
 	//conn.SetCloseHandler(nil)
-	//conn.SetPingHandler(nil)
+	conn.SetPingHandler(func(appData string) error {
+		conn.UpdateReadTime()
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	conn.SetPongHandler(func(string) error {
+		conn.UpdateReadTime()
+		return nil
+	})
 	return conn.BaseConn.Init(conn, true)
 }
 
+// writeMessage
+//
+//	@Description: 写二进制消息，协商了压缩且消息长度达到阈值时压缩
+//	@receiver conn
+//	@param data
+//	@return error
+func (conn *Conn) writeMessage(data []byte) error {
+	if compression := conn.client.Compression; compression != nil {
+		conn.EnableWriteCompression(len(data) >= compression.Threshold)
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
 func (conn *Conn) WriteTo(w io.Writer) (n int64, err error) {
 	panic("not implemented")
 }
@@ -2,13 +2,14 @@ package client
 
 import (
 	"context"
-	"errors"
 	"github.com/gorilla/websocket"
 	"github.com/meow-pad/persian/errdef"
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
 	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/utils/coding"
+	"time"
 )
 
 // 写事件
@@ -39,6 +40,8 @@ type eventLoop struct {
 	readDone   chan struct{}
 	writeChan  chan writeEvent
 	closeChan  chan error
+	ticker     *time.Ticker
+	tickChan   <-chan time.Time
 	cancelCtx  context.Context
 	cancelFunc context.CancelFunc
 }
@@ -77,13 +80,13 @@ func (loop *eventLoop) run(first bool) {
 			var write bool
 			if len(event.buf) > 0 {
 				write = true
-				err = loop.conn.WriteMessage(websocket.BinaryMessage, event.buf)
+				err = loop.conn.writeMessage(event.buf)
 			}
 			if err == nil && len(event.bufS) > 0 {
 				for _, buf := range event.bufS {
-					if len(event.buf) > 0 {
+					if len(buf) > 0 {
 						write = true
-						err = loop.conn.WriteMessage(websocket.BinaryMessage, buf)
+						err = loop.conn.writeMessage(buf)
 						if err != nil {
 							break
 						}
@@ -91,6 +94,9 @@ func (loop *eventLoop) run(first bool) {
 				}
 			}
 			if write {
+				if err == nil {
+					loop.conn.UpdateWriteTime()
+				}
 				if event.callback != nil {
 					if cErr := event.callback(loop.conn, err); cErr != nil {
 						plog.Error("write callback error:", pfield.Error(cErr))
@@ -100,6 +106,10 @@ func (loop *eventLoop) run(first bool) {
 					return
 				}
 			}
+		case now := <-loop.tickChan:
+			if loop.checkIdle(now) {
+				return
+			}
 		case closeReason := <-loop.closeChan:
 			loop._stop(closeReason)
 			return
@@ -111,55 +121,69 @@ func (loop *eventLoop) run(first bool) {
 	}
 }
 
+// readState
+//
+//	@Description: 读协程使用的状态，在启动时获取，避免与重连时事件循环的重新启动竞争
+type readState struct {
+	conn      *Conn
+	readChan  chan []byte
+	readDone  chan struct{}
+	closeChan chan error
+	cancelCtx context.Context
+}
+
 // readConn
 //
-//	@Description: 持续读网络数据
+//	@Description: 持续读网络数据，仅处理启动时关联的连接
 //	@receiver loop
-func (loop *eventLoop) readConn() {
+//	@param state 启动时的读状态
+func (loop *eventLoop) readConn(state *readState) {
+	conn := state.conn
+	readChan, readDone, closeChan, cancelCtx := state.readChan, state.readDone, state.closeChan, state.cancelCtx
 	defer coding.CatchPanicError("read conn error:", func() {
-		if loop.client.IsClosed() {
+		if closed, _ := conn.IsClosed(); closed || cancelCtx.Err() != nil {
 			return
 		}
-		go loop.readConn()
+		go loop.readConn(state)
 	})
 	for {
 		// 判定是否已关闭
-		if loop.client.IsClosed() {
+		if closed, _ := conn.IsClosed(); closed {
 			return
 		}
 		// 读网络
-		opType, payload, err := loop.conn.ReadMessage()
-		if err == nil {
-			switch opType {
-			case websocket.TextMessage, websocket.BinaryMessage:
-				// 提交已读数据
-				if len(payload) > 0 {
-					// 等待读处理
-					select {
-					case loop.readChan <- payload:
-					case <-loop.cancelCtx.Done():
-						return
-					}
-					// 等待读处理结束
-					select {
-					case <-loop.readDone:
-					case <-loop.cancelCtx.Done():
-						return
-					}
-				}
-			case websocket.CloseMessage:
-			case websocket.PingMessage:
-			case websocket.PongMessage:
-			default:
-				plog.Warn("unknown ws opCode:", pfield.Int("opCode", opType))
-			}
-		}
+		opType, payload, err := conn.ReadMessage()
 		if err != nil {
-			if sErr := loop.stop(context.Background(), err); sErr != nil && !errors.Is(sErr, pnet.ErrClosedClient) {
-				plog.Error("close conn error:", pfield.Error(sErr))
+			select {
+			case closeChan <- err:
+			case <-cancelCtx.Done():
 			}
 			return
 		}
+		conn.UpdateReadTime()
+		switch opType {
+		case websocket.TextMessage, websocket.BinaryMessage:
+			// 提交已读数据
+			if len(payload) > 0 {
+				// 等待读处理
+				select {
+				case readChan <- payload:
+				case <-cancelCtx.Done():
+					return
+				}
+				// 等待读处理结束
+				select {
+				case <-readDone:
+				case <-cancelCtx.Done():
+					return
+				}
+			}
+		case websocket.CloseMessage:
+		case websocket.PingMessage:
+		case websocket.PongMessage:
+		default:
+			plog.Warn("unknown ws opCode:", pfield.Int("opCode", opType))
+		}
 	} // end of for
 }
 
@@ -193,6 +217,26 @@ func (loop *eventLoop) asyncWritev(bs [][]byte, callback func(c *Conn, err error
 	return nil
 }
 
+// checkIdle
+//
+//	@Description: 检查连接是否空闲，发送心跳或关闭超时连接
+//	@receiver loop
+//	@param now
+//	@return bool 是否已关闭
+func (loop *eventLoop) checkIdle(now time.Time) bool {
+	heartbeat := loop.client.Heartbeat
+	switch loop.conn.CheckIdle(heartbeat, now) {
+	case session.IdleHeartbeat:
+		if err := loop.conn.WriteControl(websocket.PingMessage, nil, now.Add(time.Second)); err != nil {
+			plog.Error("write ping error:", pfield.Error(err))
+		}
+	case session.IdleClose:
+		loop._stop(pnet.ErrIdleTimeout)
+		return true
+	}
+	return false
+}
+
 // start
 //
 //	@Description: 开启事件处理循环
@@ -208,9 +252,20 @@ func (loop *eventLoop) start(conn *Conn) error {
 	loop.readDone = make(chan struct{})
 	loop.writeChan = make(chan writeEvent, loop.client.WriteQueueCap)
 	loop.closeChan = make(chan error)
+	if heartbeat := loop.client.Heartbeat; heartbeat != nil && heartbeat.Interval > 0 {
+		loop.ticker = time.NewTicker(heartbeat.TickInterval())
+		loop.tickChan = loop.ticker.C
+	}
 	loop.cancelCtx, loop.cancelFunc = context.WithCancel(context.Background())
+	state := &readState{
+		conn:      conn,
+		readChan:  loop.readChan,
+		readDone:  loop.readDone,
+		closeChan: loop.closeChan,
+		cancelCtx: loop.cancelCtx,
+	}
 	go loop.run(true)
-	go loop.readConn()
+	go loop.readConn(state)
 	return nil
 }
 
@@ -247,4 +302,15 @@ func (loop *eventLoop) _stop(reason error) {
 	// 即便连接关闭出错，也继续走关闭逻辑
 	loop.handler.OnClose(loop.conn, reason)
 	loop.cancelFunc()
+	if loop.ticker != nil {
+		loop.ticker.Stop()
+		loop.ticker = nil
+		loop.tickChan = nil
+	}
+	// 非主动关闭时重连，重连期间保留会话属性
+	if loop.client.Reconnect != nil && !loop.client.closing.Load() {
+		go loop.client.reconnect(reason)
+	} else {
+		loop.client.Attributes().Clear()
+	}
 }
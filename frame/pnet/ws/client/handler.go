@@ -1,8 +1,11 @@
 package client
 
 import (
+	"errors"
+	"github.com/gorilla/websocket"
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 )
 
 type action uint32
@@ -29,7 +32,7 @@ func (handler *eventHandler) OnOpen(conn *Conn) (action action) {
 
 func (handler *eventHandler) OnClose(conn *Conn, err error) (action action) {
 	conn.ToClosed(err)
-	conn.client.listener.OnClosed(conn.client)
+	conn.client.listener.OnClosed(conn.client, closeReason(conn))
 	plog.Debug("close ws-client connecting:",
 		pfield.String("client", conn.client.Name),
 		pfield.Uint64("conn", conn.Hash()))
@@ -40,9 +43,28 @@ func (handler *eventHandler) OnTraffic(conn *Conn, data []byte) (action action)
 	msg, err := conn.client.codec.Decode(data)
 	if err != nil {
 		plog.Error("decode error", pfield.Error(err))
+		conn.ToClosed(session.NewCloseReason(session.CloseProtocol, session.CloseByLocal, err))
 		action = actionClose
 		return
 	}
 	_ = conn.client.listener.OnReceive(conn.client, msg, len(data))
 	return
 }
+
+// closeReason
+//
+//	@Description: 获取连接的结构化关闭原因，对端的关闭帧按关闭码分类
+//	@param conn 已关闭的连接
+//	@return *session.CloseReason
+func closeReason(conn *Conn) *session.CloseReason {
+	_, err := conn.IsClosed()
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		category := session.WebSocketCloseCategory(closeErr.Code, true)
+		return session.NewCloseReason(category, session.CloseByPeer, closeErr)
+	}
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return session.NewCloseReason(session.CloseLimited, session.CloseByLocal, err)
+	}
+	return session.ResolveCloseReason(err)
+}
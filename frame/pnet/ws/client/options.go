@@ -1,5 +1,12 @@
 package client
 
+import (
+	"crypto/tls"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"net/http"
+	"time"
+)
+
 func newOptions(opts ...Option) *Options {
 	options := &Options{
 		WriteQueueCap: 100,
@@ -16,6 +23,32 @@ type Options struct {
 	WriteQueueCap int
 	// 最大消息长度
 	MaxMessageLength int64
+	// 心跳与空闲检测配置，为nil时不检测
+	Heartbeat *session.HeartbeatOptions
+	// 断线重连配置，为nil时不重连
+	Reconnect *session.ReconnectOptions
+	// TLS配置，用于wss连接
+	TLSConfig *tls.Config
+	// 握手请求头
+	Header http.Header
+	// 握手请求使用的cookie
+	CookieJar http.CookieJar
+	// 请求的子协议，按优先级排列
+	Subprotocols []string
+	// 握手超时，为0时不限制
+	HandshakeTimeout time.Duration
+	// 压缩配置，为nil时不协商压缩
+	Compression *CompressionOptions
+}
+
+// CompressionOptions
+//
+//	@Description: permessage-deflate压缩配置，客户端两个方向均不保留压缩上下文
+type CompressionOptions struct {
+	// 压缩级别，同 compress/flate
+	Level int
+	// 消息长度达到该值才压缩
+	Threshold int
 }
 
 type Option func(*Options)
@@ -37,3 +70,85 @@ func WithMaxMessageLength(value int64) Option {
 		options.MaxMessageLength = value
 	}
 }
+
+// WithHeartbeat
+//
+//	@Description: 开启心跳与空闲检测，心跳使用ping帧
+//	@param interval 未收到数据多久后发送心跳
+//	@param maxMissed 连续未响应的心跳次数上限，小于1时按1处理
+//	@return Option
+func WithHeartbeat(interval time.Duration, maxMissed int) Option {
+	return func(options *Options) {
+		options.Heartbeat = &session.HeartbeatOptions{
+			Interval:  interval,
+			MaxMissed: maxMissed,
+		}
+	}
+}
+
+// WithReconnect
+//
+//	@Description: 开启断线重连，主动关闭时不重连
+//	@param value 重连配置
+//	@return Option
+func WithReconnect(value *session.ReconnectOptions) Option {
+	return func(options *Options) {
+		options.Reconnect = value
+	}
+}
+
+// WithTLSConfig
+//
+//	@Description: 设置wss连接使用的TLS配置
+//	@param config
+//	@return Option
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.TLSConfig = config
+	}
+}
+
+// WithHeader
+//
+//	@Description: 设置握手请求头，如鉴权信息
+//	@param header
+//	@return Option
+func WithHeader(header http.Header) Option {
+	return func(options *Options) {
+		options.Header = header
+	}
+}
+
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(options *Options) {
+		options.CookieJar = jar
+	}
+}
+
+// WithSubprotocols
+//
+//	@Description: 设置请求的子协议，服务端选中的子协议可通过 Client.Handshake 获取
+//	@param protocols
+//	@return Option
+func WithSubprotocols(protocols ...string) Option {
+	return func(options *Options) {
+		options.Subprotocols = protocols
+	}
+}
+
+func WithHandshakeTimeout(value time.Duration) Option {
+	return func(options *Options) {
+		options.HandshakeTimeout = value
+	}
+}
+
+// WithCompression
+//
+//	@Description: 开启permessage-deflate压缩协商
+//	@param value 压缩配置
+//	@return Option
+func WithCompression(value *CompressionOptions) Option {
+	return func(options *Options) {
+		options.Compression = value
+	}
+}
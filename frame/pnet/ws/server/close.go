@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+)
+
+// CloseError
+//
+//	@Description: websocket关闭原因，可在 Listener.OnClosed 中通过 session.Conn.IsClosed 获取
+type CloseError struct {
+	// 关闭码，对端未携带时为 ws.StatusNoStatusRcvd
+	Code ws.StatusCode
+	// 关闭原因
+	Reason string
+	// 是否由对端发起关闭
+	Remote bool
+}
+
+func (err *CloseError) Error() string {
+	if err.Remote {
+		return fmt.Sprintf("websocket closed by peer: code=%d reason=%s", err.Code, err.Reason)
+	}
+	return fmt.Sprintf("websocket closed: code=%d reason=%s", err.Code, err.Reason)
+}
+
+// Session
+//
+//	@Description: websocket会话，可由 session.Session 类型断言得到
+type Session interface {
+	session.Session
+
+	// CloseWithStatus
+	//	@Description: 发送关闭帧后关闭会话
+	//	@param code 关闭码
+	//	@param reason 关闭原因，超出控制帧长度的部分会被截断
+	//	@return error
+	//
+	CloseWithStatus(code ws.StatusCode, reason string) error
+}
+
+func (sess *svrSession) CloseWithStatus(code ws.StatusCode, reason string) error {
+	if err := ws.CheckCloseFrameData(code, reason); err != nil {
+		return err
+	}
+	if !sess.conn.ToClosed(&CloseError{Code: code, Reason: reason}) {
+		return pnet.ErrClosedConn
+	}
+	if sess.conn.upgraded.Load() {
+		data, err := ws.CompileFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(code, reason)))
+		if err != nil {
+			return err
+		}
+		// 异步写与关闭按提交顺序执行，关闭前会写出关闭帧
+		if err = sess.conn.Conn.AsyncWrite(data, nil); err != nil {
+			plog.Error("write close frame error:", pfield.Uint64("conn", sess.conn.Hash()), pfield.Error(err))
+		}
+	}
+	return sess.conn.Close()
+}
+
+// onCloseFrame
+//
+//	@Description: 处理对端的关闭帧，记录关闭原因并回应关闭帧
+//	@receiver codec
+//	@param conn
+//	@param payload 关闭帧数据
+func (codec *wsCodec) onCloseFrame(conn *Conn, payload []byte) {
+	if closed, _ := conn.IsClosed(); closed {
+		// 本端已发起关闭
+		return
+	}
+	var body []byte
+	closeErr := &CloseError{Code: ws.StatusNoStatusRcvd, Remote: true}
+	if len(payload) > 0 {
+		code, reason := ws.ParseCloseFrameData(payload)
+		if err := ws.CheckCloseFrameData(code, reason); err != nil {
+			plog.Debug("invalid close frame:", pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
+			closeErr = &CloseError{Code: ws.StatusProtocolError, Reason: err.Error()}
+			body = ws.NewCloseFrameBody(ws.StatusProtocolError, "")
+		} else {
+			closeErr.Code, closeErr.Reason = code, reason
+			body = ws.NewCloseFrameBody(code, "")
+		}
+	}
+	conn.ToClosed(closeErr)
+	if err := wsutil.WriteServerMessage(conn, ws.OpClose, body); err != nil {
+		plog.Debug("write close frame error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
+	}
+}
+
+// protocolError
+//
+//	@Description: 构造协议错误的关闭原因
+//	@param reason
+//	@return *CloseError
+func protocolError(reason string) *CloseError {
+	return &CloseError{Code: ws.StatusProtocolError, Reason: reason}
+}
+
+// errMessageTooBig
+//
+//	@Description: 构造消息超长的关闭原因
+//	@return *CloseError
+func errMessageTooBig() *CloseError {
+	return &CloseError{Code: ws.StatusMessageTooBig, Reason: "message too big"}
+}
+
+// failConnection
+//
+//	@Description: 读取出错时以对应关闭码关闭连接，非协议错误按 ws.StatusProtocolError 处理
+//	@receiver codec
+//	@param conn
+//	@param err 读取错误
+func (codec *wsCodec) failConnection(conn *Conn, err error) {
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) {
+		closeErr = protocolError(err.Error())
+	}
+	if !conn.ToClosed(closeErr) {
+		return
+	}
+	body := ws.NewCloseFrameBody(closeErr.Code, closeErr.Reason)
+	if wErr := wsutil.WriteServerMessage(conn, ws.OpClose, body); wErr != nil {
+		plog.Debug("write close frame error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(wErr))
+	}
+}
+
+// closeSlow
+//
+//	@Description: 关闭慢速会话
+//	@receiver sess
+//	@return error
+func (sess *svrSession) closeSlow() error {
+	return sess.CloseWithStatus(ws.StatusPolicyViolation, pnet.ErrSlowConsumer.Error())
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 	"github.com/meow-pad/persian/errdef"
 	"github.com/meow-pad/persian/frame/plog"
@@ -11,6 +12,7 @@ import (
 	"github.com/meow-pad/persian/frame/pnet/message"
 	"github.com/panjf2000/gnet/v2"
 	"io"
+	"unicode/utf8"
 )
 
 type wsReadWrite struct {
@@ -25,27 +27,77 @@ func newWsCodec(msgCodec message.Codec) (*wsCodec, error) {
 	return &wsCodec{msgCodec: msgCodec}, nil
 }
 
+// FrameType
+//
+//	@Description: 发送消息使用的数据帧类型
+type FrameType uint8
+
+const (
+	// FrameDefault 使用服务配置的帧类型
+	FrameDefault FrameType = iota
+	// FrameBinary 二进制帧
+	FrameBinary
+	// FrameText 文本帧，编码结果需为合法的UTF-8
+	FrameText
+)
+
+// FrameTyper
+//
+//	@Description: 消息可实现该接口以单独指定发送使用的帧类型
+type FrameTyper interface {
+	FrameType() FrameType
+}
+
 type wsCodec struct {
 	msgCodec message.Codec
+	// 默认是否使用文本帧
+	textFrame bool
+	// 最大消息长度，为0时不限制
+	maxMessageLength int64
+	// 握手处理函数
+	upgradeHandler UpgradeHandler
+	// 压缩器，未开启压缩时为nil
+	deflater *deflater
 }
 
-func (codec *wsCodec) Encode(msg any) (out []byte, err error) {
-	out, err = codec.msgCodec.Encode(msg)
+// newFrame
+//
+//	@Description: 编码消息，帧数据在发送时按连接的压缩协商结果生成
+//	@receiver codec
+//	@param msg
+//	@return *wsFrame
+//	@return error
+func (codec *wsCodec) newFrame(msg any) (*wsFrame, error) {
+	payload, err := codec.msgCodec.Encode(msg)
 	if err != nil {
-		return
+		return nil, err
 	}
-	buf := bytes.NewBuffer(make([]byte, 0, len(out)+ws.MaxHeaderSize))
-	err = wsutil.WriteServerMessage(buf, ws.OpBinary, out)
-	if err != nil {
-		out = nil
-		return
+	return &wsFrame{codec: codec, opCode: codec.opCode(msg), payload: payload}, nil
+}
+
+// opCode
+//
+//	@Description: 获取消息使用的帧类型
+//	@receiver codec
+//	@param msg
+//	@return ws.OpCode
+func (codec *wsCodec) opCode(msg any) ws.OpCode {
+	if typer, ok := msg.(FrameTyper); ok {
+		switch typer.FrameType() {
+		case FrameText:
+			return ws.OpText
+		case FrameBinary:
+			return ws.OpBinary
+		}
 	}
-	out = buf.Bytes()
-	return
+	if codec.textFrame {
+		return ws.OpText
+	}
+	return ws.OpBinary
 }
 
 func (codec *wsCodec) Decode(conn *Conn) ([]any, int, gnet.Action) {
-	if !conn.upgraded {
+	if !conn.upgraded.Load() {
 		ok, action := codec.upgrade(conn)
 		if action == gnet.Close {
 			return nil, 0, gnet.Close
@@ -57,10 +109,27 @@ func (codec *wsCodec) Decode(conn *Conn) ([]any, int, gnet.Action) {
 	if conn.InboundBuffered() <= 0 {
 		return nil, 0, gnet.None
 	}
-	wsMsgArr, err := codec.readWsMessages(conn)
-	if err != nil {
-		plog.Error("read ws messages error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
+	wsMsgArr, readErr := codec.readWsMessages(conn)
+	msgArr, totalLen, action := codec.handleWsMessages(conn, wsMsgArr)
+	if readErr != nil && action != gnet.Close {
+		// 出错前已收全的消息仍正常处理
+		plog.Error("read ws messages error:", pfield.Uint64("conn", conn.Hash()), pfield.Error(readErr))
+		codec.failConnection(conn, readErr)
+		action = gnet.Close
 	}
+	return msgArr, totalLen, action
+}
+
+// handleWsMessages
+//
+//	@Description: 解码数据消息并处理控制消息
+//	@receiver codec
+//	@param conn
+//	@param wsMsgArr
+//	@return []any 解码后的消息
+//	@return int 消息总长度
+//	@return gnet.Action
+func (codec *wsCodec) handleWsMessages(conn *Conn, wsMsgArr []wsutil.Message) ([]any, int, gnet.Action) {
 	arrLen := len(wsMsgArr)
 	if arrLen <= 0 {
 		return nil, 0, gnet.None
@@ -78,13 +147,11 @@ func (codec *wsCodec) Decode(conn *Conn) ([]any, int, gnet.Action) {
 				msgArr = append(msgArr, msg)
 			}
 		case ws.OpClose:
-			err = wsutil.WriteServerMessage(conn, ws.OpClose, nil)
-			if err != nil {
-				return nil, 0, gnet.Close
-			}
+			// 回应关闭帧后关闭连接，之前收到的消息仍正常处理
+			codec.onCloseFrame(conn, wsMsg.Payload)
+			return msgArr, totalLen, gnet.Close
 		case ws.OpPing:
-			err = wsutil.WriteServerMessage(conn, ws.OpPong, nil)
-			if err != nil {
+			if err := wsutil.WriteServerMessage(conn, ws.OpPong, wsMsg.Payload); err != nil {
 				return nil, 0, gnet.Close
 			}
 		case ws.OpPong:
@@ -113,7 +180,14 @@ func (codec *wsCodec) upgrade(conn *Conn) (ok bool, action gnet.Action) {
 	}
 	tmpReader := bytes.NewReader(buf)
 	oldLen := tmpReader.Len()
-	hs, err := ws.Upgrade(wsReadWrite{tmpReader, conn})
+	sess, _ := conn.Context().(*svrSession)
+	upgrader := newUpgrader(codec.upgradeHandler, sess)
+	var extension *wsflate.Extension
+	if codec.deflater != nil {
+		extension = codec.deflater.extension()
+		upgrader.Negotiate = negotiate(extension)
+	}
+	hs, err := upgrader.Upgrade(wsReadWrite{tmpReader, conn})
 	skipN := oldLen - tmpReader.Len()
 	if err != nil {
 		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
@@ -129,83 +203,189 @@ func (codec *wsCodec) upgrade(conn *Conn) (ok bool, action gnet.Action) {
 		action = gnet.Close
 		return
 	}
+	if extension != nil {
+		if params, accepted := extension.Accepted(); accepted {
+			conn.inflater = newInflater(params)
+			conn.compress.Store(true)
+		}
+	}
 	ok = true
-	conn.upgraded = true
+	conn.upgraded.Store(true)
 	plog.Debug("upgraded websocket protocol",
 		pfield.Uint64("conn", conn.Hash()), pfield.Any("handshake", hs))
 	return
 }
 
+// readWsMessages
+//
+//	@Description: 读取已收全的消息，分片消息在收到结束帧后合并，分片间穿插的控制帧立即返回
+//	@receiver codec
+//	@param conn
+//	@return messages
+//	@return err 协议错误时为 *CloseError
 func (codec *wsCodec) readWsMessages(conn *Conn) (messages []wsutil.Message, err error) {
 	msgBuf := &conn.wsMsgBuf
 	for {
 		if msgBuf.curHeader == nil {
-			if conn.InboundBuffered() < ws.MinHeaderSize {
-				//头长度至少是2
-				return
-			}
 			var head ws.Header
-			if conn.InboundBuffered() >= ws.MaxHeaderSize {
-				head, err = ws.ReadHeader(conn)
-				if err != nil {
-					return
-				}
-			} else {
-				//有可能不完整，构建新的 reader 读取 head 读取成功才实际对 in 进行读操作
-				var pBuf []byte
-				pBuf, err = conn.Peek(conn.InboundBuffered())
-				if err != nil {
-					return
-				}
-				tmpReader := bytes.NewReader(pBuf)
-				oldLen := tmpReader.Len()
-				head, err = ws.ReadHeader(tmpReader)
-				skipN := oldLen - tmpReader.Len()
-				if err != nil {
-					if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
-						//数据不完整
-						err = nil
-						return
-					}
-					if _, dErr := conn.Discard(skipN); dErr != nil {
-						plog.Error("discard bytes error:",
-							pfield.Uint64("conn", conn.Hash()), pfield.Error(err))
-					}
-					return
-				}
-				if _, err = conn.Discard(skipN); err != nil {
-					return
-				}
+			var ok bool
+			if head, ok, err = readHeader(conn); err != nil || !ok {
+				return
 			}
-
-			msgBuf.curHeader = &head
-			err = ws.WriteHeader(&msgBuf.inboundCached, head)
-			if err != nil {
+			if err = codec.checkHeader(conn, head); err != nil {
 				return
 			}
+			msgBuf.curHeader = &head
+		}
+		head := msgBuf.curHeader
+		if (int64)(conn.InboundBuffered()) < head.Length {
+			//数据不完整
+			return
+		}
+		payload := make([]byte, head.Length)
+		if _, err = io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		msgBuf.curHeader = nil
+		ws.Cipher(payload, head.Mask, 0)
+		if head.OpCode.IsControl() {
+			messages = append(messages, wsutil.Message{OpCode: head.OpCode, Payload: payload})
+			continue
 		}
-		dataLen := msgBuf.curHeader.Length
-		if dataLen > 0 {
-			if (int64)(conn.InboundBuffered()) >= dataLen {
-				_, err = io.CopyN(&msgBuf.inboundCached, conn, dataLen)
-				if err != nil {
+		if msgBuf.firstHeader == nil {
+			if head.Fin {
+				// 未分片的消息
+				var msg wsutil.Message
+				if msg, err = codec.completeMessage(conn, *head, payload); err != nil {
 					return
 				}
-			} else {
-				//数据不完整
+				messages = append(messages, msg)
+				continue
+			}
+			msgBuf.firstHeader = head
+		}
+		msgBuf.payload.Write(payload)
+		if head.Fin {
+			first := *msgBuf.firstHeader
+			payload = bytes.Clone(msgBuf.payload.Bytes())
+			msgBuf.firstHeader = nil
+			msgBuf.payload.Reset()
+			var msg wsutil.Message
+			if msg, err = codec.completeMessage(conn, first, payload); err != nil {
 				return
 			}
+			messages = append(messages, msg)
 		}
-		if msgBuf.curHeader.Fin {
-			//当前 header 已经是一个完整消息
-			messages, err = wsutil.ReadClientMessage(&msgBuf.inboundCached, messages)
-			if err != nil {
-				return nil, err
+	}
+}
+
+// readHeader
+//
+//	@Description: 读取帧头，数据不完整时不消耗输入
+//	@param conn
+//	@return head
+//	@return ok 是否读取到完整的帧头
+//	@return err
+func readHeader(conn *Conn) (head ws.Header, ok bool, err error) {
+	if conn.InboundBuffered() < ws.MinHeaderSize {
+		//头长度至少是2
+		return
+	}
+	if conn.InboundBuffered() >= ws.MaxHeaderSize {
+		head, err = ws.ReadHeader(conn)
+		return head, err == nil, err
+	}
+	//有可能不完整，构建新的 reader 读取 head 读取成功才实际对 in 进行读操作
+	var pBuf []byte
+	pBuf, err = conn.Peek(conn.InboundBuffered())
+	if err != nil {
+		return
+	}
+	tmpReader := bytes.NewReader(pBuf)
+	oldLen := tmpReader.Len()
+	head, err = ws.ReadHeader(tmpReader)
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			//数据不完整
+			err = nil
+		}
+		return
+	}
+	if _, err = conn.Discard(oldLen - tmpReader.Len()); err != nil {
+		return
+	}
+	return head, true, nil
+}
+
+// checkHeader
+//
+//	@Description: 按协议校验客户端的帧头及消息长度
+//	@receiver codec
+//	@param conn
+//	@param head
+//	@return error
+func (codec *wsCodec) checkHeader(conn *Conn, head ws.Header) error {
+	msgBuf := &conn.wsMsgBuf
+	if !head.Masked {
+		return protocolError("client frame is not masked")
+	}
+	switch {
+	case head.OpCode.IsReserved():
+		return protocolError("reserved opcode")
+	case head.OpCode.IsControl():
+		if !head.Fin {
+			return protocolError("fragmented control frame")
+		}
+		if head.Length > ws.MaxControlFramePayloadSize {
+			return protocolError("control frame payload is too long")
+		}
+		if head.Rsv != 0 {
+			return protocolError("non-zero rsv bits")
+		}
+		return nil
+	case head.OpCode == ws.OpContinuation:
+		if msgBuf.firstHeader == nil {
+			return protocolError("unexpected continuation frame")
+		}
+		if head.Rsv != 0 {
+			return protocolError("non-zero rsv bits")
+		}
+	default:
+		if msgBuf.firstHeader != nil {
+			return protocolError("expected continuation frame")
+		}
+		if head.Rsv2() || head.Rsv3() || (head.Rsv1() && conn.inflater == nil) {
+			return protocolError("non-zero rsv bits")
+		}
+	}
+	if maxLen := codec.maxMessageLength; maxLen > 0 && int64(msgBuf.payload.Len())+head.Length > maxLen {
+		return errMessageTooBig()
+	}
+	return nil
+}
+
+// completeMessage
+//
+//	@Description: 解压完整的消息并校验文本消息的UTF-8编码
+//	@receiver codec
+//	@param conn
+//	@param first 消息的首帧头
+//	@param payload 消息数据
+//	@return wsutil.Message
+//	@return error
+func (codec *wsCodec) completeMessage(conn *Conn, first ws.Header, payload []byte) (wsutil.Message, error) {
+	if first.Rsv1() {
+		var err error
+		if payload, err = conn.inflater.inflate(payload, codec.maxMessageLength); err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				return wsutil.Message{}, err
 			}
-			msgBuf.inboundCached.Reset()
-		} else {
-			plog.Debug("data is split into multiple frames", pfield.Uint64("conn", conn.Hash()))
+			return wsutil.Message{}, &CloseError{Code: ws.StatusInvalidFramePayloadData, Reason: err.Error()}
 		}
-		msgBuf.curHeader = nil
 	}
+	if first.OpCode == ws.OpText && !utf8.Valid(payload) {
+		return wsutil.Message{}, &CloseError{Code: ws.StatusInvalidFramePayloadData, Reason: "invalid utf8 text"}
+	}
+	return wsutil.Message{OpCode: first.OpCode, Payload: payload}, nil
 }
@@ -5,12 +5,17 @@ import (
 	"github.com/gobwas/ws"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/panjf2000/gnet/v2"
+	"net"
+	"sync/atomic"
 )
 
 type wsMessageBuf struct {
-	firstHeader   *ws.Header
-	curHeader     *ws.Header
-	inboundCached bytes.Buffer
+	// 分片消息的首帧头，无未完成的分片消息时为nil
+	firstHeader *ws.Header
+	// 数据未收全的帧头
+	curHeader *ws.Header
+	// 分片消息已收到的数据
+	payload bytes.Buffer
 }
 
 func newConn(gConn gnet.Conn) (*Conn, error) {
@@ -26,8 +31,18 @@ type Conn struct {
 	gnet.Conn
 	session.BaseConn
 
-	upgraded bool
+	upgraded atomic.Bool
 	wsMsgBuf wsMessageBuf
+	// 是否已协商压缩
+	compress atomic.Bool
+	// 解压器，仅在事件循环中访问
+	inflater *inflater
+	// 是否等待读取PROXY协议头，仅在事件循环中访问
+	proxyPending bool
+	// 是否已占用连接数，仅在事件循环中访问
+	counted bool
+	// 是否已通知会话开启，仅在事件循环中访问
+	opened bool
 }
 
 func (conn *Conn) Init(gConn gnet.Conn) error {
@@ -35,6 +50,20 @@ func (conn *Conn) Init(gConn gnet.Conn) error {
 	return conn.BaseConn.Init(gConn, false)
 }
 
+func (conn *Conn) RemoteAddr() net.Addr {
+	if addr := conn.ProxiedAddr(true); addr != nil {
+		return addr
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *Conn) LocalAddr() net.Addr {
+	if addr := conn.ProxiedAddr(false); addr != nil {
+		return addr
+	}
+	return conn.Conn.LocalAddr()
+}
+
 func (conn *Conn) AsyncWrite(buf []byte, callback func(c session.Conn, err error) error) (err error) {
 	err = conn.Conn.AsyncWrite(buf, func(c gnet.Conn, err error) error {
 		return callback(conn, err)
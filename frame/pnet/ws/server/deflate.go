@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"io"
+	"sync"
+)
+
+// deflateTail
+//
+//	@Description: 压缩数据省略的同步刷新尾部，解压时补上并追加一个空的结束块
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// newDeflater
+//
+//	@Description: 构造压缩器，服务端始终不保留压缩上下文，压缩结果可在多个连接间共享
+//	@param options 压缩配置
+//	@return *deflater
+//	@return error
+func newDeflater(options *CompressionOptions) (*deflater, error) {
+	// 提前校验压缩级别
+	if _, err := flate.NewWriter(io.Discard, options.Level); err != nil {
+		return nil, err
+	}
+	deflater := &deflater{CompressionOptions: options}
+	deflater.writers.New = func() any {
+		writer, _ := flate.NewWriter(io.Discard, options.Level)
+		return writer
+	}
+	return deflater, nil
+}
+
+type deflater struct {
+	*CompressionOptions
+	writers sync.Pool
+}
+
+// extension
+//
+//	@Description: 构造握手协商使用的扩展
+//	@receiver deflater
+//	@return *wsflate.Extension
+func (deflater *deflater) extension() *wsflate.Extension {
+	return &wsflate.Extension{
+		Parameters: wsflate.Parameters{
+			ServerNoContextTakeover: true,
+			ClientNoContextTakeover: deflater.ClientNoContextTakeover,
+		},
+	}
+}
+
+// negotiate
+//
+//	@Description: 协商压缩参数，客户端声明不保留压缩上下文时在响应中确认
+//		部分客户端（如gorilla）要求响应中包含该参数
+//	@param extension
+//	@return func(httphead.Option) (httphead.Option, error)
+func negotiate(extension *wsflate.Extension) func(httphead.Option) (httphead.Option, error) {
+	return func(opt httphead.Option) (httphead.Option, error) {
+		accept, err := extension.Negotiate(opt)
+		if err != nil || accept.Size() <= 0 {
+			return accept, err
+		}
+		if params, _ := extension.Accepted(); params.ClientNoContextTakeover {
+			want := extension.Parameters
+			want.ClientNoContextTakeover = true
+			accept = want.Option()
+		}
+		return accept, nil
+	}
+}
+
+// compressible
+//
+//	@Description: 是否需要压缩
+//	@receiver deflater
+//	@param size 消息长度
+//	@return bool
+func (deflater *deflater) compressible(size int) bool {
+	return size >= deflater.Threshold
+}
+
+// deflate
+//
+//	@Description: 压缩消息并去掉同步刷新尾部
+//	@receiver deflater
+//	@param payload
+//	@return []byte
+//	@return error
+func (deflater *deflater) deflate(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := deflater.writers.Get().(*flate.Writer)
+	defer deflater.writers.Put(writer)
+	writer.Reset(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	out := buf.Bytes()
+	if n := len(out); n >= 4 && bytes.Equal(out[n-4:], deflateTail[:4]) {
+		out = out[:n-4]
+	}
+	return out, nil
+}
+
+// newInflater
+//
+//	@Description: 构造连接的解压器
+//	@param params 协商结果
+//	@return *inflater
+func newInflater(params wsflate.Parameters) *inflater {
+	return &inflater{takeover: !params.ClientNoContextTakeover}
+}
+
+// inflater
+//
+//	@Description: 连接的解压器，仅在事件循环中访问
+type inflater struct {
+	// 是否保留解压上下文
+	takeover bool
+	// 最近解压的数据，作为下一条消息的字典
+	window []byte
+}
+
+// inflate
+//
+//	@Description: 解压消息
+//	@receiver inflater
+//	@param payload 压缩数据
+//	@param limit 解压后长度上限，为0时不限制
+//	@return []byte
+//	@return error
+func (inflater *inflater) inflate(payload []byte, limit int64) ([]byte, error) {
+	reader := flate.NewReaderDict(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)),
+		inflater.window)
+	defer func() { _ = reader.Close() }()
+	var src io.Reader = reader
+	if limit > 0 {
+		src = io.LimitReader(reader, limit+1)
+	}
+	out, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, errMessageTooBig()
+	}
+	if inflater.takeover {
+		inflater.slide(out)
+	}
+	return out, nil
+}
+
+// slide
+//
+//	@Description: 保留最近 wsflate.MaxLZ77WindowSize 字节的数据作为字典
+//	@receiver inflater
+//	@param out
+func (inflater *inflater) slide(out []byte) {
+	if len(out) >= wsflate.MaxLZ77WindowSize {
+		inflater.window = append(inflater.window[:0], out[len(out)-wsflate.MaxLZ77WindowSize:]...)
+		return
+	}
+	if overflow := len(inflater.window) + len(out) - wsflate.MaxLZ77WindowSize; overflow > 0 {
+		inflater.window = append(inflater.window[:0], inflater.window[overflow:]...)
+	}
+	inflater.window = append(inflater.window, out...)
+}
+
+// wsFrame
+//
+//	@Description: 已编码的消息，按连接是否启用压缩生成对应的帧，帧数据可在多个连接间共享
+//		不是协程安全的
+type wsFrame struct {
+	codec    *wsCodec
+	opCode   ws.OpCode
+	payload  []byte
+	plain    []byte
+	deflated []byte
+}
+
+// data
+//
+//	@Description: 获取发往连接的帧数据
+//	@receiver frame
+//	@param conn
+//	@return []byte
+//	@return error
+func (frame *wsFrame) data(conn *Conn) (out []byte, err error) {
+	deflater := frame.codec.deflater
+	if deflater != nil && conn.compress.Load() && deflater.compressible(len(frame.payload)) {
+		if frame.deflated == nil {
+			var compressed []byte
+			if compressed, err = deflater.deflate(frame.payload); err != nil {
+				return
+			}
+			frame.deflated, err = writeServerFrame(frame.opCode, compressed, true)
+		}
+		return frame.deflated, err
+	}
+	if frame.plain == nil {
+		frame.plain, err = writeServerFrame(frame.opCode, frame.payload, false)
+	}
+	return frame.plain, err
+}
+
+// writeServerFrame
+//
+//	@Description: 构造服务端数据帧
+//	@param opCode 帧类型
+//	@param payload
+//	@param compressed 是否设置压缩标记
+//	@return []byte
+//	@return error
+func writeServerFrame(opCode ws.OpCode, payload []byte, compressed bool) ([]byte, error) {
+	header := ws.Header{Fin: true, OpCode: opCode, Length: int64(len(payload))}
+	if compressed {
+		header.Rsv = ws.Rsv(true, false, false)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(payload)+ws.MaxHeaderSize))
+	if err := ws.WriteHeader(buf, header); err != nil {
+		return nil, err
+	}
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
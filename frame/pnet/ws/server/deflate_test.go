@@ -0,0 +1,48 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestInflater_ContextTakeover(t *testing.T) {
+	should := require.New(t)
+	// 模拟保留压缩上下文的客户端
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestCompression)
+	should.Nil(err)
+	compress := func(msg string) []byte {
+		buf.Reset()
+		_, wErr := writer.Write([]byte(msg))
+		should.Nil(wErr)
+		should.Nil(writer.Flush())
+		out := bytes.Clone(buf.Bytes())
+		return out[:len(out)-4]
+	}
+	inflater := newInflater(wsflate.Parameters{})
+	messages := []string{strings.Repeat("persian", 100), strings.Repeat("persian", 100), "meow"}
+	for _, msg := range messages {
+		out, err := inflater.inflate(compress(msg), 0)
+		should.Nil(err)
+		should.Equal(msg, string(out))
+	}
+	should.LessOrEqual(len(inflater.window), wsflate.MaxLZ77WindowSize)
+
+	deflater, err := newDeflater(&CompressionOptions{Level: flate.BestSpeed, Threshold: 16})
+	should.Nil(err)
+	should.False(deflater.compressible(8))
+	compressed, err := deflater.deflate([]byte(messages[0]))
+	should.Nil(err)
+	should.Less(len(compressed), len(messages[0]))
+	out, err := newInflater(wsflate.Parameters{ClientNoContextTakeover: true}).inflate(compressed, 0)
+	should.Nil(err)
+	should.Equal(messages[0], string(out))
+	// 解压后超出长度上限
+	_, err = newInflater(wsflate.Parameters{ClientNoContextTakeover: true}).inflate(compressed, 64)
+	should.Equal(&CloseError{Code: ws.StatusMessageTooBig, Reason: "message too big"}, err)
+}
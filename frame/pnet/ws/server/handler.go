@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/panjf2000/gnet/v2"
 	"reflect"
 	"time"
@@ -10,6 +11,8 @@ import (
 
 type eventHandler struct {
 	server *Server
+	// 下次检查会话有效性的时间
+	nextCheckTime time.Time
 }
 
 func (handler *eventHandler) OnBoot(engine gnet.Engine) (action gnet.Action) {
@@ -21,6 +24,11 @@ func (handler *eventHandler) OnShutdown(_ gnet.Engine) {
 }
 
 func (handler *eventHandler) OnOpen(gConn gnet.Conn) (out []byte, action gnet.Action) {
+	if handler.server.draining.Load() {
+		// 排空时不再接受新连接
+		action = gnet.Close
+		return
+	}
 	conn, err := newConn(gConn)
 	if err != nil {
 		plog.Error("create connection error:", pfield.Error(err))
@@ -41,10 +49,38 @@ func (handler *eventHandler) OnOpen(gConn gnet.Conn) (out []byte, action gnet.Ac
 		action = gnet.Close
 		return
 	}
-	handler.server.listener.OnOpened(sess)
+	if handler.server.options.ProxyProtocol != session.ProxyOff {
+		// 读取PROXY协议头后再开启会话
+		conn.proxyPending = true
+		return
+	}
+	action = handler.open(sess)
 	return
 }
 
+// open
+//
+//	@Description: 占用连接数并开启会话，开启PROXY协议时在读取协议头后执行
+//	@receiver handler
+//	@param sess
+//	@return gnet.Action
+func (handler *eventHandler) open(sess *svrSession) gnet.Action {
+	if counter := handler.server.connCounter; counter != nil {
+		if event := counter.Acquire(sess.conn.RemoteAddr()); event != nil {
+			session.RejectConn(handler.server.name, handler.server.listener, event)
+			return gnet.Close
+		}
+		sess.conn.counted = true
+	}
+	if handler.server.options.UpgradeHandler != nil {
+		// 握手成功后再通知会话开启
+		return gnet.None
+	}
+	sess.conn.opened = true
+	handler.server.listener.OnOpened(sess)
+	return gnet.None
+}
+
 func (handler *eventHandler) OnClose(conn gnet.Conn, err error) (action gnet.Action) {
 	connCtx := conn.Context()
 	if connCtx == nil {
@@ -62,11 +98,22 @@ func (handler *eventHandler) OnClose(conn gnet.Conn, err error) (action gnet.Act
 	sess.conn.ToClosed(err)
 	// 移除会话
 	handler.server.RemoveSession(sess)
+	if sess.conn.counted {
+		handler.server.connCounter.Release(sess.conn.RemoteAddr())
+	}
 	plog.Debug("close ws-server connecting:",
 		pfield.String("server", handler.server.name),
 		pfield.Uint64("conn", sess.conn.Hash()))
-	// 触发关闭监听
-	handler.server.listener.OnClosed(sess)
+	// 触发关闭监听，被拒绝或握手未完成的会话未通知过开启
+	if !sess.conn.opened {
+		sess.Attributes().Clear()
+	} else if dispatcher := handler.server.options.Dispatcher; dispatcher != nil {
+		// 与已提交的消息在同一队列中执行
+		dispatcher.DispatchClosed(sess, handler.server.listener, handler.server.closeReason(sess))
+	} else {
+		handler.server.listener.OnClosed(sess, handler.server.closeReason(sess))
+		sess.Attributes().Clear()
+	}
 	return
 }
 
@@ -83,9 +130,33 @@ func (handler *eventHandler) OnTraffic(conn gnet.Conn) (action gnet.Action) {
 		action = gnet.Close
 		return
 	}
-	msgArr, totalLen, dAction := handler.server.codec.Decode(sess.conn)
-	if dAction == gnet.Close {
-		action = gnet.Close
+	sess.conn.UpdateReadTime()
+	if sess.conn.proxyPending {
+		if action = handler.readProxyHeader(sess); action == gnet.Close || sess.conn.proxyPending {
+			return
+		}
+	}
+	var msgArr []any
+	var totalLen int
+	// 有延迟投递的消息时暂停解码
+	if sess.limiter == nil || !sess.limiter.Pending() {
+		upgraded := sess.conn.upgraded.Load()
+		msgArr, totalLen, action = handler.server.codec.Decode(sess.conn)
+		if !upgraded && sess.conn.upgraded.Load() && !sess.conn.opened {
+			sess.conn.opened = true
+			handler.server.listener.OnOpened(sess)
+		}
+	}
+	if sess.limiter != nil && action != gnet.Close {
+		if msgArr, totalLen, action = handler.server.admit(sess, msgArr, totalLen); action == gnet.Close {
+			return
+		}
+	}
+	if action == gnet.Close && len(msgArr) <= 0 {
+		return
+	}
+	if dispatcher := handler.server.options.Dispatcher; dispatcher != nil {
+		_ = dispatcher.Dispatch(sess, handler.server.listener, msgArr, totalLen)
 		return
 	}
 	msgNum := len(msgArr)
@@ -98,7 +169,44 @@ func (handler *eventHandler) OnTraffic(conn gnet.Conn) (action gnet.Action) {
 }
 
 func (handler *eventHandler) OnTick() (delay time.Duration, action gnet.Action) {
-	handler.server.CheckSessions()
-	delay = handler.server.options.CheckSessionInterval
+	now := time.Now()
+	options := handler.server.options
+	delay = options.CheckSessionInterval
+	if options.Heartbeat != nil {
+		handler.server.checkIdleSessions(now)
+		if tick := options.Heartbeat.TickInterval(); tick < delay {
+			delay = tick
+		}
+	}
+	if options.Backpressure != nil {
+		session.CheckSlowSessions(handler.server.Manager, handler.server)
+		if session.BackpressureSampleInterval < delay {
+			delay = session.BackpressureSampleInterval
+		}
+	}
+	if !now.Before(handler.nextCheckTime) {
+		handler.server.CheckSessions()
+		handler.nextCheckTime = now.Add(options.CheckSessionInterval)
+	}
 	return
 }
+
+// readProxyHeader
+//
+//	@Description: 读取PROXY协议头，读取完成后开启会话，在事件循环中执行
+//	@receiver handler
+//	@param sess
+//	@return gnet.Action
+func (handler *eventHandler) readProxyHeader(sess *svrSession) gnet.Action {
+	done, err := session.AcceptProxyHeader(sess.conn.Conn, &sess.conn.BaseConn,
+		handler.server.options.ProxyProtocol, handler.server.name)
+	if err != nil {
+		sess.conn.ToClosed(err)
+		return gnet.Close
+	}
+	if !done {
+		return gnet.None
+	}
+	sess.conn.proxyPending = false
+	return handler.open(sess)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/meow-pad/persian/frame/plog"
+	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
+	"github.com/panjf2000/gnet/v2"
+)
+
+// admit
+//
+//	@Description: 按速率限制放行消息，在事件循环中执行
+//	@receiver server
+//	@param sess
+//	@param msgArr 本次解码的消息
+//	@param totalLen 消息总长度
+//	@return []any 放行的消息
+//	@return int 放行的消息长度
+//	@return gnet.Action
+func (server *Server) admit(sess *svrSession, msgArr []any, totalLen int) ([]any, int, gnet.Action) {
+	msgArr, totalLen, closing := sess.limiter.Apply(sess, server.listener, msgArr, totalLen,
+		sess.conn.InboundBuffered() > 0, func() {
+			server.wakeConn(sess)
+		})
+	if closing {
+		server.codec.failConnection(sess.conn,
+			&CloseError{Code: ws.StatusPolicyViolation, Reason: pnet.ErrRateLimited.Error()})
+		return nil, 0, gnet.Close
+	}
+	return msgArr, totalLen, gnet.None
+}
+
+func (server *Server) wakeConn(sess *svrSession) {
+	if err := sess.conn.Conn.Wake(nil); err != nil {
+		plog.Debug("wake connection error:", pfield.Error(err))
+	}
+}
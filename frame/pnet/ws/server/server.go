@@ -3,12 +3,18 @@ package server
 import (
 	"context"
 	"errors"
+	"github.com/gobwas/ws"
+	"github.com/meow-pad/persian/errdef"
 	"github.com/meow-pad/persian/frame/plog"
 	"github.com/meow-pad/persian/frame/plog/pfield"
+	"github.com/meow-pad/persian/frame/pnet"
 	"github.com/meow-pad/persian/frame/pnet/message"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/frame/pnet/utils"
 	"github.com/panjf2000/gnet/v2"
+	gerrors "github.com/panjf2000/gnet/v2/pkg/errors"
+	"sync/atomic"
+	"time"
 )
 
 func NewServer(name string, protoAddr string,
@@ -26,26 +32,53 @@ func NewServer(name string, protoAddr string,
 	if swCodec, err = newWsCodec(codec); err != nil {
 		return
 	}
+	swCodec.upgradeHandler = options.UpgradeHandler
+	swCodec.textFrame = options.TextFrame
+	swCodec.maxMessageLength = options.MaxMessageLength
+	if options.Compression != nil {
+		if swCodec.deflater, err = newDeflater(options.Compression); err != nil {
+			return
+		}
+	}
 	if listener == nil {
 		err = errors.New("less listener")
 		return
 	}
-	var manager *session.Manager
-	if manager, err = session.NewManager(name, options.UnregisterSessionLife); err != nil {
-		return
+	manager := options.Manager
+	if manager == nil {
+		if manager, err = session.NewManager(name, options.UnregisterSessionLife); err != nil {
+			return
+		}
+	}
+	if options.Resume != nil {
+		manager.EnableResume(options.Resume)
 	}
-	return &Server{
+	server = &Server{
 		Manager:   manager,
 		options:   options,
 		name:      name,
 		protoAddr: protoAddr,
 		codec:     swCodec,
 		listener:  listener,
-	}, nil
+
+		connCounter: session.NewConnCounter(options.Limit),
+	}
+	server.Broadcaster = session.NewBroadcaster(manager, server, server.codec.newFrame,
+		func(sess *svrSession, frame *wsFrame, message any) {
+			data, err := frame.data(sess.conn)
+			if err != nil {
+				plog.Error("encode frame error:", pfield.Error(err))
+				return
+			}
+			sess.sendData(frame, data, message)
+		})
+	return
 }
 
 type Server struct {
 	*session.Manager
+	// 广播，仅发送给本服务接受的会话
+	*session.Broadcaster[*svrSession, *wsFrame]
 	options *Options
 
 	// 服务名称
@@ -60,6 +93,12 @@ type Server struct {
 	listener session.Listener
 	// 启动通知
 	startChan chan gnet.Engine
+	// 是否正在排空，排空时拒绝新连接
+	draining atomic.Bool
+	// 连接计数，未限制连接数时为nil
+	connCounter *session.ConnCounter
+	// 是否已开始停服
+	stopping atomic.Bool
 }
 
 func (server *Server) Start(ctx context.Context) error {
@@ -85,10 +124,151 @@ func (server *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// Stop
+//
+//	@Description: 停止服务，配置了排空时先排空，可作为 pboot.LifeCycle 按注册顺序逆序停止
+//	@receiver server
+//	@param ctx 停止上下文，排空、强制关闭及停止引擎均以其截止时间为界；超时后可再次调用以等待引擎停止
+//	@return error
 func (server *Server) Stop(ctx context.Context) error {
-	return server.engine.Stop(ctx)
+	first := server.stopping.CompareAndSwap(false, true)
+	if server.options.Drain != nil {
+		server.drain(ctx)
+	}
+	err := server.engine.Stop(ctx)
+	if !first && errors.Is(err, gerrors.ErrEngineInShutdown) {
+		// 之前的调用已停止引擎
+		return nil
+	}
+	return err
+}
+
+// drain
+//
+//	@Description: 拒绝新连接并通知已注册会话，等待客户端断开后强制关闭剩余会话
+//	@receiver server
+//	@param ctx 停止上下文
+func (server *Server) drain(ctx context.Context) {
+	if !server.draining.CompareAndSwap(false, true) {
+		return
+	}
+	server.options.Drain.Drain(ctx, server.name, server.engine.CountConnections, func(message any) error {
+		return server.BroadcastAll(message)
+	}, server.closeRemain)
+}
+
+// closeRemain
+//
+//	@Description: 强制关闭排空后剩余的会话
+//	@receiver server
+//	@return int 关闭的会话数
+func (server *Server) closeRemain() int {
+	forced := 0
+	server.RangeSessions(func(sess session.Session) bool {
+		svrSess, ok := server.ownSession(sess)
+		if !ok || svrSess.IsClosed() {
+			return true
+		}
+		forced++
+		if err := svrSess.CloseWithStatus(ws.StatusGoingAway, "server shutting down"); err != nil {
+			plog.Error("close draining session error:", pfield.Error(err))
+		}
+		return true
+	})
+	return forced
+}
+
+// closeReason
+//
+//	@Description: 获取会话的结构化关闭原因
+//	@receiver server
+//	@param sess 已关闭的会话
+//	@return *session.CloseReason
+func (server *Server) closeReason(sess *svrSession) *session.CloseReason {
+	_, err := sess.conn.IsClosed()
+	if err == nil && server.stopping.Load() {
+		// 停服时由引擎关闭的连接
+		return session.NewCloseReason(session.CloseShutdown, session.CloseByLocal, pnet.ErrServerStopped)
+	}
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		initiator := session.CloseByLocal
+		if closeErr.Remote {
+			initiator = session.CloseByPeer
+		}
+		category := session.WebSocketCloseCategory(int(closeErr.Code), closeErr.Remote)
+		return session.NewCloseReason(category, initiator, closeErr)
+	}
+	return session.ResolveCloseReason(err)
 }
 
 func (server *Server) Name() string {
 	return server.name
 }
+
+// CName
+//
+//	@Description: 组件名，用于作为 pboot.LifeCycle 注册
+//	@receiver server
+//	@return string
+func (server *Server) CName() string {
+	return server.name
+}
+
+// ResumeSession
+//
+//	@Description: 在新连接的会话上恢复会话，成功后会话关联原业务对象并重发客户端缺失的消息
+//	@receiver server
+//	@param sess 新连接的未注册会话
+//	@param token 注册时签发的恢复令牌，可由 session.Session.ResumeState 获取
+//	@param lastSeq 客户端已收到的最后消息序号，注册后发出的消息从1开始按发送顺序编号
+//	@return error
+func (server *Server) ResumeSession(sess session.Session, token string, lastSeq uint64) error {
+	svrSess, ok := server.ownSession(sess)
+	if !ok {
+		return errdef.ErrInvalidParams
+	}
+	return server.Manager.ResumeSession(svrSess, token, lastSeq, svrSess.replay)
+}
+
+// checkIdleSessions
+//
+//	@Description: 检查空闲会话，发送心跳或关闭超时会话
+//	@receiver server
+//	@param now
+func (server *Server) checkIdleSessions(now time.Time) {
+	heartbeat := server.options.Heartbeat
+	server.RangeSessions(func(sess session.Session) bool {
+		svrSess, ok := server.ownSession(sess)
+		if !ok || svrSess.IsClosed() {
+			return true
+		}
+		switch svrSess.conn.CheckIdle(heartbeat, now) {
+		case session.IdleHeartbeat:
+			if svrSess.conn.upgraded.Load() {
+				if err := svrSess.conn.Conn.AsyncWrite(ws.CompiledPing, nil); err != nil {
+					plog.Error("write ping error:", pfield.Error(err))
+				}
+			}
+		case session.IdleClose:
+			plog.Debug("close idle session:",
+				pfield.String("server", server.name),
+				pfield.Uint64("conn", svrSess.conn.Hash()))
+			if err := svrSess.closeWithReason(pnet.ErrIdleTimeout); err != nil {
+				plog.Error("close idle session error:", pfield.Error(err))
+			}
+		}
+		return true
+	})
+}
+
+// ownSession
+//
+//	@Description: 获取由本服务接受的会话，共享管理器时忽略其他服务的会话
+//	@receiver server
+//	@param sess
+//	@return *svrSession
+//	@return bool
+func (server *Server) ownSession(sess session.Session) (*svrSession, bool) {
+	return session.OwnSession[*svrSession](sess, server)
+}
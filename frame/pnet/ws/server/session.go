@@ -16,9 +16,12 @@ func newSession(server *Server, conn *Conn) (*svrSession, error) {
 		return nil, pnet.ErrClosedConn
 	}
 	svrSess := &svrSession{
-		server: server,
-		conn:   conn,
+		server:  server,
+		conn:    conn,
+		limiter: session.NewLimiter(server.options.Limit),
 	}
+	svrSess.backpressure = session.NewBackpressure(server.options.Backpressure, server.name, server.listener,
+		svrSess, &conn.BaseConn, conn.Conn, svrSess.closeSlow)
 	conn.SetContext(svrSess)
 	return svrSess, nil
 }
@@ -29,10 +32,32 @@ type svrSession struct {
 	server *Server
 	// 关联的连接
 	conn *Conn
+	// 速率限制器，未限制速率时为nil
+	limiter *session.Limiter
+	// 出站背压，未开启背压时为nil
+	backpressure *session.Backpressure
 	// 关联的业务对象
 	context session.Context
 }
 
+// Owner
+//
+//	@Description: 接受该会话的服务
+//	@receiver sess
+//	@return any
+func (sess *svrSession) Owner() any {
+	return sess.server
+}
+
+// Backpressure
+//
+//	@Description: 会话的出站背压
+//	@receiver sess
+//	@return *session.Backpressure 未开启背压时为nil
+func (sess *svrSession) Backpressure() *session.Backpressure {
+	return sess.backpressure
+}
+
 func (sess *svrSession) Connection() session.Conn {
 	return sess.conn
 }
@@ -55,17 +80,40 @@ func (sess *svrSession) SendMessage(message any) {
 		plog.Debug("cant send to closed conn")
 		return
 	}
-	data, err := sess.server.codec.Encode(message)
+	frame, err := sess.server.codec.newFrame(message)
 	if err != nil {
 		sess.onSendingError("encode message error:", err)
 		return
 	}
+	data, err := frame.data(sess.conn)
+	if err != nil {
+		sess.onSendingError("encode frame error:", err)
+		return
+	}
+	sess.sendData(frame, data, message)
+}
+
+// sendData
+//
+//	@Description: 发送已编码的消息数据，数据可在多个会话间共享
+//	@receiver sess
+//	@param frame 消息帧
+//	@param data 编码后的数据
+//	@param message 原始消息
+func (sess *svrSession) sendData(frame *wsFrame, data []byte, message any) {
+	if closed, _ := sess.conn.IsClosed(); closed {
+		return
+	}
+	if sess.backpressure != nil && !sess.backpressure.Admit(message) {
+		return
+	}
 	dataLen := len(data)
-	err = sess.conn.AsyncWrite(data, func(c session.Conn, err error) error {
+	err := sess.asyncWritev([]*wsFrame{frame}, [][]byte{data}, func(c session.Conn, err error) error {
 		if err != nil {
 			sess.onSendingError("write message error:", err)
 			return nil
 		}
+		sess.conn.UpdateWriteTime()
 		if err = sess.server.listener.OnSend(sess, message, dataLen); err != nil {
 			plog.Error("on send error:", pfield.Error(err))
 		}
@@ -81,22 +129,35 @@ func (sess *svrSession) SendMessages(messages ...any) {
 		plog.Debug("cant send to closed conn")
 		return
 	}
+	if sess.backpressure != nil {
+		if messages = sess.backpressure.AdmitAll(messages); len(messages) <= 0 {
+			return
+		}
+	}
 	totalLen := 0
+	frames := make([]*wsFrame, 0, len(messages))
 	dataArr := make([][]byte, 0, len(messages))
 	for _, message := range messages {
-		data, err := sess.server.codec.Encode(message)
+		frame, err := sess.server.codec.newFrame(message)
 		if err != nil {
 			sess.onSendingError("encode message error:", err)
 			return
 		}
+		data, err := frame.data(sess.conn)
+		if err != nil {
+			sess.onSendingError("encode frame error:", err)
+			return
+		}
+		frames = append(frames, frame)
 		dataArr = append(dataArr, data)
 		totalLen += len(data)
 	}
-	err := sess.conn.AsyncWritev(dataArr, func(c session.Conn, err error) error {
+	err := sess.asyncWritev(frames, dataArr, func(c session.Conn, err error) error {
 		if err != nil {
 			sess.onSendingError("write messages error:", err)
 			return nil
 		}
+		sess.conn.UpdateWriteTime()
 		if err = sess.server.listener.OnSendMulti(sess, messages, totalLen); err != nil {
 			plog.Error("on send error:", pfield.Error(err))
 		}
@@ -107,6 +168,96 @@ func (sess *svrSession) SendMessages(messages ...any) {
 	}
 }
 
+// asyncWritev
+//
+//	@Description: 异步写出数据，开启会话恢复时按写出顺序将消息帧记录到重放缓冲，开启背压时统计出站字节数
+//	@receiver sess
+//	@param frames 消息帧，重放时按新连接的压缩协商重新编码
+//	@param dataArr 编码后的数据，与消息帧一一对应
+//	@param callback 写出完成回调
+//	@return error
+func (sess *svrSession) asyncWritev(frames []*wsFrame, dataArr [][]byte,
+	callback func(c session.Conn, err error) error) (err error) {
+	if sess.backpressure != nil {
+		var size int
+		size, callback = sess.backpressure.Track(dataArr, callback)
+		defer func() {
+			if err != nil {
+				sess.backpressure.Cancel(size)
+			}
+			sess.backpressure.Update()
+		}()
+	}
+	write := func() error {
+		if len(dataArr) == 1 {
+			return sess.conn.AsyncWrite(dataArr[0], callback)
+		}
+		return sess.conn.AsyncWritev(dataArr, callback)
+	}
+	state := sess.ResumeState()
+	if state == nil {
+		return write()
+	}
+	items := make([]any, 0, len(frames))
+	for _, frame := range frames {
+		items = append(items, frame)
+	}
+	return state.Send(sess, items, write)
+}
+
+// replay
+//
+//	@Description: 恢复会话时重发缺失的消息，开启背压时统计出站字节数
+//	@receiver sess
+//	@param items 重放缓冲中的消息帧
+//	@return error
+func (sess *svrSession) replay(items []any) (err error) {
+	dataArr := make([][]byte, 0, len(items))
+	for _, item := range items {
+		frame, ok := item.(*wsFrame)
+		if !ok {
+			// 共享管理器时由其他协议的服务记录，无法重放
+			return pnet.ErrResumeSeqMissed
+		}
+		data, err := frame.data(sess.conn)
+		if err != nil {
+			return err
+		}
+		dataArr = append(dataArr, data)
+	}
+	callback := func(c session.Conn, err error) error {
+		if err != nil {
+			sess.onSendingError("replay messages error:", err)
+			return nil
+		}
+		sess.conn.UpdateWriteTime()
+		return nil
+	}
+	if sess.backpressure != nil {
+		// 新会话尚无暂存消息，在恢复状态锁内更新慢速状态不会重入发送
+		var size int
+		size, callback = sess.backpressure.Track(dataArr, callback)
+		defer func() {
+			if err != nil {
+				sess.backpressure.Cancel(size)
+			}
+			sess.backpressure.Update()
+		}()
+	}
+	return sess.conn.AsyncWritev(dataArr, callback)
+}
+
+// closeWithReason
+//
+//	@Description: 记录关闭原因后关闭连接
+//	@receiver sess
+//	@param reason 关闭原因
+//	@return error
+func (sess *svrSession) closeWithReason(reason error) error {
+	sess.conn.ToClosed(reason)
+	return sess.conn.Close()
+}
+
 // onSendingError
 //
 //	@Description: 发送消息时错误处理
@@ -116,6 +267,7 @@ func (sess *svrSession) SendMessages(messages ...any) {
 func (sess *svrSession) onSendingError(tip string, err error) {
 	plog.Error(tip, pfield.Error(err))
 	// 无法处理的状态，关闭连接
+	sess.conn.ToClosed(session.NewCloseReason(session.CloseNetwork, session.CloseByLocal, err))
 	cErr := sess.conn.Close()
 	if cErr != nil {
 		plog.Error("close conn error", pfield.Error(cErr))
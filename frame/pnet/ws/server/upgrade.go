@@ -0,0 +1,146 @@
+package server
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/meow-pad/persian/frame/pnet/tcp/session"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const headerSecProtocol = "Sec-WebSocket-Protocol"
+
+// UpgradeRequest
+//
+//	@Description: websocket握手请求信息
+type UpgradeRequest struct {
+	// 请求URI，包含路径与查询参数
+	URI string
+	// 请求Host
+	Host string
+	// 请求头，不包含websocket协议相关的头
+	Header http.Header
+	// 客户端请求的子协议，按客户端优先级排列
+	Protocols []string
+	// 客户端地址
+	RemoteAddr net.Addr
+}
+
+// URL
+//
+//	@Description: 解析请求URI
+//	@receiver req
+//	@return *url.URL
+//	@return error
+func (req *UpgradeRequest) URL() (*url.URL, error) {
+	return url.ParseRequestURI(req.URI)
+}
+
+// Cookies
+//
+//	@Description: 解析请求中的cookie
+//	@receiver req
+//	@return []*http.Cookie
+func (req *UpgradeRequest) Cookies() []*http.Cookie {
+	return (&http.Request{Header: req.Header}).Cookies()
+}
+
+// UpgradeResponse
+//
+//	@Description: websocket握手响应
+type UpgradeResponse struct {
+	// 选中的子协议，需为请求的子协议之一，为空时不协商
+	Protocol string
+	// 额外的响应头
+	Header http.Header
+}
+
+// UpgradeHandler
+//
+//	@Description: websocket握手处理函数，在发送握手响应前调用
+//		返回错误时拒绝握手并关闭连接，可使用 RejectUpgrade 指定HTTP状态码
+//		可在此注册会话以关联业务对象，设置后 Listener.OnOpened 将在握手成功后调用
+type UpgradeHandler func(sess session.Session, req *UpgradeRequest) (*UpgradeResponse, error)
+
+// RejectUpgrade
+//
+//	@Description: 构造拒绝握手的错误
+//	@param status HTTP状态码
+//	@param reason 原因，作为响应内容
+//	@return error
+func RejectUpgrade(status int, reason string) error {
+	return ws.RejectConnectionError(ws.RejectionStatus(status), ws.RejectionReason(reason))
+}
+
+// newUpgrader
+//
+//	@Description: 构造握手升级器，未设置握手处理函数时使用默认升级器
+//	@param handler 握手处理函数
+//	@param sess 会话
+//	@return ws.Upgrader
+func newUpgrader(handler UpgradeHandler, sess *svrSession) ws.Upgrader {
+	if handler == nil || sess == nil {
+		return ws.Upgrader{}
+	}
+	req := &UpgradeRequest{
+		Header:     make(http.Header),
+		RemoteAddr: sess.conn.RemoteAddr(),
+	}
+	return ws.Upgrader{
+		OnRequest: func(uri []byte) error {
+			req.URI = string(uri)
+			return nil
+		},
+		OnHost: func(host []byte) error {
+			req.Host = string(host)
+			return nil
+		},
+		OnHeader: func(key, value []byte) error {
+			req.Header.Add(string(key), string(value))
+			return nil
+		},
+		ProtocolCustom: func(value []byte) (string, bool) {
+			for _, protocol := range strings.Split(string(value), ",") {
+				if protocol = strings.TrimSpace(protocol); len(protocol) > 0 {
+					req.Protocols = append(req.Protocols, protocol)
+				}
+			}
+			// 仅收集，由握手处理函数选择
+			return "", true
+		},
+		OnBeforeUpgrade: func() (ws.HandshakeHeader, error) {
+			resp, err := handler(sess, req)
+			if err != nil {
+				return nil, err
+			}
+			if resp == nil {
+				return nil, nil
+			}
+			header := resp.Header
+			if len(resp.Protocol) > 0 {
+				if !containsProtocol(req.Protocols, resp.Protocol) {
+					return nil, ws.ErrHandshakeBadProtocol
+				}
+				header = header.Clone()
+				if header == nil {
+					header = make(http.Header)
+				}
+				header.Set(headerSecProtocol, resp.Protocol)
+			}
+			if len(header) <= 0 {
+				return nil, nil
+			}
+			return ws.HandshakeHeaderHTTP(header), nil
+		},
+	}
+}
+
+func containsProtocol(protocols []string, protocol string) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,29 @@
 package test
 
 import (
+	"compress/flate"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/gobwas/ws"
+	"github.com/gorilla/websocket"
+	"github.com/meow-pad/persian/frame/pnet"
 	"github.com/meow-pad/persian/frame/pnet/message"
 	"github.com/meow-pad/persian/frame/pnet/tcp/session"
 	"github.com/meow-pad/persian/frame/pnet/utils"
 	"github.com/meow-pad/persian/frame/pnet/ws/client"
 	"github.com/meow-pad/persian/frame/pnet/ws/server"
+	"github.com/meow-pad/persian/utils/worker"
+	"github.com/panjf2000/gnet/v2"
 	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,7 +49,7 @@ func (listener *svrListener) OnOpened(session session.Session) {
 	listener.t.Logf("ws-svr open conn:%v", session.Connection().Hash())
 }
 
-func (listener *svrListener) OnClosed(session session.Session) {
+func (listener *svrListener) OnClosed(session session.Session, reason *session.CloseReason) {
 	listener.t.Logf("ws-svr close conn:%v", session.Connection().Hash())
 }
 
@@ -61,7 +76,7 @@ func (listener *cliListener) OnOpened(session session.Session) {
 	listener.t.Logf("ws-cli open conn:%v", session.Connection().Hash())
 }
 
-func (listener *cliListener) OnClosed(session session.Session) {
+func (listener *cliListener) OnClosed(session session.Session, reason *session.CloseReason) {
 	listener.t.Logf("ws-cli close conn:%v", session.Connection().Hash())
 }
 
@@ -98,3 +113,619 @@ func TestWS_Echo(t *testing.T) {
 	err = echoSvr.Stop(context.Background())
 	should.Nil(err)
 }
+
+type recvListener struct {
+	session.EmptyListener
+	received chan any
+}
+
+func (listener *recvListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	listener.received <- msg
+	return nil
+}
+
+func TestWS_Secure(t *testing.T) {
+	should := require.New(t)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"persian.v2", "persian.v1"}}
+	tlsSvr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if r.Header.Get("Authorization") != "Bearer meow" || err != nil || cookie.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Server": []string{"echo"}})
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			opType, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(opType, payload); err != nil {
+				return
+			}
+		}
+	}))
+	defer tlsSvr.Close()
+	wsUrl, err := url.Parse(tlsSvr.URL)
+	should.Nil(err)
+	wsUrl.Scheme = utils.ProtoWebsocketSecure
+	pool := x509.NewCertPool()
+	pool.AddCert(tlsSvr.Certificate())
+	jar, err := cookiejar.New(nil)
+	should.Nil(err)
+	jar.SetCookies(&url.URL{Scheme: "https", Host: wsUrl.Host}, []*http.Cookie{{Name: "session", Value: "s1"}})
+
+	// 缺少鉴权头时握手失败
+	cli, err := client.NewClient(newCodec(), &cliListener{t: t}, client.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	should.Nil(err)
+	should.NotNil(cli.Dial(context.Background(), wsUrl))
+
+	listener := &recvListener{received: make(chan any, 1)}
+	cli, err = client.NewClient(newCodec(), listener,
+		client.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		client.WithHeader(http.Header{"Authorization": []string{"Bearer meow"}}),
+		client.WithCookieJar(jar),
+		client.WithSubprotocols("persian.v1"))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), wsUrl))
+	handshake := cli.Handshake()
+	should.NotNil(handshake)
+	should.Equal("persian.v1", handshake.Subprotocol)
+	should.Equal("echo", handshake.Header.Get("X-Server"))
+	cli.SendMessage("secure")
+	select {
+	case msg := <-listener.received:
+		should.Equal("secure", msg)
+	case <-time.After(3 * time.Second):
+		should.FailNow("wss echo timeout")
+	}
+	should.Nil(cli.Close())
+}
+
+type upgradeListener struct {
+	session.EmptyListener
+	tokens sync.Map
+	opened chan string
+}
+
+func (listener *upgradeListener) OnOpened(session session.Session) {
+	token, _ := listener.tokens.Load(session)
+	listener.opened <- token.(string)
+}
+
+func TestWS_Upgrade(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9081"
+	listener := &upgradeListener{opened: make(chan string, 1)}
+	svr, err := server.NewServer("test-server", addr, newCodec(), listener,
+		server.WithGNetOption(gnet.WithReuseAddr(true)),
+		server.WithUpgradeHandler(func(sess session.Session, req *server.UpgradeRequest) (*server.UpgradeResponse, error) {
+			reqUrl, err := req.URL()
+			if err != nil {
+				return nil, err
+			}
+			if req.Header.Get("Origin") != "http://game.local" {
+				return nil, server.RejectUpgrade(http.StatusForbidden, "bad origin")
+			}
+			token := reqUrl.Query().Get("token")
+			if len(token) <= 0 {
+				return nil, server.RejectUpgrade(http.StatusUnauthorized, "less token")
+			}
+			listener.tokens.Store(sess, token)
+			return &server.UpgradeResponse{
+				Protocol: req.Protocols[len(req.Protocols)-1],
+				Header:   http.Header{"X-Session": []string{"ok"}},
+			}, nil
+		}))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	origin := http.Header{"Origin": []string{"http://game.local"}}
+
+	// 缺少token时拒绝握手
+	_, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/game", origin)
+	should.NotNil(err)
+	should.NotNil(resp)
+	should.Equal(http.StatusUnauthorized, resp.StatusCode)
+	_, resp, err = websocket.DefaultDialer.Dial("ws://"+addr+"/game?token=meow", nil)
+	should.NotNil(err)
+	should.NotNil(resp)
+	should.Equal(http.StatusForbidden, resp.StatusCode)
+
+	cli, err := client.NewClient(newCodec(), &cliListener{t: t},
+		client.WithHeader(origin), client.WithSubprotocols("persian.v2", "persian.v1"))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(),
+		&url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/game", RawQuery: "token=meow"}))
+	should.Equal("persian.v1", cli.Handshake().Subprotocol)
+	should.Equal("ok", cli.Handshake().Header.Get("X-Session"))
+	select {
+	case token := <-listener.opened:
+		should.Equal("meow", token)
+	case <-time.After(3 * time.Second):
+		should.FailNow("session is not opened")
+	}
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestWS_Compression(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9082"
+	svr, err := server.NewServer("test-server", addr, newCodec(), &svrListener{t: t},
+		server.WithGNetOption(gnet.WithReuseAddr(true)),
+		server.WithCompression(&server.CompressionOptions{Level: flate.BestSpeed, Threshold: 64}))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	listener := &recvListener{received: make(chan any, 4)}
+	cli, err := client.NewClient(newCodec(), listener,
+		client.WithCompression(&client.CompressionOptions{Level: flate.BestSpeed, Threshold: 64}))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), &url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/"}))
+	should.Contains(cli.Handshake().Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	expects := []any{"hi", strings.Repeat(`{"name":"meow"}`, 200), strings.Repeat(`{"name":"pad"}`, 200)}
+	cli.SendMessage(expects[0])
+	cli.SendMessages(expects[1:]...)
+	for _, expected := range expects {
+		select {
+		case msg := <-listener.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("compressed echo timeout")
+		}
+	}
+	should.Nil(cli.Close())
+	should.Nil(svr.Stop(context.Background()))
+}
+
+type closeListener struct {
+	session.EmptyListener
+	closed chan error
+}
+
+func (listener *closeListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.closed <- reason
+}
+
+func (listener *closeListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	if msg == "kick" {
+		return sess.(server.Session).CloseWithStatus(4001, "kicked")
+	}
+	sess.SendMessage(msg)
+	return nil
+}
+
+func TestWS_CloseStatus(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9083"
+	listener := &closeListener{closed: make(chan error, 2)}
+	svr, err := server.NewServer("test-server", addr, newCodec(), listener,
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithTextFrame(true))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	closedReason := func() *server.CloseError {
+		select {
+		case reason := <-listener.closed:
+			closeErr, ok := reason.(*session.CloseReason).Err.(*server.CloseError)
+			should.True(ok, "unexpected close reason:%v", reason)
+			return closeErr
+		case <-time.After(3 * time.Second):
+			should.FailNow("session is not closed")
+		}
+		return nil
+	}
+
+	// 文本帧及服务端指定关闭码
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", nil)
+	should.Nil(err)
+	should.Nil(conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	msgType, data, err := conn.ReadMessage()
+	should.Nil(err)
+	should.Equal(websocket.TextMessage, msgType)
+	should.Equal("hi", string(data))
+	should.Nil(conn.WriteMessage(websocket.TextMessage, []byte("kick")))
+	_, _, err = conn.ReadMessage()
+	should.True(websocket.IsCloseError(err, 4001), "unexpected error:%v", err)
+	should.Equal(&server.CloseError{Code: 4001, Reason: "kicked"}, closedReason())
+	_ = conn.Close()
+
+	// 客户端指定关闭码
+	conn, _, err = websocket.DefaultDialer.Dial("ws://"+addr+"/", nil)
+	should.Nil(err)
+	should.Nil(conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(4002, "bye"), time.Now().Add(time.Second)))
+	_, _, err = conn.ReadMessage()
+	should.True(websocket.IsCloseError(err, 4002), "unexpected error:%v", err)
+	should.Equal(&server.CloseError{Code: 4002, Reason: "bye", Remote: true}, closedReason())
+	_ = conn.Close()
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestWS_Fragment(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9084"
+	svr, err := server.NewServer("test-server", addr, newCodec(), &svrListener{t: t},
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithMaxMessageLength(64))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	dial := func() net.Conn {
+		conn, _, _, dErr := ws.Dial(context.Background(), "ws://"+addr+"/")
+		should.Nil(dErr)
+		_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+		return conn
+	}
+	writeFrame := func(conn net.Conn, fin bool, op ws.OpCode, payload string) {
+		frame := ws.NewFrame(op, fin, []byte(payload))
+		should.Nil(ws.WriteFrame(conn, ws.MaskFrameInPlace(frame)))
+	}
+	readFrame := func(conn net.Conn) ws.Frame {
+		frame, rErr := ws.ReadFrame(conn)
+		should.Nil(rErr)
+		return frame
+	}
+	expectClose := func(conn net.Conn, code ws.StatusCode) {
+		frame := readFrame(conn)
+		should.Equal(ws.OpClose, frame.Header.OpCode)
+		closeCode, _ := ws.ParseCloseFrameData(frame.Payload)
+		should.Equal(code, closeCode)
+		_ = conn.Close()
+	}
+
+	// 分片消息间穿插控制帧
+	conn := dial()
+	writeFrame(conn, false, ws.OpText, "he")
+	writeFrame(conn, true, ws.OpPing, "p")
+	writeFrame(conn, false, ws.OpContinuation, "l")
+	writeFrame(conn, true, ws.OpContinuation, "lo")
+	frame := readFrame(conn)
+	should.Equal(ws.OpPong, frame.Header.OpCode)
+	should.Equal("p", string(frame.Payload))
+	frame = readFrame(conn)
+	should.Equal(ws.OpBinary, frame.Header.OpCode)
+	should.Equal("hello", string(frame.Payload))
+	// 分片合并后超长
+	writeFrame(conn, false, ws.OpBinary, strings.Repeat("a", 40))
+	writeFrame(conn, true, ws.OpContinuation, strings.Repeat("a", 40))
+	expectClose(conn, ws.StatusMessageTooBig)
+
+	// 非法UTF-8文本
+	conn = dial()
+	writeFrame(conn, true, ws.OpText, "\xff\xfe")
+	expectClose(conn, ws.StatusInvalidFramePayloadData)
+
+	// 未开始分片的后续帧
+	conn = dial()
+	writeFrame(conn, true, ws.OpContinuation, "lo")
+	expectClose(conn, ws.StatusProtocolError)
+	should.Nil(svr.Stop(context.Background()))
+}
+
+func TestWS_Drain(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9085"
+	svr, err := server.NewServer("test-server", addr, newCodec(), &svrListener{t: t},
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithDrain(300*time.Millisecond, nil))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", nil)
+	should.Nil(err)
+	should.Nil(conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	_, _, err = conn.ReadMessage()
+	should.Nil(err)
+	should.Nil(svr.Stop(context.Background()))
+	// 未断开的会话以 ws.StatusGoingAway 关闭
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	should.True(websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error:%v", err)
+	_ = conn.Close()
+}
+
+// gateListener
+//
+//	@Description: 消息处理等待放行，用于验证分发顺序
+type gateListener struct {
+	session.EmptyListener
+	gate     chan struct{}
+	received chan any
+}
+
+func (listener *gateListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	<-listener.gate
+	listener.received <- msg
+	return nil
+}
+
+func (listener *gateListener) OnReceiveMulti(session session.Session, msgArr []any, totalLen int) error {
+	for _, msg := range msgArr {
+		_ = listener.OnReceive(session, msg, 0)
+	}
+	return nil
+}
+
+func (listener *gateListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.received <- "closed"
+}
+
+func TestWS_Dispatch(t *testing.T) {
+	should := require.New(t)
+	pool, err := worker.NewFixedWorkerPool(4, 16, true)
+	should.Nil(err)
+	dispatcher, err := session.NewDispatcher(pool, session.DispatchBlock)
+	should.Nil(err)
+	addr := "127.0.0.1:9087"
+	listener := &gateListener{gate: make(chan struct{}), received: make(chan any, 8)}
+	svr, err := server.NewServer("test-server", addr, newCodec(), listener,
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithDispatcher(dispatcher))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	cli, err := newClient(&cliListener{t: t})
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), &url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/"}))
+	expected := []any{"1", "2", "3", "4", "5"}
+	for _, msg := range expected {
+		cli.SendMessage(msg)
+	}
+	// 消息处理被阻塞时断开连接，关闭通知排在已提交的消息之后
+	time.Sleep(200 * time.Millisecond)
+	should.Nil(cli.Close())
+	time.Sleep(200 * time.Millisecond)
+	close(listener.gate)
+	received := make([]any, 0, len(expected)+1)
+	for i := 0; i <= len(expected); i++ {
+		select {
+		case msg := <-listener.received:
+			received = append(received, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	should.Equal(append(expected, "closed"), received)
+	should.Equal(uint64(0), dispatcher.Stats().Dropped)
+	should.Nil(svr.Stop(context.Background()))
+	should.Nil(pool.Shutdown(context.Background()))
+}
+
+// kickListener
+//
+//	@Description: 收到 kick 时断开连接，其余消息回显
+type kickListener struct {
+	session.EmptyListener
+}
+
+func (listener *kickListener) OnReceive(session session.Session, msg any, msgLen int) (err error) {
+	if msg == "kick" {
+		return session.Close()
+	}
+	session.SendMessage(msg)
+	return nil
+}
+
+func TestWS_Reconnect(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9088"
+	svr, err := server.NewServer("test-server", addr, newCodec(), &kickListener{},
+		server.WithGNetOption(gnet.WithReuseAddr(true)))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	succeeded := make(chan session.ReconnectEvent, 1)
+	listener := &recvListener{received: make(chan any, 1)}
+	cli, err := client.NewClient(newCodec(), listener, client.WithReconnect(&session.ReconnectOptions{
+		InitialDelay: 20 * time.Millisecond,
+		MaxAttempts:  5,
+		OnEvent: func(event session.ReconnectEvent) {
+			if event.Kind == session.ReconnectSucceeded {
+				succeeded <- event
+			}
+		},
+	}))
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), &url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/"}))
+	// 连续断线重连，每次重连均启动新的读协程
+	for i := 0; i < 3; i++ {
+		cli.SendMessage("kick")
+		select {
+		case <-succeeded:
+		case <-time.After(3 * time.Second):
+			should.FailNow("reconnect timeout")
+		}
+	}
+	cli.SendMessage("hello")
+	select {
+	case msg := <-listener.received:
+		should.Equal("hello", msg)
+	case <-time.After(3 * time.Second):
+		should.FailNow("receive timeout")
+	}
+	should.Nil(cli.Close())
+}
+
+func TestWS_SendMessages(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9089"
+	svr, err := server.NewServer("test-server", addr, newCodec(), &svrListener{t: t},
+		server.WithGNetOption(gnet.WithReuseAddr(true)))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	listener := &recvListener{received: make(chan any, 4)}
+	cli, err := newClient(listener)
+	should.Nil(err)
+	should.Nil(cli.Dial(context.Background(), &url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/"}))
+	// 批量发送的每条消息各自成帧
+	expects := []any{"1", "2", "3"}
+	cli.SendMessages(expects...)
+	for _, expected := range expects {
+		select {
+		case msg := <-listener.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	should.Nil(cli.Close())
+}
+
+type proxyListener struct {
+	session.EmptyListener
+	addrs chan net.Addr
+}
+
+func (listener *proxyListener) OnOpened(session session.Session) {
+	listener.addrs <- session.Connection().RemoteAddr()
+}
+
+func TestWS_Proxy(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9090"
+	listener := &proxyListener{addrs: make(chan net.Addr, 1)}
+	svr, err := server.NewServer("test-server", addr, newCodec(), listener,
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithProxyProtocol(session.ProxyRequired))
+	should.Nil(err)
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	dialer := func(header string) *websocket.Dialer {
+		return &websocket.Dialer{
+			HandshakeTimeout: 3 * time.Second,
+			NetDialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				conn, dErr := (&net.Dialer{}).DialContext(ctx, network, address)
+				if dErr != nil || header == "" {
+					return conn, dErr
+				}
+				// 协议头与握手请求分两次发送
+				if _, dErr = conn.Write([]byte(header)); dErr != nil {
+					_ = conn.Close()
+					return nil, dErr
+				}
+				time.Sleep(50 * time.Millisecond)
+				return conn, nil
+			},
+		}
+	}
+	v2Header := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x21, 0x11, 0, 12,
+		10, 0, 0, 1, 10, 0, 0, 2, 0x1f, 0x90, 0, 80)
+	for header, expected := range map[string]string{
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1234 80\r\n": "1.2.3.4:1234",
+		string(v2Header):                         "10.0.0.1:8080",
+	} {
+		conn, _, err := dialer(header).Dial("ws://"+addr+"/", nil)
+		should.Nil(err)
+		select {
+		case remoteAddr := <-listener.addrs:
+			should.Equal(expected, remoteAddr.String())
+		case <-time.After(3 * time.Second):
+			should.FailNow("open timeout")
+		}
+		should.Nil(conn.Close())
+	}
+	// 缺少协议头的连接被拒绝
+	_, _, err = dialer("").Dial("ws://"+addr+"/", nil)
+	should.NotNil(err)
+}
+
+type resumeListener struct {
+	session.EmptyListener
+	svr    *server.Server
+	closed chan struct{}
+}
+
+func (listener *resumeListener) OnClosed(session session.Session, reason *session.CloseReason) {
+	listener.closed <- struct{}{}
+}
+
+func (listener *resumeListener) OnReceive(sess session.Session, msg any, msgLen int) (err error) {
+	text, _ := msg.(string)
+	switch {
+	case text == "login":
+		ctx := &session.BaseContext{}
+		ctx.Init(1)
+		ctx.SetDeadline(time.Now().Add(time.Minute).Unix())
+		if err = sess.Register(ctx); err != nil {
+			return
+		}
+		sess.SendMessages("token:"+sess.ResumeState().Token(), "m1", "m2", "m3")
+	case strings.HasPrefix(text, "resume:"):
+		fields := strings.Split(text, ":")
+		seq, _ := strconv.ParseUint(fields[2], 10, 64)
+		if err = listener.svr.ResumeSession(sess, fields[1], seq); err != nil {
+			sess.SendMessage("resume failed:" + err.Error())
+			return nil
+		}
+	default:
+		sess.SendMessage(msg)
+	}
+	return
+}
+
+func TestWS_Resume(t *testing.T) {
+	should := require.New(t)
+	addr := "127.0.0.1:9091"
+	wsUrl := &url.URL{Scheme: utils.ProtoWebsocket, Host: addr, Path: "/"}
+	listener := &resumeListener{closed: make(chan struct{}, 4)}
+	svr, err := server.NewServer("test-server", addr, newCodec(), listener,
+		server.WithGNetOption(gnet.WithReuseAddr(true)), server.WithResume(time.Minute, 2))
+	should.Nil(err)
+	listener.svr = svr
+	should.Nil(svr.Start(context.Background()))
+	defer func() {
+		should.Nil(svr.Stop(context.Background()))
+	}()
+	expect := func(receiver *recvListener, expected any) {
+		select {
+		case msg := <-receiver.received:
+			should.Equal(expected, msg)
+		case <-time.After(3 * time.Second):
+			should.FailNow("receive timeout")
+		}
+	}
+	dial := func() (*client.Client, *recvListener) {
+		receiver := &recvListener{received: make(chan any, 8)}
+		cli, dErr := newClient(receiver)
+		should.Nil(dErr)
+		should.Nil(cli.Dial(context.Background(), wsUrl))
+		return cli, receiver
+	}
+	cli, receiver := dial()
+	cli.SendMessage("login")
+	var token string
+	select {
+	case msg := <-receiver.received:
+		token = strings.TrimPrefix(msg.(string), "token:")
+	case <-time.After(3 * time.Second):
+		should.FailNow("login timeout")
+	}
+	expect(receiver, "m1")
+	expect(receiver, "m2")
+	expect(receiver, "m3")
+	should.Nil(cli.Close())
+	select {
+	case <-listener.closed:
+	case <-time.After(3 * time.Second):
+		should.FailNow("close timeout")
+	}
+	// 缺失的 m1 已不在重放缓冲中，恢复失败且不绑定
+	cli, receiver = dial()
+	cli.SendMessage("resume:" + token + ":1")
+	expect(receiver, "resume failed:"+pnet.ErrResumeSeqMissed.Error())
+	should.Nil(svr.GetSession(1))
+	should.Nil(cli.Close())
+	// 缓冲中的消息按序重发，之后的消息序号连续
+	cli, receiver = dial()
+	cli.SendMessage("resume:" + token + ":2")
+	expect(receiver, "m2")
+	expect(receiver, "m3")
+	cli.SendMessage("echo")
+	expect(receiver, "echo")
+	resumed := svr.GetSession(1)
+	should.NotNil(resumed)
+	should.Equal(uint64(5), resumed.ResumeState().Seq())
+	should.Nil(cli.Close())
+}
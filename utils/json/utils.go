@@ -1,6 +1,7 @@
 package json
 
 import (
+	"errors"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/meow-pad/persian/frame/plog/pfield"
 	"github.com/meow-pad/persian/utils/loggers"
@@ -8,6 +9,18 @@ import (
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+// 拒绝未知字段的解析配置
+var strictJson = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	DisallowUnknownFields:  true,
+}.Froze()
+
+var ErrEmptyData = errors.New("empty json data")
+
+type RawMessage = jsoniter.RawMessage
+
 func Marshal(value any) ([]byte, error) {
 	return json.Marshal(value)
 }
@@ -19,6 +32,19 @@ func Unmarshal(data []byte, value any) error {
 	return json.Unmarshal(data, value)
 }
 
+// UnmarshalStrict
+//
+//	@Description: 解析数据，存在未知字段或数据为空时返回错误
+//	@param data
+//	@param value
+//	@return error
+func UnmarshalStrict(data []byte, value any) error {
+	if len(data) <= 0 {
+		return ErrEmptyData
+	}
+	return strictJson.Unmarshal(data, value)
+}
+
 func ToString(value any) string {
 	data, err := json.Marshal(value)
 	if err != nil {
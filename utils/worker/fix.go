@@ -108,6 +108,18 @@ func (pool *FixedWorkerPool) init(slotNum, queueSize int, blockingOnFull bool) e
 }
 
 func (pool *FixedWorkerPool) Submit(group int, task func(*GoroutineLocal)) error {
+	return pool.SubmitWithBlocking(group, task, pool.blockingOnFull)
+}
+
+// SubmitWithBlocking
+//
+//	@Description: 提交任务到工作池，并指定队列满时是否阻塞
+//	@receiver pool
+//	@param group 任务分组
+//	@param task 任务
+//	@param blockingOnFull 队列满时是否阻塞
+//	@return error
+func (pool *FixedWorkerPool) SubmitWithBlocking(group int, task func(*GoroutineLocal), blockingOnFull bool) error {
 	if pool.closed.Load() {
 		return ErrWorkerPoolClosed
 	}
@@ -119,7 +131,7 @@ func (pool *FixedWorkerPool) Submit(group int, task func(*GoroutineLocal)) error
 		}
 	}
 	worker := pool.taskWorkers[index]
-	if pool.blockingOnFull {
+	if blockingOnFull {
 		select {
 		case worker.queue <- task:
 			return nil